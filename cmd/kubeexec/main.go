@@ -1,16 +1,18 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
 
-	"kubeexec/internal/cmdutil"
+	"github.com/estebanmilaho/kubeexec"
 )
 
 var version = "dev"
@@ -21,13 +23,34 @@ func main() {
 	var namespace string
 	var container string
 	var selector string
+	var fieldSelector string
+	var node string
+	var owner string
+	var phase string
 	var context string
 	var dryRun bool
 	var pod string
 	var confirmContext bool
 	var nonInteractive bool
+	var picker string
 	var ignoreFzf bool
 	var allNamespaces bool
+	var wait time.Duration
+	var reconnect int
+	var reconnectCommand bool
+	var script string
+	var snippet string
+	var snippetArgs []string
+	var envAssignments []string
+	var envFiles []string
+	var forwardEnv []string
+	var configFile string
+	var confirmStyle string
+	var yesIKnow bool
+	var banner bool
+	var terminalTitle bool
+	var verbose int
+	var traceFile string
 	pflag.BoolVarP(&showVersion, "version", "v", false, "print version and exit")
 	pflag.BoolVarP(&showHelp, "help", "h", false, "show this message")
 	pflag.StringVar(&context, "context", "", "kubernetes context (overrides current context)")
@@ -35,18 +58,52 @@ func main() {
 		f.NoOptDefVal = ""
 	}
 	pflag.StringVarP(&namespace, "namespace", "n", "", "kubernetes namespace (defaults to current context/namespace)")
-	pflag.StringVarP(&container, "container", "c", "", "container name (defaults to pod's default)")
+	pflag.StringVarP(&container, "container", "c", "", "container name, including sidecar, init and running ephemeral containers (defaults to pod's default)")
 	pflag.StringVarP(&selector, "selector", "l", "", "label selector for pods (e.g. app=api)")
+	pflag.StringVar(&fieldSelector, "field-selector", "", "field selector for pods (e.g. status.phase=Running)")
+	pflag.StringVar(&node, "node", "", "only pods on this node (exact or partial name)")
+	pflag.StringVar(&owner, "owner", "", "only pods owned by kind/name (e.g. deployment/api, statefulset/db)")
+	pflag.StringVar(&phase, "phase", "", "only pods in this phase (Pending, Running, Succeeded, Failed, Unknown)")
 	pflag.BoolVarP(&allNamespaces, "all-namespaces", "A", false, "list pods across all namespaces")
+	pflag.DurationVar(&wait, "wait", 0, "wait up to this long for the target container to be running and ready before exec (--wait alone waits "+kubeexec.DefaultWaitTimeout.String()+")")
+	if f := pflag.Lookup("wait"); f != nil {
+		f.NoOptDefVal = kubeexec.DefaultWaitTimeout.String()
+	}
+	pflag.IntVar(&reconnect, "reconnect", 0, "re-attach up to this many times when the exec session drops abnormally (--reconnect alone allows "+strconv.Itoa(kubeexec.DefaultReconnectAttempts)+")")
+	if f := pflag.Lookup("reconnect"); f != nil {
+		f.NoOptDefVal = strconv.Itoa(kubeexec.DefaultReconnectAttempts)
+	}
+	pflag.BoolVar(&reconnectCommand, "reconnect-command", false, "with --reconnect, also run -- <CMD>, --script and snippets again when the session drops (by default only shells are re-attached)")
+	pflag.StringVar(&script, "script", "", "run a local script in the container via stdin, with args after -- (\"-\" reads the script from stdin)")
+	pflag.StringVarP(&snippet, "snippet", "s", "", "run a named snippet from the config (-s alone picks from the snippets that apply to the container)")
+	if f := pflag.Lookup("snippet"); f != nil {
+		f.NoOptDefVal = ""
+	}
+	pflag.StringArrayVar(&snippetArgs, "arg", nil, "snippet parameter as name=value, fills {{name}} in the snippet command (repeatable)")
+	pflag.StringArrayVarP(&envAssignments, "env", "e", nil, "set an environment variable for the remote command as KEY=VALUE, or KEY to forward the local value (repeatable)")
+	pflag.StringArrayVar(&envFiles, "env-from-file", nil, "read KEY=VALUE environment variables from a local file (repeatable)")
+	pflag.StringArrayVar(&forwardEnv, "forward-env", nil, "forward local environment variables whose names match a glob, e.g. 'AWS_*' (repeatable)")
+	pflag.StringVar(&configFile, "config", "", "config file layered above the project, user and system config (env: KUBEEXEC_CONFIG)")
 	pflag.BoolVar(&dryRun, "dry-run", false, "print kubectl command without executing")
+	pflag.BoolVar(&banner, "banner", false, "print the context, cluster server, namespace, pod, container, image, node and pod age to stderr before exec (color per context with banner-color in the config)")
+	pflag.BoolVar(&terminalTitle, "terminal-title", false, "set the terminal title to context/namespace/pod for the session and restore it afterwards")
+	pflag.CountVarP(&verbose, "verbose", "V", "log kubectl calls and resolution decisions to stderr; -VV adds kubectl stderr and where each setting came from")
+	pflag.StringVar(&traceFile, "trace-file", "", "write kubectl calls, resolution decisions and setting sources to `FILE` as JSON lines, e.g. for bug reports")
 	pflag.Var(newConfirmBoolFlag(&confirmContext), "confirm-context", "confirm when context/namespace looks like prod (values: true/True/1/on/ON/false/False/0/off/OFF; env: KUBEEXEC_CONFIRM_CONTEXT; config: ~/.config/kubeexec/kubeexec.toml, TOML boolean)")
 	if f := pflag.Lookup("confirm-context"); f != nil {
 		f.NoOptDefVal = "true"
 	}
+	pflag.StringVar(&confirmStyle, "confirm-style", "context", "how --confirm-context asks: context (type context/namespace), namespace (type the namespace), word (type a random word), yes-no or countdown (continue unless aborted)")
+	pflag.BoolVar(&yesIKnow, "yes-i-know", false, "skip the --confirm-context prompt; only works when KUBEEXEC_CONFIRM_TOKEN hashes to confirm-token-hash in the user config")
 	pflag.Var(newConfirmBoolFlag(&nonInteractive), "non-interactive", "run without stdin or TTY (no -i/-t), useful for scripts (values: true/True/1/on/ON/false/False/0/off/OFF; env: KUBEEXEC_NON_INTERACTIVE; config: ~/.config/kubeexec/kubeexec.toml, TOML boolean)")
 	if f := pflag.Lookup("non-interactive"); f != nil {
 		f.NoOptDefVal = "true"
 	}
+	pflag.StringVar(&picker, "picker", "fzf", "picker for ambiguous pods, contexts, containers and snippets: fzf, skim, builtin, none (fail instead) or a command reading items on stdin (env: KUBEEXEC_PICKER)")
+	pflag.Var(newConfirmBoolFlag(&ignoreFzf), "ignore-fzf", "same as --picker none (values: true/True/1/on/ON/false/False/0/off/OFF; env: KUBEEXEC_IGNORE_FZF)")
+	if f := pflag.Lookup("ignore-fzf"); f != nil {
+		f.NoOptDefVal = "true"
+	}
 	pflag.Usage = func() {
 		cmd := displayName()
 		fmt.Fprintln(os.Stdout, "USAGE:")
@@ -56,11 +113,26 @@ func main() {
 		fmt.Fprintf(os.Stdout, "  %s --context                : select a context from a list\n", cmd)
 		fmt.Fprintf(os.Stdout, "  %s <POD> -- <CMD> [ARGS]     : run a command in a specific pod\n", cmd)
 		fmt.Fprintf(os.Stdout, "  %s -- <CMD> [ARGS]           : select a pod, then run a command\n", cmd)
+		fmt.Fprintf(os.Stdout, "  %s <POD> --script <FILE> -- [ARGS] : run a local script in a pod\n", cmd)
+		fmt.Fprintf(os.Stdout, "  %s <POD> -s <SNIPPET>       : run a snippet from the config in a pod\n", cmd)
+		fmt.Fprintf(os.Stdout, "  %s <POD> -s                 : select a snippet that applies to the container\n", cmd)
+		fmt.Fprintf(os.Stdout, "  %s <POD> -e <KEY>=<VALUE>   : exec with an environment variable set\n", cmd)
 		fmt.Fprintf(os.Stdout, "  %s <POD> -c <NAME>          : exec into a specific container in a pod\n", cmd)
 		fmt.Fprintf(os.Stdout, "  %s -n <NS> -c <NAME>        : specify both namespace and container\n", cmd)
 		fmt.Fprintf(os.Stdout, "  %s -n <NS> -l <SEL>         : specify both namespace and selector\n", cmd)
+		fmt.Fprintf(os.Stdout, "  %s --node <NODE>            : select a pod running on a node (exact or partial)\n", cmd)
+		fmt.Fprintf(os.Stdout, "  %s --owner <KIND>/<NAME>    : select a pod owned by a workload\n", cmd)
+		fmt.Fprintf(os.Stdout, "  %s <POD> --wait[=TIMEOUT]   : wait for the pod to be ready, then exec\n", cmd)
+		fmt.Fprintf(os.Stdout, "  %s <POD> --reconnect[=N]    : re-attach when the session drops\n", cmd)
+		fmt.Fprintf(os.Stdout, "  %s <POD> -V [--trace-file F] : show kubectl calls and why the target was chosen\n", cmd)
 		fmt.Fprintf(os.Stdout, "  %s -A, --all-namespaces     : select a pod across all namespaces\n", cmd)
 		fmt.Fprintf(os.Stdout, "  %s -A <NS>/<POD>            : target a pod across all namespaces directly\n", cmd)
+		fmt.Fprintf(os.Stdout, "  %s tui                      : browse contexts, namespaces, pods and containers full-screen\n", cmd)
+		fmt.Fprintf(os.Stdout, "  %s config view              : show the effective settings and where each came from\n", cmd)
+		fmt.Fprintf(os.Stdout, "  %s config validate          : check the config files, env and flags\n", cmd)
+		fmt.Fprintf(os.Stdout, "  %s config path              : list the config files that are read\n", cmd)
+		fmt.Fprintf(os.Stdout, "  %s config init [FILE]       : write a commented config with every setting\n", cmd)
+		fmt.Fprintf(os.Stdout, "  %s completion <SHELL>       : print a completion script (bash, zsh, fish, powershell)\n", cmd)
 		fmt.Fprintf(os.Stdout, "  %s version, -v, --version   : print version and exit\n", cmd)
 		fmt.Fprintf(os.Stdout, "  %s -h, --help               : show this message\n", cmd)
 		fmt.Fprintln(os.Stdout, "")
@@ -75,61 +147,82 @@ func main() {
 		fmt.Fprintln(os.Stdout, "NOTES:")
 		fmt.Fprintln(os.Stdout, "  - A kubectl context must be set unless --context is provided")
 		fmt.Fprintln(os.Stdout, "  - Uses the context namespace when -n is not provided")
-		fmt.Fprintln(os.Stdout, "  - If --context or <POD> is ambiguous, fzf picker is used")
-		fmt.Fprintln(os.Stdout, "  - If fzf is disabled and selection is required, the command exits with an error")
+		fmt.Fprintln(os.Stdout, "  - If --context or <POD> is ambiguous, the picker (fzf by default) is used")
+		fmt.Fprintln(os.Stdout, "  - With --picker none and a selection required, the command exits with an error")
+		fmt.Fprintln(os.Stdout, "  - --picker builtin prompts with a numbered list on the terminal; other commands get key<TAB>display lines")
+		fmt.Fprintln(os.Stdout, "  - --field-selector and --phase are sent to the API server; --node is too when it names a node exactly")
+		fmt.Fprintln(os.Stdout, "  - --wait follows the replacement pod if the chosen one is deleted while waiting")
 		fmt.Fprintln(os.Stdout, "  - If pod has multiple containers, default is used when available; otherwise picker is shown")
-		fmt.Fprintln(os.Stdout, "  - Config precedence: flag > env > ~/.config/kubeexec/kubeexec.toml")
+		fmt.Fprintln(os.Stdout, "  - -c also accepts sidecar (restartable init), init and running ephemeral containers")
+		fmt.Fprintln(os.Stdout, "  - Snippets are defined under [snippets.<name>] in the config; --arg values are shell-quoted")
+		fmt.Fprintln(os.Stdout, "  - -e, --env-from-file and --forward-env values are shown as *** in --dry-run output")
+		fmt.Fprintln(os.Stdout, "  - Confirmations are asked on the terminal (/dev/tty), so they work when stdin is piped")
+		fmt.Fprintln(os.Stdout, "  - --banner and --terminal-title can be set per context, e.g. banner-color = \"red\" under [context.\"prod-*\"]")
+		fmt.Fprintln(os.Stdout, "  - Exit status is the remote command's own; kubeexec failures use 2 (usage), 120 (not found),")
+		fmt.Fprintln(os.Stdout, "    121 (ambiguous), 122 (kubectl/cluster), 123 (denied) and 130 (cancelled)")
+		fmt.Fprintln(os.Stdout, "  - Every setting flag can also be set with KUBEEXEC_<FLAG> or in the config, e.g. namespace = \"payments\"")
+		fmt.Fprintln(os.Stdout, "  - Config precedence: flag > env > --config/KUBEEXEC_CONFIG > nearest .kubeexec.toml >")
+		fmt.Fprintln(os.Stdout, "    ~/.config/kubeexec/kubeexec.toml > /etc/kubeexec/kubeexec.toml")
 	}
 	pflag.CommandLine.SetOutput(io.Discard)
+	if len(os.Args) > 1 && os.Args[1] == "__complete" {
+		kubeexec.Complete(os.Args[2:], completionFlags(), os.Stdout)
+		return
+	}
 	flagArgs, commandArgs := splitCommandArgs(os.Args[1:])
 	if err := rejectDeprecatedArgs(flagArgs); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		fmt.Fprintln(os.Stderr)
 		pflag.Usage()
-		os.Exit(2)
+		os.Exit(kubeexec.ExitUsage)
 	}
 	if err := pflag.CommandLine.Parse(normalizeContextArgs(flagArgs)); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		fmt.Fprintln(os.Stderr)
 		pflag.Usage()
-		os.Exit(2)
+		os.Exit(kubeexec.ExitUsage)
 	}
+	kubeexec.SetConfigFile(configFile)
 	contextRequested := false
 	if f := pflag.Lookup("context"); f != nil && f.Changed {
 		contextRequested = true
 	}
-	confirmContextRequested := false
-	if f := pflag.Lookup("confirm-context"); f != nil && f.Changed {
-		confirmContextRequested = true
-	}
-	confirmContextEnabled, err := cmdutil.ResolveConfirmContext(confirmContextRequested, confirmContext)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "error:", err)
-		os.Exit(2)
-	}
-	nonInteractiveRequested := false
-	if f := pflag.Lookup("non-interactive"); f != nil && f.Changed {
-		nonInteractiveRequested = true
-	}
-	nonInteractiveEnabled, err := cmdutil.ResolveNonInteractive(nonInteractiveRequested, nonInteractive)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "error:", err)
-		os.Exit(2)
-	}
-	ignoreFzfEnabled, err := cmdutil.ResolveIgnoreFzf(false, ignoreFzf)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "error:", err)
-		os.Exit(2)
+	snippetRequested := false
+	if f := pflag.Lookup("snippet"); f != nil && f.Changed {
+		snippetRequested = true
 	}
+	settingFlags := changedSettingFlags()
 	args := pflag.Args()
 	if len(args) > 0 && args[0] == "version" {
 		fmt.Println(version)
 		return
 	}
+	if len(args) > 0 && args[0] == "config" {
+		if err := kubeexec.RunConfigCommand(args[1:], settingFlags, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(kubeexec.ExitCode(err))
+		}
+		return
+	}
+	if len(args) > 0 && args[0] == "completion" {
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, "error: completion: expected one shell (bash, zsh, fish or powershell)")
+			os.Exit(kubeexec.ExitUsage)
+		}
+		if err := kubeexec.WriteCompletionScript(args[1], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(kubeexec.ExitCode(err))
+		}
+		return
+	}
+	tuiMode := len(args) > 0 && args[0] == "tui"
+	if tuiMode {
+		args = args[1:]
+	}
 	if len(args) > 1 {
 		fmt.Fprintln(os.Stderr, "error: too many arguments")
 		pflag.Usage()
-		os.Exit(2)
+		os.Exit(kubeexec.ExitUsage)
 	}
 	if len(args) == 1 {
 		pod = args[0]
@@ -145,23 +238,76 @@ func main() {
 		return
 	}
 
-	if err := cmdutil.Run(cmdutil.RunOptions{
+	settings, err := kubeexec.ResolveSettings(settingFlags)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(kubeexec.ExitCode(err))
+	}
+	opts := kubeexec.RunOptions{
 		Context:          context,
-		Namespace:        namespace,
-		Container:        container,
-		Selector:         selector,
 		Pod:              pod,
 		Command:          commandArgs,
 		DryRun:           dryRun,
 		ContextRequested: contextRequested,
-		ConfirmContext:   confirmContextEnabled,
-		NonInteractive:   nonInteractiveEnabled,
-		IgnoreFzf:        ignoreFzfEnabled,
-		AllNamespaces:    allNamespaces,
-	}); err != nil {
-		fmt.Fprintln(os.Stderr, "error:", err)
-		os.Exit(1)
+		Script:           script,
+		Snippet:          snippet,
+		SnippetRequested: snippetRequested,
+		SnippetArgs:      snippetArgs,
+		Env:              envAssignments,
+		EnvFiles:         envFiles,
+		ForwardEnv:       forwardEnv,
+		YesIKnow:         yesIKnow,
 	}
+	settings.ApplyRunOptions(&opts)
+	if tuiMode {
+		if pod != "" || len(commandArgs) > 0 {
+			fmt.Fprintln(os.Stderr, "error: tui takes no pod or command")
+			os.Exit(kubeexec.ExitUsage)
+		}
+		if err := kubeexec.RunTUI(opts); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(kubeexec.ExitCode(err))
+		}
+		return
+	}
+	// Ctrl-C and SIGTERM cancel the run and are passed on to kubectl.
+	ctx, stop := kubeexec.SignalContext()
+	err = kubeexec.Run(ctx, opts)
+	stop()
+	if err != nil {
+		// The remote command reports its own failure; only pass its status on.
+		var remote *kubeexec.RemoteExitError
+		if !errors.As(err, &remote) {
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+		os.Exit(kubeexec.ExitCode(err))
+	}
+}
+
+// changedSettingFlags returns the raw values of the setting flags given on
+// the command line.
+func changedSettingFlags() map[string]string {
+	flags := make(map[string]string)
+	pflag.CommandLine.Visit(func(f *pflag.Flag) {
+		if kubeexec.IsSettingFlag(f.Name) {
+			flags[f.Name] = f.Value.String()
+		}
+	})
+	return flags
+}
+
+// completionFlags describes the command-line flags for shell completion.
+func completionFlags() []kubeexec.CompletionFlag {
+	var flags []kubeexec.CompletionFlag
+	pflag.CommandLine.VisitAll(func(f *pflag.Flag) {
+		flags = append(flags, kubeexec.CompletionFlag{
+			Name:       f.Name,
+			Shorthand:  f.Shorthand,
+			Usage:      f.Usage,
+			TakesValue: f.Value.Type() != "bool" && f.Value.Type() != "count",
+		})
+	})
+	return flags
 }
 
 func displayName() string {
@@ -175,15 +321,18 @@ func displayName() string {
 
 func normalizeContextArgs(args []string) []string {
 	normalized := make([]string, 0, len(args))
-	flagsAllowEmpty := map[string]struct{}{
-		"--context":   {},
-		"--container": {},
+	// Shorthands map to their long form: pflag reads "-s=" as the value "=".
+	flagsAllowEmpty := map[string]string{
+		"--context":   "--context",
+		"--container": "--container",
+		"--snippet":   "--snippet",
+		"-s":          "--snippet",
 	}
 	for i := 0; i < len(args); i++ {
 		arg := args[i]
-		if _, ok := flagsAllowEmpty[arg]; ok {
+		if long, ok := flagsAllowEmpty[arg]; ok {
 			if i+1 >= len(args) || strings.HasPrefix(args[i+1], "-") {
-				normalized = append(normalized, arg+"=")
+				normalized = append(normalized, long+"=")
 				continue
 			}
 		}
@@ -226,7 +375,7 @@ func (b *confirmBoolFlag) String() string {
 }
 
 func (b *confirmBoolFlag) Set(value string) error {
-	parsed, ok := cmdutil.ParseConfirmBool(value)
+	parsed, ok := kubeexec.ParseConfirmBool(value)
 	if !ok {
 		return fmt.Errorf("invalid value %q (use true/True/1/on/ON/false/False/0/off/OFF)", value)
 	}
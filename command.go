@@ -0,0 +1,83 @@
+package kubeexec
+
+import (
+	"context"
+	"io"
+
+	"github.com/estebanmilaho/kubeexec/internal/cmdutil"
+)
+
+type (
+	// RunOptions are the kubeexec command's options for Run and RunTUI.
+	RunOptions = cmdutil.RunOptions
+	// CompletionFlag describes a command-line flag to Complete.
+	CompletionFlag = cmdutil.CompletionFlag
+)
+
+// Exit codes for kubeexec's own failures, as returned by ExitCode.
+const (
+	ExitOK         = cmdutil.ExitOK
+	ExitFailure    = cmdutil.ExitFailure
+	ExitUsage      = cmdutil.ExitUsage
+	ExitResolution = cmdutil.ExitResolution
+	ExitAmbiguous  = cmdutil.ExitAmbiguous
+	ExitKubectl    = cmdutil.ExitKubectl
+	ExitDenied     = cmdutil.ExitDenied
+	ExitCancelled  = cmdutil.ExitCancelled
+)
+
+// Defaults for --wait and --reconnect given without a value.
+const (
+	DefaultWaitTimeout       = cmdutil.DefaultWaitTimeout
+	DefaultReconnectAttempts = cmdutil.DefaultReconnectAttempts
+)
+
+// Run resolves the target described by opts and execs into it, like the
+// kubeexec command.
+func Run(ctx context.Context, opts RunOptions) error {
+	return cmdutil.Run(ctx, opts)
+}
+
+// RunTUI opens the full-screen dashboard of `kubeexec tui`.
+func RunTUI(opts RunOptions) error {
+	return cmdutil.RunTUI(opts)
+}
+
+// RunConfigCommand runs `kubeexec config <view|validate|path|init>`. flags
+// holds the setting flags given on the command line, as for ResolveSettings.
+func RunConfigCommand(args []string, flags map[string]string, out io.Writer) error {
+	return cmdutil.RunConfigCommand(args, flags, out)
+}
+
+// SetConfigFile sets the config file given with --config. It takes
+// precedence over KUBEEXEC_CONFIG.
+func SetConfigFile(path string) {
+	cmdutil.SetConfigFile(path)
+}
+
+// ResolveSettings is LoadSettings with the setting flags given on the command
+// line, keyed by flag name, taking precedence over the environment and files.
+func ResolveSettings(flags map[string]string) (*Settings, error) {
+	return cmdutil.ResolveSettings(flags)
+}
+
+// IsSettingFlag reports whether the command-line flag name sets a setting.
+func IsSettingFlag(name string) bool {
+	return cmdutil.IsSettingFlag(name)
+}
+
+// ParseConfirmBool parses the value of --confirm-context.
+func ParseConfirmBool(value string) (bool, bool) {
+	return cmdutil.ParseConfirmBool(value)
+}
+
+// Complete writes the candidates for the last word of words, one per line,
+// optionally followed by a tab and a description, as `kubeexec __complete` does.
+func Complete(words []string, flags []CompletionFlag, out io.Writer) {
+	cmdutil.Complete(words, flags, out)
+}
+
+// WriteCompletionScript writes the completion script for shell.
+func WriteCompletionScript(shell string, out io.Writer) error {
+	return cmdutil.WriteCompletionScript(shell, out)
+}
@@ -1,16 +1,33 @@
+# bash completion for kubeexec; generated by `kubeexec completion bash`.
 _kubeexec() {
-	local cur prev
-	cur="${COMP_WORDS[COMP_CWORD]}"
-	prev="${COMP_WORDS[COMP_CWORD-1]}"
+	local line="${COMP_LINE:0:COMP_POINT}"
+	local -a words
+	read -ra words <<< "$line"
+	if [[ "$line" =~ [[:space:]]$ ]]; then
+		words+=("")
+	fi
+	local cur="${words[${#words[@]}-1]}"
+	local prev=""
+	if (( ${#words[@]} > 1 )); then
+		prev="${words[${#words[@]}-2]}"
+	fi
 
 	case "$prev" in
-		-n|--namespace|-c|--container|-l|--selector|--context)
+		--script|--env-from-file|--config|--trace-file)
+			COMPREPLY=($(compgen -f -- "$cur"))
 			return 0
 			;;
 	esac
 
-	local flags="--context --namespace --container --selector --dry-run --version --help -n -c -l -h -v"
-	COMPREPLY=($(compgen -W "${flags}" -- "$cur"))
+	local IFS=$'\n'
+	local -a candidates=($(kubeexec __complete "${words[@]:1}" 2>/dev/null))
+	candidates=("${candidates[@]%%$'\t'*}")
+	# bash replaces only the part of the word after the last = or :.
+	local done="${cur%"${cur##*[=:]}"}"
+	COMPREPLY=("${candidates[@]#"$done"}")
+	if [[ ${#COMPREPLY[@]} -eq 1 && ${COMPREPLY[0]} == *= ]]; then
+		compopt -o nospace 2>/dev/null
+	fi
 }
 
 complete -F _kubeexec kubeexec
@@ -1,7 +1,11 @@
-complete -c kubeexec -s h -l help -d "show this message"
-complete -c kubeexec -s v -l version -d "print version and exit"
-complete -c kubeexec -s n -l namespace -d "kubernetes namespace (defaults to current context/namespace)" -r
-complete -c kubeexec -s c -l container -d "container name (defaults to pod's default)" -r
-complete -c kubeexec -s l -l selector -d "label selector for pods (e.g. app=api)" -r
-complete -c kubeexec -l context -d "kubernetes context (overrides current context)" -r
-complete -c kubeexec -l dry-run -d "print the kubectl exec command and exit"
+# fish completion for kubeexec; generated by `kubeexec completion fish`.
+function __kubeexec_complete
+    set -l tokens (commandline -opc) (commandline -ct)
+    kubeexec __complete $tokens[2..-1] 2>/dev/null
+end
+
+complete -c kubeexec -f -a '(__kubeexec_complete)'
+complete -c kubeexec -l script -r -F
+complete -c kubeexec -l env-from-file -r -F
+complete -c kubeexec -l config -r -F
+complete -c kubeexec -l trace-file -r -F
@@ -0,0 +1,17 @@
+# PowerShell completion for kubeexec; generated by `kubeexec completion powershell`.
+Register-ArgumentCompleter -Native -CommandName kubeexec -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements |
+        Where-Object { $_.Extent.StartOffset -lt $cursorPosition } |
+        Select-Object -Skip 1 |
+        ForEach-Object { $_.ToString() })
+    if ($wordToComplete -eq '') {
+        # Windows PowerShell and PowerShell before 7.3 drop empty arguments.
+        if ($PSVersionTable.PSVersion -lt [version]'7.3.0') { $words += '""' } else { $words += '' }
+    }
+    & kubeexec __complete @words 2>$null | ForEach-Object {
+        $name, $description = $_ -split "`t", 2
+        if (-not $description) { $description = $name }
+        [System.Management.Automation.CompletionResult]::new($name, $name, 'ParameterValue', $description)
+    }
+}
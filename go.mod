@@ -1,4 +1,4 @@
-module kubeexec
+module github.com/estebanmilaho/kubeexec
 
 go 1.26
 
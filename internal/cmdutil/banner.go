@@ -0,0 +1,113 @@
+package cmdutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// bannerColors are the values of the banner-color setting and their ANSI
+// codes.
+var bannerColors = map[string]string{
+	"red":     "1;31",
+	"green":   "1;32",
+	"yellow":  "1;33",
+	"blue":    "1;34",
+	"magenta": "1;35",
+	"cyan":    "1;36",
+}
+
+// parseBannerColor validates a banner-color value; empty means no color.
+func parseBannerColor(value string) (string, error) {
+	color := strings.ToLower(strings.TrimSpace(value))
+	if color == "" {
+		return "", nil
+	}
+	if _, ok := bannerColors[color]; !ok {
+		names := make([]string, 0, len(bannerColors))
+		for name := range bannerColors {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "", usageErrorf("invalid banner-color %q (use %s)", color, strings.Join(names, ", "))
+	}
+	return color, nil
+}
+
+// writeBanner prints where the session is about to go: the context and its
+// cluster server, the namespace, pod and container, and the container's
+// image, the pod's node and its age. Details kubectl cannot provide are left
+// out rather than failing the exec. The banner is colored unless NO_COLOR is
+// set.
+func writeBanner(ctx context.Context, out io.Writer, target execTarget, color string, now time.Time) {
+	server, _ := ClusterServer(ctx, target.context)
+	var image, node, age string
+	if data, err := getPodJSON(ctx, target.context, target.namespace, target.pod); err == nil {
+		var pod podJSON
+		if json.Unmarshal(data, &pod) == nil {
+			node = pod.Spec.NodeName
+			age = formatAge(pod.Metadata.CreationTimestamp, now)
+		}
+		if containers, _, err := parsePodContainers(data); err == nil {
+			if container, ok := findContainer(containers, target.container); ok {
+				image = container.Image
+			}
+		}
+	}
+	lines := [][2]string{
+		{"context", target.context},
+		{"server", server},
+		{"namespace", target.namespace},
+		{"pod", target.pod},
+		{"container", target.container},
+		{"image", image},
+		{"node", node},
+		{"age", age},
+	}
+	code := bannerColors[color]
+	if os.Getenv("NO_COLOR") != "" {
+		code = ""
+	}
+	var b strings.Builder
+	fmt.Fprintln(&b, bannerLine(code, "*** kubeexec session ***"))
+	for _, line := range lines {
+		if line[1] != "" {
+			fmt.Fprintln(&b, bannerLine(code, fmt.Sprintf("%-10s %s", line[0]+":", line[1])))
+		}
+	}
+	io.WriteString(out, b.String())
+}
+
+func bannerLine(code, line string) string {
+	if code == "" {
+		return line
+	}
+	return "\x1b[" + code + "m" + line + "\x1b[0m"
+}
+
+// pushTerminalTitle saves the terminal title on the xterm title stack and
+// sets it to context/namespace/pod; popTerminalTitle restores the saved one.
+// Terminals without a title stack ignore the save and restore sequences.
+func pushTerminalTitle(out io.Writer, target execTarget) {
+	io.WriteString(out, "\x1b[22;0t")
+	setTerminalTitle(out, target)
+}
+
+func setTerminalTitle(out io.Writer, target execTarget) {
+	title := strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, target.context+"/"+target.namespace+"/"+target.pod)
+	io.WriteString(out, "\x1b]0;"+title+"\x07")
+}
+
+func popTerminalTitle(out io.Writer) {
+	io.WriteString(out, "\x1b[23;0t")
+}
@@ -0,0 +1,153 @@
+package cmdutil
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteBanner(t *testing.T) {
+	now := time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC)
+	plain := "*** kubeexec session ***\n" +
+		"context:   dev\n" +
+		"server:    https://dev.example.com:6443\n" +
+		"namespace: payments\n" +
+		"pod:       api-1\n" +
+		"container: app\n" +
+		"image:     example/app:1.0\n" +
+		"node:      node-a\n" +
+		"age:       3d\n"
+	tests := []struct {
+		name    string
+		target  execTarget
+		color   string
+		noColor string
+		want    string
+	}{
+		{
+			name:   "plain",
+			target: execTarget{context: "dev", namespace: "payments", pod: "api-1", container: "app"},
+			want:   plain,
+		},
+		{
+			name:   "colored",
+			target: execTarget{context: "dev", namespace: "payments", pod: "api-1", container: "app"},
+			color:  "red",
+			want:   "\x1b[1;31m*** kubeexec session ***\x1b[0m\n\x1b[1;31mcontext:   dev\x1b[0m\n",
+		},
+		{
+			name:    "NO_COLOR",
+			target:  execTarget{context: "dev", namespace: "payments", pod: "api-1", container: "app"},
+			color:   "red",
+			noColor: "1",
+			want:    plain,
+		},
+		{
+			name:   "unknown server and node",
+			target: execTarget{context: "staging", namespace: "payments", pod: "multi-1", container: "proxy"},
+			want: "*** kubeexec session ***\n" +
+				"context:   staging\n" +
+				"namespace: payments\n" +
+				"pod:       multi-1\n" +
+				"container: proxy\n" +
+				"image:     example/proxy:1.0\n" +
+				"age:       3d\n",
+		},
+		{
+			name:   "pod gone",
+			target: execTarget{context: "dev", namespace: "payments", pod: "gone-1", container: "app"},
+			want: "*** kubeexec session ***\n" +
+				"context:   dev\n" +
+				"server:    https://dev.example.com:6443\n" +
+				"namespace: payments\n" +
+				"pod:       gone-1\n" +
+				"container: app\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newFakeCluster(t, fakeRunCluster())
+			t.Setenv("NO_COLOR", tt.noColor)
+			var out bytes.Buffer
+			writeBanner(context.Background(), &out, tt.target, tt.color, now)
+			if !strings.HasPrefix(out.String(), tt.want) {
+				t.Errorf("writeBanner() = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBannerColor(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "", want: ""},
+		{value: "red", want: "red"},
+		{value: " Yellow ", want: "yellow"},
+		{value: "orange", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseBannerColor(tt.value)
+		if tt.wantErr {
+			if !IsKind(err, KindUsage) {
+				t.Errorf("parseBannerColor(%q) error = %v, want usage error", tt.value, err)
+			}
+		} else if err != nil || got != tt.want {
+			t.Errorf("parseBannerColor(%q) = %q, %v, want %q", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestTerminalTitle(t *testing.T) {
+	var out bytes.Buffer
+	pushTerminalTitle(&out, execTarget{context: "dev", namespace: "pay\x1bments", pod: "api-1"})
+	popTerminalTitle(&out)
+	if want := "\x1b[22;0t\x1b]0;dev/payments/api-1\x07\x1b[23;0t"; out.String() != want {
+		t.Errorf("terminal title sequences = %q, want %q", out.String(), want)
+	}
+}
+
+func TestRunBanner(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   RunOptions
+		config string
+		want   bool
+	}{
+		{name: "off", opts: RunOptions{Pod: "api-1"}},
+		{name: "on", opts: RunOptions{Pod: "api-1", Banner: true}, want: true},
+		{name: "dry run", opts: RunOptions{Pod: "api-1", Banner: true, DryRun: true}},
+		{name: "context section", opts: RunOptions{Pod: "api-1"}, config: "[context.\"dev\"]\nbanner = true\n", want: true},
+		{name: "terminal title without a terminal", opts: RunOptions{Pod: "api-1", TerminalTitle: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newFakeCluster(t, fakeRunCluster())
+			opts := tt.opts
+			if tt.config != "" {
+				h.writeConfig(t, tt.config)
+				settings, err := ResolveSettings(nil)
+				if err != nil {
+					t.Fatalf("ResolveSettings() error: %v", err)
+				}
+				opts.Settings = settings
+			}
+			opts.Picker = "none"
+			var stdout, stderr bytes.Buffer
+			opts.Stdout, opts.Stderr = &stdout, &stderr
+			if err := Run(context.Background(), opts); err != nil {
+				t.Fatalf("Run() error: %v", err)
+			}
+			if got := strings.Contains(stderr.String(), "pod:       api-1\n"); got != tt.want {
+				t.Errorf("Run() stderr = %q, want banner %t", stderr.String(), tt.want)
+			}
+			if strings.Contains(stderr.String(), "\x1b") {
+				t.Errorf("Run() stderr = %q, want no escape sequences off a terminal", stderr.String())
+			}
+		})
+	}
+}
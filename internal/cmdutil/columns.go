@@ -0,0 +1,234 @@
+package cmdutil
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	podSortName     = "name"
+	podSortNewest   = "newest"
+	podSortOldest   = "oldest"
+	podSortRestarts = "restarts"
+	podSortNode     = "node"
+
+	labelColumnPrefix      = "label:"
+	annotationColumnPrefix = "annotation:"
+)
+
+var (
+	defaultPodColumns = []string{"name", "ready", "status"}
+	podSortOrders     = []string{podSortName, podSortNewest, podSortOldest, podSortRestarts, podSortNode}
+)
+
+type podColumn struct {
+	name  string
+	value func(PodItem) string
+}
+
+var podColumnValues = map[string]func(PodItem) string{
+	"namespace": func(p PodItem) string { return p.Namespace },
+	"name":      func(p PodItem) string { return p.Name },
+	"ready":     func(p PodItem) string { return p.Ready },
+	"status":    func(p PodItem) string { return p.Status },
+	"restarts":  func(p PodItem) string { return strconv.Itoa(p.Restarts) },
+	"age":       func(p PodItem) string { return formatAge(p.Created, time.Now()) },
+	"node":      func(p PodItem) string { return p.Node },
+	"ip":        func(p PodItem) string { return p.IP },
+	"owner": func(p PodItem) string {
+		if p.OwnerKind == "" {
+			return ""
+		}
+		return p.OwnerKind + "/" + p.OwnerName
+	},
+	"image": func(p PodItem) string { return p.Image },
+}
+
+// podLayout describes how pods are rendered in the picker. watch keeps the
+// list current while the picker is open.
+type podLayout struct {
+	columns []podColumn
+	sort    string
+	colors  bool
+	watch   bool
+}
+
+// podLayoutFromSettings reads picker-columns, picker-sort, picker-colors and
+// picker-watch. The name column is always shown, and the namespace column is
+// added in all-namespaces mode so entries stay distinguishable.
+func podLayoutFromSettings(settings *Settings, allNamespaces bool) (podLayout, error) {
+	names := settings.Strings("picker-columns")
+	if len(names) == 0 {
+		names = defaultPodColumns
+	}
+	columns, err := parsePodColumns(names, allNamespaces)
+	if err != nil {
+		return podLayout{}, err
+	}
+	order := strings.TrimSpace(settings.String("picker-sort"))
+	if order == "" {
+		order = podSortName
+	}
+	if !contains(podSortOrders, order) {
+		return podLayout{}, usageErrorf("invalid picker-sort %q (use %s)", order, strings.Join(podSortOrders, ", "))
+	}
+	colors := os.Getenv("NO_COLOR") == ""
+	if settings.IsSet("picker-colors") {
+		colors = settings.Bool("picker-colors")
+	}
+	return podLayout{columns: columns, sort: order, colors: colors, watch: settings.Bool("picker-watch")}, nil
+}
+
+func parsePodColumns(names []string, allNamespaces bool) ([]podColumn, error) {
+	var columns []podColumn
+	seen := make(map[string]bool)
+	for _, raw := range names {
+		name := strings.TrimSpace(raw)
+		if name == "" || seen[name] {
+			continue
+		}
+		column, err := newPodColumn(name)
+		if err != nil {
+			return nil, err
+		}
+		seen[name] = true
+		columns = append(columns, column)
+	}
+	if !seen["name"] {
+		columns = append([]podColumn{{name: "name", value: podColumnValues["name"]}}, columns...)
+	}
+	if allNamespaces && !seen["namespace"] {
+		columns = append([]podColumn{{name: "namespace", value: podColumnValues["namespace"]}}, columns...)
+	}
+	return columns, nil
+}
+
+func newPodColumn(name string) (podColumn, error) {
+	if key, ok := strings.CutPrefix(name, labelColumnPrefix); ok && key != "" {
+		return podColumn{name: name, value: func(p PodItem) string { return p.Labels[key] }}, nil
+	}
+	if key, ok := strings.CutPrefix(name, annotationColumnPrefix); ok && key != "" {
+		return podColumn{name: name, value: func(p PodItem) string { return p.Annotations[key] }}, nil
+	}
+	value, ok := podColumnValues[name]
+	if !ok {
+		known := make([]string, 0, len(podColumnValues))
+		for k := range podColumnValues {
+			known = append(known, k)
+		}
+		sort.Strings(known)
+		return podColumn{}, usageErrorf("invalid picker column %q (use %s, label:<key> or annotation:<key>)", name, strings.Join(known, ", "))
+	}
+	return podColumn{name: name, value: value}, nil
+}
+
+// sortPods orders pods in place. The name order keeps the API server order,
+// which is already sorted by namespace and name.
+func sortPods(pods []PodItem, order string) {
+	switch order {
+	case podSortNewest:
+		sort.SliceStable(pods, func(i, j int) bool { return pods[i].Created.After(pods[j].Created) })
+	case podSortOldest:
+		sort.SliceStable(pods, func(i, j int) bool { return pods[i].Created.Before(pods[j].Created) })
+	case podSortRestarts:
+		sort.SliceStable(pods, func(i, j int) bool { return pods[i].Restarts > pods[j].Restarts })
+	case podSortNode:
+		sort.SliceStable(pods, func(i, j int) bool { return pods[i].Node < pods[j].Node })
+	}
+}
+
+// formatPodDisplays sorts pods and fills Display with the layout's columns.
+func formatPodDisplays(pods []PodItem, layout podLayout) {
+	sortPods(pods, layout.sort)
+	fillPodDisplays(pods, layout)
+}
+
+// fillPodDisplays fills Display with the layout's columns, keeping the order.
+// Cells are padded before coloring so escape codes don't skew alignment.
+func fillPodDisplays(pods []PodItem, layout podLayout) {
+	rows := make([][]string, len(pods))
+	widths := make([]int, len(layout.columns))
+	for i, pod := range pods {
+		rows[i] = make([]string, len(layout.columns))
+		for j, column := range layout.columns {
+			cell := column.value(pod)
+			if cell == "" {
+				cell = "-"
+			}
+			rows[i][j] = cell
+			if len(cell) > widths[j] {
+				widths[j] = len(cell)
+			}
+		}
+	}
+	for i := range pods {
+		cells := make([]string, len(layout.columns))
+		for j, column := range layout.columns {
+			cell := rows[i][j]
+			if j < len(layout.columns)-1 {
+				cell = fmt.Sprintf("%-*s", widths[j], cell)
+			}
+			if layout.colors && column.name == "status" {
+				cell = colorStatus(cell, pods[i])
+			}
+			cells[j] = cell
+		}
+		pods[i].Display = strings.Join(cells, "  ")
+	}
+}
+
+func colorStatus(cell string, pod PodItem) string {
+	code := ""
+	switch pod.Status {
+	case "Running":
+		code = "32"
+		if ready, total, ok := strings.Cut(pod.Ready, "/"); ok && ready != total {
+			code = "33"
+		}
+	case "Pending", "ContainerCreating", "PodInitializing":
+		code = "33"
+	case "Succeeded", "Completed":
+		code = "34"
+	case "Terminating":
+		code = "35"
+	case podStatusDeleted:
+		code = "90"
+	default:
+		code = "31"
+	}
+	return "\x1b[" + code + "m" + cell + "\x1b[0m"
+}
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// stripANSI removes the status colors, for pickers that do not render them.
+func stripANSI(value string) string {
+	return ansiEscape.ReplaceAllString(value, "")
+}
+
+// formatAge renders a duration the way kubectl does, e.g. 45s, 12m, 3h, 5d.
+func formatAge(created, now time.Time) string {
+	if created.IsZero() {
+		return ""
+	}
+	d := now.Sub(created)
+	switch {
+	case d < 0:
+		return "0s"
+	case d < 2*time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < 2*time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	case d < 365*24*time.Hour:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	default:
+		return fmt.Sprintf("%dy", int(d.Hours()/24/365))
+	}
+}
@@ -0,0 +1,161 @@
+package cmdutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePodColumns(t *testing.T) {
+	tests := []struct {
+		name          string
+		columns       []string
+		allNamespaces bool
+		want          []string
+		wantErr       bool
+	}{
+		{"defaults", defaultPodColumns, false, []string{"name", "ready", "status"}, false},
+		{"all namespaces adds namespace", defaultPodColumns, true, []string{"namespace", "name", "ready", "status"}, false},
+		{"name always shown", []string{"age", "node"}, false, []string{"name", "age", "node"}, false},
+		{"labels and annotations", []string{"name", "label:app", "annotation:team"}, false, []string{"name", "label:app", "annotation:team"}, false},
+		{"duplicates and blanks dropped", []string{"name", " ", "name", "ip"}, false, []string{"name", "ip"}, false},
+		{"unknown column", []string{"name", "cpu"}, false, nil, true},
+		{"empty label key", []string{"label:"}, false, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns, err := parsePodColumns(tt.columns, tt.allNamespaces)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePodColumns(%v) error = %v, wantErr %v", tt.columns, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			var got []string
+			for _, c := range columns {
+				got = append(got, c.name)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("parsePodColumns(%v) = %v, want %v", tt.columns, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatPodDisplays(t *testing.T) {
+	now := time.Now()
+	pods := []PodItem{
+		{Name: "api-1", Ready: "1/1", Status: "Running", Restarts: 0, Created: now.Add(-3 * time.Hour), Labels: map[string]string{"app": "api"}},
+		{Name: "api-22", Ready: "0/1", Status: "CrashLoopBackOff", Restarts: 12, Created: now.Add(-10 * time.Minute)},
+	}
+	columns, err := parsePodColumns([]string{"name", "status", "restarts", "label:app"}, false)
+	if err != nil {
+		t.Fatalf("parsePodColumns error: %v", err)
+	}
+	formatPodDisplays(pods, podLayout{columns: columns, sort: podSortRestarts, colors: true})
+	if pods[0].Name != "api-22" {
+		t.Fatalf("expected most restarts first, got %q", pods[0].Name)
+	}
+	if !strings.Contains(pods[0].Display, "\x1b[31mCrashLoopBackOff\x1b[0m") {
+		t.Errorf("expected red status, got %q", pods[0].Display)
+	}
+	plain0 := stripANSI(pods[0].Display)
+	plain1 := stripANSI(pods[1].Display)
+	if plain0 != "api-22  CrashLoopBackOff  12  -" {
+		t.Errorf("unexpected display %q", plain0)
+	}
+	if plain1 != "api-1   Running           0   api" {
+		t.Errorf("unexpected display %q", plain1)
+	}
+}
+
+func TestSortPods(t *testing.T) {
+	now := time.Now()
+	base := []PodItem{
+		{Name: "a", Node: "node-b", Restarts: 1, Created: now.Add(-2 * time.Hour)},
+		{Name: "b", Node: "node-a", Restarts: 5, Created: now.Add(-1 * time.Hour)},
+		{Name: "c", Node: "node-c", Restarts: 0, Created: now.Add(-3 * time.Hour)},
+	}
+	tests := []struct {
+		order string
+		want  string
+	}{
+		{podSortName, "abc"},
+		{podSortNewest, "bac"},
+		{podSortOldest, "cab"},
+		{podSortRestarts, "bac"},
+		{podSortNode, "bac"},
+	}
+	for _, tt := range tests {
+		pods := append([]PodItem(nil), base...)
+		sortPods(pods, tt.order)
+		got := ""
+		for _, p := range pods {
+			got += p.Name
+		}
+		if got != tt.want {
+			t.Errorf("sortPods(%q) = %q, want %q", tt.order, got, tt.want)
+		}
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		ago  time.Duration
+		want string
+	}{
+		{45 * time.Second, "45s"},
+		{12 * time.Minute, "12m"},
+		{3 * time.Hour, "3h"},
+		{5 * 24 * time.Hour, "5d"},
+		{400 * 24 * time.Hour, "1y"},
+	}
+	for _, tt := range tests {
+		if got := formatAge(now.Add(-tt.ago), now); got != tt.want {
+			t.Errorf("formatAge(-%s) = %q, want %q", tt.ago, got, tt.want)
+		}
+	}
+	if got := formatAge(time.Time{}, now); got != "" {
+		t.Errorf("formatAge(zero) = %q, want empty", got)
+	}
+}
+
+func TestPodLayoutFromSettings(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	path := filepath.Join(dir, ".config", "kubeexec", "kubeexec.toml")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	config := "picker-columns = [\"name\", \"age\", \"node\"]\npicker-sort = \"newest\"\npicker-colors = false\n"
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	settings, err := ResolveSettings(nil)
+	if err != nil {
+		t.Fatalf("ResolveSettings() error: %v", err)
+	}
+	layout, err := podLayoutFromSettings(settings, true)
+	if err != nil {
+		t.Fatalf("podLayoutFromSettings error: %v", err)
+	}
+	if len(layout.columns) != 4 || layout.columns[0].name != "namespace" {
+		t.Errorf("unexpected columns: %+v", layout.columns)
+	}
+	if layout.sort != podSortNewest || layout.colors {
+		t.Errorf("unexpected layout: sort=%q colors=%v", layout.sort, layout.colors)
+	}
+
+	if err := os.WriteFile(path, []byte("picker-sort = \"random\"\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if settings, err = ResolveSettings(nil); err != nil {
+		t.Fatalf("ResolveSettings() error: %v", err)
+	}
+	if _, err := podLayoutFromSettings(settings, false); err == nil {
+		t.Fatal("expected error for invalid picker-sort")
+	}
+}
@@ -0,0 +1,409 @@
+package cmdutil
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// completionTimeout bounds each kubectl call made while completing, so a
+	// slow or unreachable cluster never hangs the shell.
+	completionTimeout = 2 * time.Second
+	// completionCacheTTL is how long discovered names are reused between
+	// completions.
+	completionCacheTTL = 30 * time.Second
+)
+
+var (
+	completionSubcommands = []string{"config", "completion", "tui", "version"}
+	configSubcommands     = []string{"view", "validate", "path", "init"}
+	completionShells      = []string{"bash", "zsh", "fish", "powershell"}
+	pickerNames           = []string{pickerFzf, pickerSkim, pickerBuiltin, pickerNone}
+)
+
+// CompletionFlag describes a CLI flag for completion. Flags that take a value
+// consume the next word unless it starts with "-".
+type CompletionFlag struct {
+	Name       string
+	Shorthand  string
+	Usage      string
+	TakesValue bool
+}
+
+// completionState is what the words before the cursor select.
+type completionState struct {
+	context       string
+	namespace     string
+	allNamespaces bool
+	selector      string
+	positionals   []string
+	pending       string
+	afterDashDash bool
+}
+
+// Complete writes the candidates for the last word of words, one per line,
+// optionally followed by a tab and a description. It backs the hidden
+// `kubeexec __complete` command used by the generated shell scripts and never
+// fails: anything that cannot be discovered is left out.
+func Complete(words []string, flags []CompletionFlag, out io.Writer) {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	ctx := withKubectlTimeouts(context.Background(), completionTimeout, completionTimeout)
+	state := parseCompletionWords(words[:len(words)-1], flags)
+	for _, candidate := range completionCandidates(ctx, state, words[len(words)-1], flags) {
+		fmt.Fprintln(out, candidate)
+	}
+}
+
+func parseCompletionWords(words []string, flags []CompletionFlag) completionState {
+	var state completionState
+	for _, word := range words {
+		switch {
+		case state.afterDashDash:
+		case word == "--":
+			state.afterDashDash = true
+			state.pending = ""
+		case strings.HasPrefix(word, "--"):
+			state.pending = ""
+			name, value, hasValue := strings.Cut(strings.TrimPrefix(word, "--"), "=")
+			flag, ok := lookupCompletionFlag(flags, name, "")
+			if !ok {
+				continue
+			}
+			if hasValue || !flag.TakesValue {
+				state.setFlag(flag, value, hasValue)
+			} else {
+				state.pending = flag.Name
+			}
+		case strings.HasPrefix(word, "-") && len(word) > 1:
+			state.pending = ""
+			shorthands := strings.TrimPrefix(word, "-")
+			for i, r := range shorthands {
+				flag, ok := lookupCompletionFlag(flags, "", string(r))
+				if !ok {
+					break
+				}
+				if !flag.TakesValue {
+					state.setFlag(flag, "", false)
+					continue
+				}
+				if rest := strings.TrimPrefix(shorthands[i+len(string(r)):], "="); rest != "" {
+					state.setFlag(flag, rest, true)
+				} else {
+					state.pending = flag.Name
+				}
+				break
+			}
+		case state.pending != "":
+			flag, _ := lookupCompletionFlag(flags, state.pending, "")
+			state.setFlag(flag, word, true)
+			state.pending = ""
+		default:
+			state.positionals = append(state.positionals, word)
+		}
+	}
+	return state
+}
+
+func (s *completionState) setFlag(flag CompletionFlag, value string, hasValue bool) {
+	switch flag.Name {
+	case "context":
+		s.context = value
+	case "namespace":
+		s.namespace = value
+	case "all-namespaces":
+		s.allNamespaces = true
+		if hasValue {
+			s.allNamespaces, _ = ParseConfirmBool(value)
+		}
+	case "selector":
+		s.selector = value
+	case "config":
+		SetConfigFile(value)
+	}
+}
+
+func lookupCompletionFlag(flags []CompletionFlag, name, shorthand string) (CompletionFlag, bool) {
+	for _, flag := range flags {
+		if (name != "" && flag.Name == name) || (shorthand != "" && flag.Shorthand == shorthand) {
+			return flag, true
+		}
+	}
+	return CompletionFlag{}, false
+}
+
+func completionCandidates(ctx context.Context, state completionState, current string, flags []CompletionFlag) []string {
+	if state.afterDashDash {
+		return nil
+	}
+	if state.pending != "" {
+		return withPrefix(flagValueCandidates(ctx, state, state.pending, current), current, "")
+	}
+	if strings.HasPrefix(current, "--") {
+		if name, value, ok := strings.Cut(strings.TrimPrefix(current, "--"), "="); ok {
+			return withPrefix(flagValueCandidates(ctx, state, name, value), value, "--"+name+"=")
+		}
+	}
+	if strings.HasPrefix(current, "-") {
+		return flagCandidates(flags, current)
+	}
+	switch {
+	case len(state.positionals) == 0:
+		var candidates []string
+		for _, sub := range completionSubcommands {
+			if strings.HasPrefix(sub, current) {
+				candidates = append(candidates, sub+"\tsubcommand")
+			}
+		}
+		return append(candidates, withPrefix(podCandidates(ctx, state), current, "")...)
+	case len(state.positionals) == 1 && state.positionals[0] == "config":
+		return withPrefix(configSubcommands, current, "")
+	case len(state.positionals) == 1 && state.positionals[0] == "completion":
+		return withPrefix(completionShells, current, "")
+	}
+	return nil
+}
+
+// withPrefix keeps the candidates that start with current and prepends
+// prefix, e.g. "--context=" for a flag value in the same word.
+func withPrefix(candidates []string, current, prefix string) []string {
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, current) {
+			matches = append(matches, prefix+candidate)
+		}
+	}
+	return matches
+}
+
+func flagCandidates(flags []CompletionFlag, current string) []string {
+	var candidates []string
+	for _, flag := range flags {
+		description := flag.Usage
+		if i := strings.Index(description, " ("); i > 0 {
+			description = description[:i]
+		}
+		if long := "--" + flag.Name; strings.HasPrefix(long, current) {
+			candidates = append(candidates, long+"\t"+description)
+		}
+		if flag.Shorthand != "" && current == "-" {
+			candidates = append(candidates, "-"+flag.Shorthand+"\t"+description)
+		}
+	}
+	return candidates
+}
+
+func flagValueCandidates(ctx context.Context, state completionState, name, current string) []string {
+	switch name {
+	case "context":
+		contexts, _ := GetContexts(ctx)
+		return contexts
+	case "namespace":
+		kubeContext := completionContext(ctx, state)
+		return cachedCompletion("namespaces", []string{kubeContext}, func() ([]string, error) {
+			return GetNamespaces(ctx, kubeContext)
+		})
+	case "container":
+		return containerCandidates(ctx, state)
+	case "selector":
+		return selectorCandidates(ctx, state, current)
+	case "phase":
+		return podPhases
+	case "picker":
+		return pickerNames
+	case "confirm-style":
+		return confirmStyles
+	case "snippet":
+		settings, err := loadSettings()
+		if err != nil {
+			return nil
+		}
+		return settings.snippetNames()
+	}
+	return nil
+}
+
+// completionContext is the context named with --context, resolved like Run
+// does for an exact or unique partial name, or else kubectl's current context,
+// so that the cache keys change with kubectl config use-context. It is "" only
+// when there is no current context.
+func completionContext(ctx context.Context, state completionState) string {
+	if state.context == "" {
+		current, _ := CurrentContext(ctx)
+		return current
+	}
+	contexts, err := GetContexts(ctx)
+	if err != nil || contains(contexts, state.context) {
+		return state.context
+	}
+	if matches := filterByQuery(contexts, state.context); len(matches) == 1 {
+		return matches[0]
+	}
+	return state.context
+}
+
+// completionNamespace is the namespace pods are listed in: -n, then the
+// namespace setting, then the context namespace ("" lets kubectl decide).
+func completionNamespace(state completionState) string {
+	if state.allNamespaces || state.namespace != "" {
+		return state.namespace
+	}
+	if settings, err := ResolveSettings(nil); err == nil {
+		return settings.String("namespace")
+	}
+	return ""
+}
+
+// podCandidates lists pod names, or namespace/pod in -A mode.
+func podCandidates(ctx context.Context, state completionState) []string {
+	kubeContext, namespace := completionContext(ctx, state), completionNamespace(state)
+	key := []string{kubeContext, namespace, fmt.Sprint(state.allNamespaces), state.selector}
+	return cachedCompletion("pods", key, func() ([]string, error) {
+		pods, err := GetPods(ctx, kubeContext, namespace, PodQuery{Selector: state.selector}, state.allNamespaces)
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(pods))
+		for _, pod := range pods {
+			if state.allNamespaces {
+				names = append(names, pod.Namespace+"/"+pod.Name)
+			} else {
+				names = append(names, pod.Name)
+			}
+		}
+		return names, nil
+	})
+}
+
+// containerCandidates lists the containers of the pod already typed. Sidecar,
+// init and ephemeral containers are described by their kind.
+func containerCandidates(ctx context.Context, state completionState) []string {
+	if len(state.positionals) == 0 {
+		return nil
+	}
+	kubeContext, namespace, pod := completionContext(ctx, state), completionNamespace(state), state.positionals[0]
+	if ns, name, ok := splitPodNamespaceArg(pod); ok && state.allNamespaces {
+		namespace, pod = ns, name
+	}
+	return cachedCompletion("containers", []string{kubeContext, namespace, pod}, func() ([]string, error) {
+		containers, _, err := GetPodContainers(ctx, kubeContext, namespace, pod)
+		if err != nil {
+			return nil, err
+		}
+		candidates := make([]string, 0, len(containers))
+		for _, container := range containers {
+			if container.Kind == ContainerKindRegular {
+				candidates = append(candidates, container.Name)
+			} else {
+				candidates = append(candidates, container.Name+"\t"+string(container.Kind))
+			}
+		}
+		return candidates, nil
+	})
+}
+
+// selectorCandidates completes the last term of a comma-separated label
+// selector: label keys as "key=", then "key=value" once a key is typed.
+func selectorCandidates(ctx context.Context, state completionState, current string) []string {
+	kubeContext, namespace := completionContext(ctx, state), completionNamespace(state)
+	key := []string{kubeContext, namespace, fmt.Sprint(state.allNamespaces)}
+	labels := cachedCompletion("labels", key, func() ([]string, error) {
+		pods, err := GetPods(ctx, kubeContext, namespace, PodQuery{}, state.allNamespaces)
+		if err != nil {
+			return nil, err
+		}
+		return podLabelPairs(pods), nil
+	})
+	return labelCandidates(labels, current)
+}
+
+// podLabelPairs returns the distinct key=value labels of pods, sorted.
+func podLabelPairs(pods []PodItem) []string {
+	seen := make(map[string]bool)
+	var pairs []string
+	for _, pod := range pods {
+		for key, value := range pod.Labels {
+			pair := key + "=" + value
+			if !seen[pair] {
+				seen[pair] = true
+				pairs = append(pairs, pair)
+			}
+		}
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+func labelCandidates(pairs []string, current string) []string {
+	done := ""
+	if i := strings.LastIndex(current, ","); i >= 0 {
+		done = current[:i+1]
+	}
+	term := strings.TrimPrefix(current, done)
+	seen := make(map[string]bool)
+	var candidates []string
+	for _, pair := range pairs {
+		candidate := pair
+		if !strings.Contains(term, "=") {
+			key, _, _ := strings.Cut(pair, "=")
+			candidate = key + "="
+		}
+		if !seen[candidate] {
+			seen[candidate] = true
+			candidates = append(candidates, done+candidate)
+		}
+	}
+	return candidates
+}
+
+type completionCacheEntry struct {
+	Time  time.Time `json:"time"`
+	Items []string  `json:"items"`
+}
+
+// cachedCompletion returns the items cached for kind and key when they are
+// fresh, and otherwise loads and caches them. The cache lives under the user
+// cache dir (e.g. ~/.cache/kubeexec/completion) and is keyed on KUBECONFIG
+// too, so switching kubeconfigs never shows stale names.
+func cachedCompletion(kind string, key []string, load func() ([]string, error)) []string {
+	path := completionCachePath(kind, key)
+	if path != "" {
+		if data, err := os.ReadFile(path); err == nil {
+			var entry completionCacheEntry
+			if json.Unmarshal(data, &entry) == nil && time.Since(entry.Time) < completionCacheTTL {
+				return entry.Items
+			}
+		}
+	}
+	items, err := load()
+	if err != nil {
+		return nil
+	}
+	if path != "" {
+		if data, err := json.Marshal(completionCacheEntry{Time: time.Now(), Items: items}); err == nil {
+			if os.MkdirAll(filepath.Dir(path), 0o700) == nil {
+				_ = os.WriteFile(path, data, 0o600)
+			}
+		}
+	}
+	return items
+}
+
+func completionCachePath(kind string, key []string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(strings.Join(append([]string{os.Getenv("KUBECONFIG")}, key...), "\x00")))
+	return filepath.Join(dir, "kubeexec", "completion", kind+"-"+hex.EncodeToString(sum[:8])+".json")
+}
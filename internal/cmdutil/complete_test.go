@@ -0,0 +1,191 @@
+package cmdutil
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var testCompletionFlags = []CompletionFlag{
+	{Name: "context", TakesValue: true},
+	{Name: "namespace", Shorthand: "n", Usage: "kubernetes namespace (defaults to current context/namespace)", TakesValue: true},
+	{Name: "all-namespaces", Shorthand: "A", Usage: "list pods across all namespaces"},
+	{Name: "container", Shorthand: "c", TakesValue: true},
+	{Name: "selector", Shorthand: "l", TakesValue: true},
+	{Name: "phase", TakesValue: true},
+	{Name: "picker", TakesValue: true},
+	{Name: "dry-run", Usage: "print kubectl command without executing"},
+}
+
+func TestParseCompletionWords(t *testing.T) {
+	tests := []struct {
+		name  string
+		words []string
+		want  completionState
+	}{
+		{name: "long flags", words: []string{"--context", "prod", "--namespace=payments", "api-1"}, want: completionState{context: "prod", namespace: "payments", positionals: []string{"api-1"}}},
+		{name: "shorthands", words: []string{"-A", "-lapp=api", "ns/api-1", "-c"}, want: completionState{allNamespaces: true, selector: "app=api", positionals: []string{"ns/api-1"}, pending: "container"}},
+		{name: "combined shorthands", words: []string{"-An", "kube-system"}, want: completionState{allNamespaces: true, namespace: "kube-system"}},
+		{name: "bool with value", words: []string{"--all-namespaces=false", "--dry-run"}, want: completionState{}},
+		{name: "after dash dash", words: []string{"api-1", "--", "-n"}, want: completionState{positionals: []string{"api-1"}, afterDashDash: true}},
+		{name: "unknown flag", words: []string{"--unknown", "api-1"}, want: completionState{positionals: []string{"api-1"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseCompletionWords(tt.words, testCompletionFlags); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCompletionWords(%q) = %+v, want %+v", tt.words, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompletionCandidates(t *testing.T) {
+	tests := []struct {
+		name  string
+		words []string
+		want  []string
+	}{
+		{name: "long flags", words: []string{"--a"}, want: []string{"--all-namespaces\tlist pods across all namespaces"}},
+		{name: "flags and shorthands", words: []string{"-"}, want: []string{
+			"--context\t",
+			"--namespace\tkubernetes namespace", "-n\tkubernetes namespace",
+			"--all-namespaces\tlist pods across all namespaces", "-A\tlist pods across all namespaces",
+			"--container\t", "-c\t",
+			"--selector\t", "-l\t",
+			"--phase\t",
+			"--picker\t",
+			"--dry-run\tprint kubectl command without executing",
+		}},
+		{name: "phase value", words: []string{"--phase", "R"}, want: []string{"Running"}},
+		{name: "phase value in the same word", words: []string{"--phase=S"}, want: []string{"--phase=Succeeded"}},
+		{name: "picker value", words: []string{"--picker", ""}, want: []string{"fzf", "skim", "builtin", "none"}},
+		{name: "config subcommand", words: []string{"config", "v"}, want: []string{"view", "validate"}},
+		{name: "completion shells", words: []string{"completion", ""}, want: []string{"bash", "zsh", "fish", "powershell"}},
+		{name: "remote command", words: []string{"api-1", "--", ""}, want: nil},
+		{name: "second positional", words: []string{"api-1", ""}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			Complete(tt.words, testCompletionFlags, &out)
+			var want bytes.Buffer
+			for _, line := range tt.want {
+				want.WriteString(line + "\n")
+			}
+			if out.String() != want.String() {
+				t.Errorf("Complete(%q) =\n%s\nwant:\n%s", tt.words, out.String(), want.String())
+			}
+		})
+	}
+}
+
+func TestLabelCandidates(t *testing.T) {
+	pairs := podLabelPairs([]PodItem{
+		{Labels: map[string]string{"app": "api", "tier": "web"}},
+		{Labels: map[string]string{"app": "worker", "tier": "web"}},
+	})
+	if want := []string{"app=api", "app=worker", "tier=web"}; !reflect.DeepEqual(pairs, want) {
+		t.Fatalf("podLabelPairs() = %v, want %v", pairs, want)
+	}
+	tests := []struct {
+		current string
+		want    []string
+	}{
+		{current: "", want: []string{"app=", "tier="}},
+		{current: "app=", want: []string{"app=api", "app=worker"}},
+		{current: "app=api,t", want: []string{"app=api,tier="}},
+		{current: "app=api,tier=", want: []string{"app=api,tier=web"}},
+	}
+	for _, tt := range tests {
+		if got := withPrefix(labelCandidates(pairs, tt.current), tt.current, ""); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("labelCandidates(%q) = %v, want %v", tt.current, got, tt.want)
+		}
+	}
+}
+
+func TestCachedCompletion(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("KUBECONFIG", "/tmp/a")
+	loads := 0
+	load := func() ([]string, error) {
+		loads++
+		return []string{"payments", "default"}, nil
+	}
+	for i := 0; i < 2; i++ {
+		if got := cachedCompletion("namespaces", []string{"prod"}, load); !reflect.DeepEqual(got, []string{"payments", "default"}) {
+			t.Fatalf("cachedCompletion() = %v", got)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("load called %d times, want 1 (second call cached)", loads)
+	}
+	cachedCompletion("namespaces", []string{"dev"}, load)
+	t.Setenv("KUBECONFIG", "/tmp/b")
+	cachedCompletion("namespaces", []string{"prod"}, load)
+	if loads != 3 {
+		t.Errorf("load called %d times, want 3 (context and KUBECONFIG are part of the key)", loads)
+	}
+}
+
+func TestCompletionFollowsCurrentContext(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	cluster := fakeCluster{Contexts: []fakeContext{
+		{Name: "dev", Pods: []fakePod{{Namespace: "payments", Name: "api-dev"}}},
+		{Name: "prod", Pods: []fakePod{{Namespace: "payments", Name: "api-prod"}}},
+	}}
+	for _, current := range []string{"dev", "prod"} {
+		cluster.CurrentContext = current
+		newFakeCluster(t, cluster)
+		var out bytes.Buffer
+		Complete([]string{"-n", "payments", "api"}, testCompletionFlags, &out)
+		if want := "api-" + current + "\n"; out.String() != want {
+			t.Errorf("pods completed with current context %s = %q, want %q", current, out.String(), want)
+		}
+	}
+}
+
+func TestCompletionContainerKinds(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	newFakeCluster(t, fakeCluster{CurrentContext: "dev", Contexts: []fakeContext{{Name: "dev", Pods: []fakePod{{
+		Namespace: "payments",
+		Name:      "api-1",
+		Containers: []fakeContainer{
+			{Name: "app"},
+			{Name: "istio-proxy", Kind: ContainerKindSidecar},
+			{Name: "migrate", Kind: ContainerKindInit},
+			{Name: "debugger", Kind: ContainerKindEphemeral},
+		},
+	}}}}})
+	var out bytes.Buffer
+	Complete([]string{"-n", "payments", "api-1", "-c", ""}, testCompletionFlags, &out)
+	if want := "app\nistio-proxy\tsidecar\nmigrate\tinit\ndebugger\tephemeral\n"; out.String() != want {
+		t.Errorf("container candidates = %q, want %q", out.String(), want)
+	}
+}
+
+func TestCompletionScriptsUpToDate(t *testing.T) {
+	files := map[string]string{
+		"bash":       "kubeexec.bash",
+		"zsh":        "_kubeexec",
+		"fish":       "kubeexec.fish",
+		"powershell": "kubeexec.ps1",
+	}
+	for shell, file := range files {
+		var out bytes.Buffer
+		if err := WriteCompletionScript(shell, &out); err != nil {
+			t.Fatalf("WriteCompletionScript(%s) error: %v", shell, err)
+		}
+		checkedIn, err := os.ReadFile(filepath.Join("..", "..", "completion", file))
+		if err != nil {
+			t.Fatalf("read completion/%s: %v", file, err)
+		}
+		if string(checkedIn) != out.String() {
+			t.Errorf("completion/%s is out of date; regenerate it with `kubeexec completion %s`", file, shell)
+		}
+	}
+	if err := WriteCompletionScript("tcsh", &bytes.Buffer{}); !IsKind(err, KindUsage) {
+		t.Errorf("WriteCompletionScript(tcsh) error = %v, want usage error", err)
+	}
+}
@@ -0,0 +1,125 @@
+package cmdutil
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Completion scripts call `kubeexec __complete <words...>` with the words up
+// to the cursor and only handle the file-valued flags themselves.
+
+const bashCompletionScript = `# bash completion for kubeexec; generated by ` + "`kubeexec completion bash`" + `.
+_kubeexec() {
+	local line="${COMP_LINE:0:COMP_POINT}"
+	local -a words
+	read -ra words <<< "$line"
+	if [[ "$line" =~ [[:space:]]$ ]]; then
+		words+=("")
+	fi
+	local cur="${words[${#words[@]}-1]}"
+	local prev=""
+	if (( ${#words[@]} > 1 )); then
+		prev="${words[${#words[@]}-2]}"
+	fi
+
+	case "$prev" in
+		--script|--env-from-file|--config|--trace-file)
+			COMPREPLY=($(compgen -f -- "$cur"))
+			return 0
+			;;
+	esac
+
+	local IFS=$'\n'
+	local -a candidates=($(kubeexec __complete "${words[@]:1}" 2>/dev/null))
+	candidates=("${candidates[@]%%$'\t'*}")
+	# bash replaces only the part of the word after the last = or :.
+	local done="${cur%"${cur##*[=:]}"}"
+	COMPREPLY=("${candidates[@]#"$done"}")
+	if [[ ${#COMPREPLY[@]} -eq 1 && ${COMPREPLY[0]} == *= ]]; then
+		compopt -o nospace 2>/dev/null
+	fi
+}
+
+complete -F _kubeexec kubeexec
+`
+
+const zshCompletionScript = `#compdef kubeexec
+# zsh completion for kubeexec; generated by ` + "`kubeexec completion zsh`" + `.
+
+_kubeexec() {
+	case "${words[CURRENT-1]}" in
+		--script|--env-from-file|--config|--trace-file)
+			_files
+			return
+			;;
+	esac
+
+	local -a items
+	local line name
+	for line in ${(f)"$(kubeexec __complete "${(@)words[2,CURRENT]}" 2>/dev/null)"}; do
+		name="${line%%$'\t'*}"
+		if [[ "$line" == *$'\t'* ]]; then
+			items+=("${name//:/\\:}:${line#*$'\t'}")
+		else
+			items+=("${name//:/\\:}")
+		fi
+	done
+	(( ${#items} )) && _describe -t values kubeexec items -Q
+}
+
+if [[ "$funcstack[1]" == "_kubeexec" ]]; then
+	_kubeexec "$@"
+else
+	compdef _kubeexec kubeexec
+fi
+`
+
+const fishCompletionScript = `# fish completion for kubeexec; generated by ` + "`kubeexec completion fish`" + `.
+function __kubeexec_complete
+    set -l tokens (commandline -opc) (commandline -ct)
+    kubeexec __complete $tokens[2..-1] 2>/dev/null
+end
+
+complete -c kubeexec -f -a '(__kubeexec_complete)'
+complete -c kubeexec -l script -r -F
+complete -c kubeexec -l env-from-file -r -F
+complete -c kubeexec -l config -r -F
+complete -c kubeexec -l trace-file -r -F
+`
+
+const powershellCompletionScript = `# PowerShell completion for kubeexec; generated by ` + "`kubeexec completion powershell`" + `.
+Register-ArgumentCompleter -Native -CommandName kubeexec -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements |
+        Where-Object { $_.Extent.StartOffset -lt $cursorPosition } |
+        Select-Object -Skip 1 |
+        ForEach-Object { $_.ToString() })
+    if ($wordToComplete -eq '') {
+        # Windows PowerShell and PowerShell before 7.3 drop empty arguments.
+        if ($PSVersionTable.PSVersion -lt [version]'7.3.0') { $words += '""' } else { $words += '' }
+    }
+    & kubeexec __complete @words 2>$null | ForEach-Object {
+        $name, $description = $_ -split "` + "`" + `t", 2
+        if (-not $description) { $description = $name }
+        [System.Management.Automation.CompletionResult]::new($name, $name, 'ParameterValue', $description)
+    }
+}
+`
+
+var completionScripts = map[string]string{
+	"bash":       bashCompletionScript,
+	"zsh":        zshCompletionScript,
+	"fish":       fishCompletionScript,
+	"powershell": powershellCompletionScript,
+}
+
+// WriteCompletionScript writes the completion script for shell.
+func WriteCompletionScript(shell string, out io.Writer) error {
+	script, ok := completionScripts[shell]
+	if !ok {
+		return usageErrorf("completion: unknown shell %q (use %s)", shell, strings.Join(completionShells, ", "))
+	}
+	_, err := fmt.Fprint(out, script)
+	return err
+}
@@ -0,0 +1,253 @@
+package cmdutil
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+const (
+	configEnvVar          = "KUBEEXEC_CONFIG"
+	userConfigFilename    = ".config/kubeexec/kubeexec.toml"
+	projectConfigFilename = ".kubeexec.toml"
+	snippetsConfigKey     = "snippets"
+	pickersConfigKey      = "pickers"
+)
+
+// systemConfigPath is the lowest-precedence config layer.
+var systemConfigPath = "/etc/kubeexec/kubeexec.toml"
+
+// configFileOverride is the file given with --config.
+var configFileOverride string
+
+// SetConfigFile sets the config file given on the command line. It takes
+// precedence over KUBEEXEC_CONFIG and, like it, is layered above the project,
+// user and system files.
+func SetConfigFile(path string) {
+	configFileOverride = path
+}
+
+// configLayer is one config file; required layers were named explicitly and
+// must exist.
+type configLayer struct {
+	source   string
+	path     string
+	required bool
+}
+
+func (l configLayer) describe() string {
+	return l.source + " " + l.path
+}
+
+// project reports whether l is a project file. It comes with whatever
+// repository is checked out, so it may not set what runs local commands or
+// writes local files.
+func (l configLayer) project() bool {
+	return l.source == "project"
+}
+
+// checkProjectSetting refuses, in a project file, the settings that run
+// local commands, write local files or weaken confirmations: a picker other
+// than the built-in backends, trace-file, which is truncated,
+// confirm-token-hash, confirm-context = false, confirm-style and
+// confirm-countdown.
+func checkProjectSetting(key string, value any) error {
+	switch key {
+	case "picker":
+		if name := strings.TrimSpace(value.(string)); !contains(pickerNames, name) {
+			return fmt.Errorf("picker %q runs a local command and cannot be set in a project config (use %s, or set it in the user config or with --config)", name, strings.Join(pickerNames, ", "))
+		}
+	case "trace-file":
+		return fmt.Errorf("trace-file writes a local file and cannot be set in a project config (use --trace-file)")
+	case "confirm-token-hash":
+		return fmt.Errorf("confirm-token-hash guards --yes-i-know and cannot be set in a project config (set it in the user config)")
+	case "confirm-context":
+		if value == false {
+			return fmt.Errorf("a project config can turn confirm-context on but not off (set it in the user config)")
+		}
+	case "confirm-style", "confirm-countdown":
+		return fmt.Errorf("%s decides how confirm-context asks and cannot be set in a project config (set it in the user config)", key)
+	}
+	return nil
+}
+
+// projectValue is what a project file's value for key amounts to on top of
+// current: confirm-context-keywords are added to the current ones, so a
+// project can only widen which contexts ask for confirmation.
+func projectValue(key string, value, current any) any {
+	if key != "confirm-context-keywords" {
+		return value
+	}
+	keywords := append([]string(nil), current.([]string)...)
+	for _, keyword := range value.([]string) {
+		if !contains(keywords, keyword) {
+			keywords = append(keywords, keyword)
+		}
+	}
+	return keywords
+}
+
+// configFile is the parsed content of one config file. Values are keyed by
+// setting and already converted to the setting's type.
+type configFile struct {
+	layer    configLayer
+	values   map[string]any
+	snippets map[string]snippetConfig
+	pickers  map[string]pickerConfig
+	contexts []contextOverride
+}
+
+// configLayers lists the config files from lowest to highest precedence:
+// system, user, project (the nearest .kubeexec.toml from the working
+// directory up) and the explicit --config or KUBEEXEC_CONFIG file.
+func configLayers() ([]configLayer, error) {
+	layers := []configLayer{{source: "system", path: systemConfigPath}}
+	userPath, err := kubeexecConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	layers = append(layers, configLayer{source: "user", path: userPath})
+	if projectPath, ok := findProjectConfig(); ok {
+		layers = append(layers, configLayer{source: "project", path: projectPath})
+	}
+	if configFileOverride != "" {
+		layers = append(layers, configLayer{source: "--config", path: configFileOverride, required: true})
+	} else if path := strings.TrimSpace(os.Getenv(configEnvVar)); path != "" {
+		layers = append(layers, configLayer{source: configEnvVar, path: path, required: true})
+	}
+	return layers, nil
+}
+
+// findProjectConfig walks up from the working directory to the nearest
+// .kubeexec.toml.
+func findProjectConfig() (string, bool) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", false
+	}
+	for {
+		path := filepath.Join(dir, projectConfigFilename)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// loadConfigFiles reads every existing config layer, lowest precedence first.
+func loadConfigFiles() ([]configFile, error) {
+	layers, err := configLayers()
+	if err != nil {
+		return nil, err
+	}
+	var files []configFile
+	for _, layer := range layers {
+		file, found, err := readConfigFile(layer)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			if layer.required {
+				return nil, usageErrorf("config %s (from %s) not found", layer.path, layer.source)
+			}
+			continue
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func readConfigFile(layer configLayer) (configFile, bool, error) {
+	file := configFile{layer: layer}
+	path := layer.path
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return file, false, nil
+		}
+		return file, false, usageErrorf("read config %s: %w", path, err)
+	}
+	// An empty file parses to no keys: a layer that sets nothing.
+	var raw map[string]any
+	if err := toml.Unmarshal(data, &raw); err != nil {
+		return file, false, usageErrorf("parse config %s: %w", path, err)
+	}
+	file.values = make(map[string]any, len(raw))
+	for key, value := range raw {
+		if key == snippetsConfigKey {
+			snippets, err := decodeTables[snippetConfig](snippetsConfigKey, value)
+			if err != nil {
+				return file, false, usageErrorf("parse config %s: %w", path, err)
+			}
+			file.snippets = snippets
+			continue
+		}
+		if key == pickersConfigKey {
+			if layer.project() {
+				return file, false, usageErrorf("parse config %s: [%s.<name>] runs local commands and cannot be set in a project config (set it in the user config or with --config)", path, pickersConfigKey)
+			}
+			pickers, err := decodeTables[pickerConfig](pickersConfigKey, value)
+			if err != nil {
+				return file, false, usageErrorf("parse config %s: %w", path, err)
+			}
+			file.pickers = pickers
+			continue
+		}
+		if key == contextConfigKey {
+			contexts, err := decodeContextOverrides(value, layer)
+			if err != nil {
+				return file, false, usageErrorf("parse config %s: %w", path, err)
+			}
+			file.contexts = contexts
+			continue
+		}
+		def, ok := lookupSettingDef(key)
+		if !ok {
+			return file, false, usageErrorf("parse config %s: unknown key %q", path, key)
+		}
+		converted, err := def.fromTOML(value)
+		if err == nil && layer.project() {
+			err = checkProjectSetting(key, converted)
+		}
+		if err != nil {
+			return file, false, usageErrorf("parse config %s: %w", path, err)
+		}
+		file.values[key] = converted
+	}
+	return file, true, nil
+}
+
+// decodeTables strictly decodes the [<key>.<name>] tables, such as
+// [snippets.<name>] and [pickers.<name>].
+func decodeTables[T any](key string, value any) (map[string]T, error) {
+	if _, ok := value.(map[string]any); !ok {
+		return nil, fmt.Errorf("%s must be a table of [%s.<name>] tables", key, key)
+	}
+	data, err := toml.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var tables map[string]T
+	decoder := toml.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&tables); err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	return tables, nil
+}
+
+func kubeexecConfigPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("resolve home dir: %w", err)
+	}
+	return filepath.Join(home, userConfigFilename), nil
+}
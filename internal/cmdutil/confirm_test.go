@@ -1,9 +1,14 @@
 package cmdutil
 
 import (
+	"bytes"
+	"context"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestResolveConfirmContextFromConfig(t *testing.T) {
@@ -54,7 +59,7 @@ func TestEnvOverridesConfig(t *testing.T) {
 	if err := os.WriteFile(path, []byte("confirm-context = true\n"), 0o600); err != nil {
 		t.Fatalf("write config: %v", err)
 	}
-	t.Setenv(confirmContextEnvVar, "false")
+	t.Setenv("KUBEEXEC_CONFIRM_CONTEXT", "false")
 	val, err := ResolveConfirmContext(false, false)
 	if err != nil {
 		t.Fatalf("ResolveConfirmContext error: %v", err)
@@ -147,9 +152,10 @@ func TestConfirmContextMatch(t *testing.T) {
 		{"empty strings", "", "", false},
 		{"production as namespace", "dev", "production", true},
 	}
+	keywords := loadConfirmContextKeywords(t)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := confirmContextMatch(tt.context, tt.namespace)
+			got := confirmContextMatch(tt.context, tt.namespace, keywords)
 			if got != tt.want {
 				t.Errorf("confirmContextMatch(%q, %q) = %v, want %v", tt.context, tt.namespace, got, tt.want)
 			}
@@ -157,6 +163,15 @@ func TestConfirmContextMatch(t *testing.T) {
 	}
 }
 
+func loadConfirmContextKeywords(t *testing.T) []string {
+	t.Helper()
+	settings, err := loadSettings()
+	if err != nil {
+		t.Fatalf("loadSettings() error: %v", err)
+	}
+	return confirmContextKeywords(settings)
+}
+
 func TestConfirmContextKeywordsFromConfig(t *testing.T) {
 	dir := t.TempDir()
 	t.Setenv("HOME", dir)
@@ -167,7 +182,7 @@ func TestConfirmContextKeywordsFromConfig(t *testing.T) {
 	if err := os.WriteFile(path, []byte("confirm-context-keywords = [\"staging\", \"uat\"]\n"), 0o600); err != nil {
 		t.Fatalf("write config: %v", err)
 	}
-	keywords := resolveConfirmContextKeywords()
+	keywords := loadConfirmContextKeywords(t)
 	if len(keywords) != 2 {
 		t.Fatalf("expected 2 keywords, got %d: %v", len(keywords), keywords)
 	}
@@ -180,7 +195,7 @@ func TestConfirmContextKeywordsDefault(t *testing.T) {
 	dir := t.TempDir()
 	t.Setenv("HOME", dir)
 	// No config file — should use defaults
-	keywords := resolveConfirmContextKeywords()
+	keywords := loadConfirmContextKeywords(t)
 	if len(keywords) != 3 {
 		t.Fatalf("expected 3 default keywords, got %d: %v", len(keywords), keywords)
 	}
@@ -202,7 +217,7 @@ func TestConfirmContextKeywordsNormalize(t *testing.T) {
 	if err := os.WriteFile(path, []byte("confirm-context-keywords = [\" Prod \", \"\", \"LIVE\", \" staging \"]\n"), 0o600); err != nil {
 		t.Fatalf("write config: %v", err)
 	}
-	keywords := resolveConfirmContextKeywords()
+	keywords := loadConfirmContextKeywords(t)
 	expected := []string{"prod", "live", "staging"}
 	if len(keywords) != len(expected) {
 		t.Fatalf("expected %d keywords, got %d: %v", len(expected), len(keywords), keywords)
@@ -213,3 +228,123 @@ func TestConfirmContextKeywordsNormalize(t *testing.T) {
 		}
 	}
 }
+
+// testConfirmTokenHash is the confirm-token-hash of the token "s3cret".
+const testConfirmTokenHash = "1ec1c26b50d5d3c58d9583181af8076655fe00756bf7285940ba3670f99fcba0"
+
+func TestConfirmContextPrompt(t *testing.T) {
+	tests := []struct {
+		name       string
+		style      string
+		countdown  time.Duration
+		yesIKnow   bool
+		token      string
+		tokenHash  string
+		terminal   bool
+		input      string
+		wantKind   ErrorKind
+		wantPrompt string
+	}{
+		{name: "context", terminal: true, input: "prod-eu/payments\n", wantPrompt: `type "prod-eu/payments" to continue`},
+		{name: "context mistyped", terminal: true, input: "prod-eu\n", wantKind: KindDenied},
+		{name: "namespace", style: confirmStyleNamespace, terminal: true, input: "payments\n", wantPrompt: `type "payments" to continue`},
+		{name: "namespace mistyped", style: confirmStyleNamespace, terminal: true, input: "prod-eu/payments\n", wantKind: KindDenied},
+		{name: "word", style: confirmStyleWord, terminal: true, input: "quartz\n", wantPrompt: `type "quartz" to continue`},
+		{name: "word mistyped", style: confirmStyleWord, terminal: true, input: "prod-eu/payments\n", wantKind: KindDenied},
+		{name: "yes", style: confirmStyleYesNo, terminal: true, input: "Y\n", wantPrompt: "[y/N]"},
+		{name: "no by default", style: confirmStyleYesNo, terminal: true, input: "\n", wantKind: KindDenied},
+		{name: "countdown runs out", style: confirmStyleCountdown, countdown: 10 * time.Millisecond, terminal: true, wantPrompt: "exec into prod-eu/payments in"},
+		{name: "countdown skipped", style: confirmStyleCountdown, countdown: time.Hour, terminal: true, input: "\n"},
+		{name: "countdown aborted", style: confirmStyleCountdown, countdown: time.Hour, terminal: true, input: "n\n", wantKind: KindDenied},
+		{name: "no terminal", wantKind: KindDenied},
+		{name: "yes-i-know with the token", yesIKnow: true, token: "s3cret", tokenHash: testConfirmTokenHash},
+		{name: "yes-i-know with the target as token", yesIKnow: true, token: "prod-eu/payments", tokenHash: testConfirmTokenHash, terminal: true, input: "prod-eu/payments\n", wantKind: KindDenied},
+		{name: "yes-i-know without a token", yesIKnow: true, tokenHash: testConfirmTokenHash, terminal: true, input: "prod-eu/payments\n", wantKind: KindDenied},
+		{name: "yes-i-know without a token hash", yesIKnow: true, token: "s3cret", terminal: true, input: "prod-eu/payments\n", wantKind: KindDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			if tt.terminal {
+				out = withTerminal(t, tt.input)
+			} else {
+				noTerminal(t)
+			}
+			t.Setenv(confirmTokenEnvVar, tt.token)
+			oldWord := randomConfirmWord
+			randomConfirmWord = func() string { return "quartz" }
+			t.Cleanup(func() { randomConfirmWord = oldWord })
+
+			policy, err := newConfirmPolicy(tt.style, tt.countdown, tt.yesIKnow, tt.tokenHash)
+			if err != nil {
+				t.Fatalf("newConfirmPolicy() error: %v", err)
+			}
+			var stderr bytes.Buffer
+			err = confirmContextPrompt(context.Background(), "prod-eu", "payments", policy, &stderr)
+			if tt.wantKind != 0 {
+				if !IsKind(err, tt.wantKind) {
+					t.Errorf("confirmContextPrompt() error = %v, want kind %d", err, tt.wantKind)
+				} else if tt.yesIKnow && strings.Contains(err.Error(), "prod-eu/payments") {
+					t.Errorf("confirmContextPrompt() error = %v, want no token to export", err)
+				}
+			} else if err != nil {
+				t.Errorf("confirmContextPrompt() error: %v", err)
+			}
+			if !strings.Contains(out.String(), tt.wantPrompt) {
+				t.Errorf("prompt = %q, want it to contain %q", out.String(), tt.wantPrompt)
+			}
+			if tt.yesIKnow && tt.wantKind == 0 && !strings.Contains(stderr.String(), "skipped with --yes-i-know") {
+				t.Errorf("stderr = %q, want the --yes-i-know note", stderr.String())
+			}
+		})
+	}
+}
+
+func TestNewConfirmPolicy(t *testing.T) {
+	tests := []struct {
+		style     string
+		countdown time.Duration
+		tokenHash string
+		want      confirmPolicy
+		wantErr   bool
+	}{
+		{want: confirmPolicy{style: confirmStyleContext, countdown: confirmCountdownDefault}},
+		{style: " yes-no ", countdown: time.Second, want: confirmPolicy{style: confirmStyleYesNo, countdown: time.Second}},
+		{style: "maybe", wantErr: true},
+		{style: confirmStyleCountdown, countdown: -time.Second, wantErr: true},
+		{tokenHash: " " + strings.ToUpper(testConfirmTokenHash), want: confirmPolicy{style: confirmStyleContext, countdown: confirmCountdownDefault, tokenHash: testConfirmTokenHash}},
+		{tokenHash: "s3cret", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := newConfirmPolicy(tt.style, tt.countdown, false, tt.tokenHash)
+		if tt.wantErr {
+			if !IsKind(err, KindUsage) {
+				t.Errorf("newConfirmPolicy(%q, %s) error = %v, want usage error", tt.style, tt.countdown, err)
+			}
+		} else if err != nil || got != tt.want {
+			t.Errorf("newConfirmPolicy(%q, %s) = %+v, %v, want %+v", tt.style, tt.countdown, got, err, tt.want)
+		}
+	}
+}
+
+func TestRunConfirmWithPipedStdin(t *testing.T) {
+	h := newFakeCluster(t, fakeRunCluster())
+	writeConfigFile(t, filepath.Join(h.home, userConfigFilename), "confirm-context = true\n[context.\"prod-*\"]\nconfirm-style = \"yes-no\"\n")
+	prompt := withTerminal(t, "y\n")
+	settings, err := ResolveSettings(map[string]string{"picker": "none"})
+	if err != nil {
+		t.Fatalf("ResolveSettings() error: %v", err)
+	}
+	opts := RunOptions{Context: "prod-eu", Pod: "api-1", Command: []string{"sh"}}
+	settings.ApplyRunOptions(&opts)
+	opts.Stdin = strings.NewReader("echo piped\n")
+	if err := Run(context.Background(), opts); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if !strings.Contains(prompt.String(), "[y/N]") {
+		t.Errorf("prompt = %q, want the yes-no style from the context section", prompt.String())
+	}
+	if got, want := h.execs(t), [][]string{{"--context", "prod-eu", "exec", "-i", "-n", "payments", "api-1", "-c", "app", "--", "sh"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("kubectl exec calls = %q, want %q", got, want)
+	}
+}
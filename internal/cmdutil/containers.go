@@ -0,0 +1,242 @@
+package cmdutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type ContainerKind string
+
+const (
+	ContainerKindRegular   ContainerKind = "container"
+	ContainerKindSidecar   ContainerKind = "sidecar"
+	ContainerKindInit      ContainerKind = "init"
+	ContainerKindEphemeral ContainerKind = "ephemeral"
+)
+
+const (
+	containerStateRunning    = "running"
+	containerStateWaiting    = "waiting"
+	containerStateTerminated = "terminated"
+	containerStateUnknown    = "unknown"
+)
+
+type ContainerItem struct {
+	Name         string
+	Kind         ContainerKind
+	Image        string
+	Ready        bool
+	RestartCount int
+	State        string
+	Reason       string
+	Display      string
+}
+
+// Running reports whether the container can accept an exec session.
+func (c ContainerItem) Running() bool {
+	return c.State == containerStateRunning
+}
+
+// StateSummary renders the container state with its reason, e.g. "waiting (CrashLoopBackOff)".
+func (c ContainerItem) StateSummary() string {
+	state := c.State
+	if state == "" {
+		state = containerStateUnknown
+	}
+	if c.Reason != "" {
+		return state + " (" + c.Reason + ")"
+	}
+	return state
+}
+
+type containerSpecJSON struct {
+	Name          string `json:"name"`
+	Image         string `json:"image"`
+	RestartPolicy string `json:"restartPolicy"`
+}
+
+type containerStatusJSON struct {
+	Name         string `json:"name"`
+	Image        string `json:"image"`
+	Ready        bool   `json:"ready"`
+	RestartCount int    `json:"restartCount"`
+	State        map[string]struct {
+		Reason string `json:"reason"`
+	} `json:"state"`
+}
+
+const defaultContainerAnnotation = "kubectl.kubernetes.io/default-container"
+
+// parsePodContainers extracts containers and the default-container annotation
+// from `kubectl get pod -o json` output. Regular containers come first,
+// followed by sidecars (restartable init containers), plain init containers
+// and ephemeral containers. Ephemeral containers are only listed while
+// running since finished debug sessions cannot be attached again.
+func parsePodContainers(data []byte) ([]ContainerItem, string, error) {
+	var pod podJSON
+	if err := json.Unmarshal(data, &pod); err != nil {
+		return nil, "", fmt.Errorf("parse pod: %w", err)
+	}
+	containers, defaultContainer := podContainers(pod)
+	return containers, defaultContainer, nil
+}
+
+func podContainers(pod podJSON) ([]ContainerItem, string) {
+	statuses := make(map[string]containerStatusJSON)
+	for _, st := range pod.Status.ContainerStatuses {
+		statuses[st.Name] = st
+	}
+	for _, st := range pod.Status.InitContainerStatuses {
+		statuses[st.Name] = st
+	}
+	for _, st := range pod.Status.EphemeralContainerStatuses {
+		statuses[st.Name] = st
+	}
+
+	var regular, sidecars, inits, ephemerals []ContainerItem
+	for _, spec := range pod.Spec.Containers {
+		regular = append(regular, newContainerItem(spec, ContainerKindRegular, statuses))
+	}
+	for _, spec := range pod.Spec.InitContainers {
+		if spec.RestartPolicy == "Always" {
+			sidecars = append(sidecars, newContainerItem(spec, ContainerKindSidecar, statuses))
+			continue
+		}
+		inits = append(inits, newContainerItem(spec, ContainerKindInit, statuses))
+	}
+	for _, spec := range pod.Spec.EphemeralContainers {
+		item := newContainerItem(spec, ContainerKindEphemeral, statuses)
+		if item.Running() {
+			ephemerals = append(ephemerals, item)
+		}
+	}
+	containers := append(append(append(regular, sidecars...), inits...), ephemerals...)
+
+	defaultContainer := strings.TrimSpace(pod.Metadata.Annotations[defaultContainerAnnotation])
+	return containers, defaultContainer
+}
+
+func newContainerItem(spec containerSpecJSON, kind ContainerKind, statuses map[string]containerStatusJSON) ContainerItem {
+	item := ContainerItem{
+		Name:  spec.Name,
+		Kind:  kind,
+		Image: spec.Image,
+		State: containerStateUnknown,
+	}
+	st, ok := statuses[spec.Name]
+	if !ok {
+		return item
+	}
+	if st.Image != "" {
+		item.Image = st.Image
+	}
+	item.Ready = st.Ready
+	item.RestartCount = st.RestartCount
+	for _, state := range []string{containerStateRunning, containerStateWaiting, containerStateTerminated} {
+		if detail, ok := st.State[state]; ok {
+			item.State = state
+			item.Reason = detail.Reason
+			break
+		}
+	}
+	return item
+}
+
+func containerNames(containers []ContainerItem) []string {
+	names := make([]string, 0, len(containers))
+	for _, c := range containers {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func findContainer(containers []ContainerItem, name string) (ContainerItem, bool) {
+	for _, c := range containers {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return ContainerItem{}, false
+}
+
+// containerLabels lists container names, marking non-regular kinds,
+// e.g. "app, proxy (sidecar), debugger-x7k (ephemeral)".
+func containerLabels(containers []ContainerItem) string {
+	labels := make([]string, 0, len(containers))
+	for _, c := range containers {
+		if c.Kind == ContainerKindRegular || c.Kind == "" {
+			labels = append(labels, c.Name)
+			continue
+		}
+		labels = append(labels, fmt.Sprintf("%s (%s)", c.Name, c.Kind))
+	}
+	return strings.Join(labels, ", ")
+}
+
+func filterContainersByKind(containers []ContainerItem, kind ContainerKind) []ContainerItem {
+	var matches []ContainerItem
+	for _, c := range containers {
+		if c.Kind == kind {
+			matches = append(matches, c)
+		}
+	}
+	return matches
+}
+
+// formatContainerDisplays fills Display for each container as aligned
+// KIND NAME IMAGE READY RESTARTS STATE columns, keeping the input order so
+// kinds stay grouped in the picker.
+func formatContainerDisplays(containers []ContainerItem) {
+	rows := make([][]string, len(containers))
+	widths := make([]int, 5)
+	for i, c := range containers {
+		ready := "not-ready"
+		if c.Ready {
+			ready = "ready"
+		}
+		rows[i] = []string{
+			"[" + string(c.Kind) + "]",
+			c.Name,
+			c.Image,
+			ready,
+			"restarts=" + strconv.Itoa(c.RestartCount),
+			c.StateSummary(),
+		}
+		for j := range widths {
+			if len(rows[i][j]) > widths[j] {
+				widths[j] = len(rows[i][j])
+			}
+		}
+	}
+	for i := range containers {
+		row := rows[i]
+		containers[i].Display = fmt.Sprintf("%-*s  %-*s  %-*s  %-*s  %-*s  %s",
+			widths[0], row[0], widths[1], row[1], widths[2], row[2], widths[3], row[3], widths[4], row[4], row[5])
+	}
+}
+
+func containerPickerItems(containers []ContainerItem) []PickerItem {
+	items := make([]PickerItem, 0, len(containers))
+	for _, c := range containers {
+		display := c.Display
+		if display == "" {
+			display = c.Name
+		}
+		items = append(items, PickerItem{Key: c.Name, Display: display})
+	}
+	return items
+}
+
+func notRunningContainerError(pod string, c ContainerItem) error {
+	msg := fmt.Sprintf("container %q in pod %q is not running (%s %s)", c.Name, pod, c.Kind, c.StateSummary())
+	switch {
+	case c.Kind == ContainerKindInit && c.State == containerStateTerminated:
+		msg += "; init containers exit before the main containers start"
+	case c.Reason == "CrashLoopBackOff":
+		msg += "; it keeps crashing, check logs with kubectl logs --previous"
+	}
+	return &Error{Kind: KindResolution, Err: errors.New(msg)}
+}
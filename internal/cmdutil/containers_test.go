@@ -0,0 +1,148 @@
+package cmdutil
+
+import (
+	"strings"
+	"testing"
+)
+
+const podWithSidecarJSON = `{
+  "metadata": {"annotations": {"kubectl.kubernetes.io/default-container": "app"}},
+  "spec": {
+    "containers": [
+      {"name": "app", "image": "example/app:1.0"},
+      {"name": "metrics", "image": "example/metrics:2.1"}
+    ],
+    "initContainers": [
+      {"name": "migrate", "image": "example/migrate:1.0"},
+      {"name": "proxy", "image": "example/proxy:3.3", "restartPolicy": "Always"}
+    ],
+    "ephemeralContainers": [
+      {"name": "debugger-old", "image": "busybox"},
+      {"name": "debugger-x7k", "image": "busybox"}
+    ]
+  },
+  "status": {
+    "containerStatuses": [
+      {"name": "app", "image": "example/app:1.0", "ready": true, "restartCount": 0, "state": {"running": {}}},
+      {"name": "metrics", "image": "example/metrics:2.1", "ready": false, "restartCount": 7, "state": {"waiting": {"reason": "CrashLoopBackOff"}}}
+    ],
+    "initContainerStatuses": [
+      {"name": "migrate", "ready": false, "restartCount": 0, "state": {"terminated": {"reason": "Completed"}}},
+      {"name": "proxy", "ready": true, "restartCount": 1, "state": {"running": {}}}
+    ],
+    "ephemeralContainerStatuses": [
+      {"name": "debugger-old", "ready": false, "restartCount": 0, "state": {"terminated": {"reason": "Completed"}}},
+      {"name": "debugger-x7k", "ready": false, "restartCount": 0, "state": {"running": {}}}
+    ]
+  }
+}`
+
+func TestParsePodContainers(t *testing.T) {
+	containers, defaultContainer, err := parsePodContainers([]byte(podWithSidecarJSON))
+	if err != nil {
+		t.Fatalf("parsePodContainers error: %v", err)
+	}
+	if defaultContainer != "app" {
+		t.Errorf("default container = %q, want %q", defaultContainer, "app")
+	}
+	want := []struct {
+		name     string
+		kind     ContainerKind
+		state    string
+		reason   string
+		restarts int
+		ready    bool
+	}{
+		{"app", ContainerKindRegular, "running", "", 0, true},
+		{"metrics", ContainerKindRegular, "waiting", "CrashLoopBackOff", 7, false},
+		{"proxy", ContainerKindSidecar, "running", "", 1, true},
+		{"migrate", ContainerKindInit, "terminated", "Completed", 0, false},
+		{"debugger-x7k", ContainerKindEphemeral, "running", "", 0, false},
+	}
+	if len(containers) != len(want) {
+		t.Fatalf("got %d containers, want %d: %+v", len(containers), len(want), containers)
+	}
+	for i, w := range want {
+		c := containers[i]
+		if c.Name != w.name || c.Kind != w.kind || c.State != w.state || c.Reason != w.reason || c.RestartCount != w.restarts || c.Ready != w.ready {
+			t.Errorf("containers[%d] = %+v, want %+v", i, c, w)
+		}
+	}
+}
+
+func TestParsePodContainersMissingStatus(t *testing.T) {
+	containers, _, err := parsePodContainers([]byte(`{"spec": {"containers": [{"name": "app", "image": "img"}]}}`))
+	if err != nil {
+		t.Fatalf("parsePodContainers error: %v", err)
+	}
+	if len(containers) != 1 || containers[0].State != "unknown" || containers[0].Running() {
+		t.Fatalf("expected single container in unknown state, got %+v", containers)
+	}
+}
+
+func TestParsePodContainersInvalidJSON(t *testing.T) {
+	if _, _, err := parsePodContainers([]byte("app\napp\n")); err == nil {
+		t.Fatal("expected error for non-JSON output")
+	}
+}
+
+func TestFormatContainerDisplays(t *testing.T) {
+	containers, _, err := parsePodContainers([]byte(podWithSidecarJSON))
+	if err != nil {
+		t.Fatalf("parsePodContainers error: %v", err)
+	}
+	formatContainerDisplays(containers)
+	for _, c := range containers {
+		if !strings.HasPrefix(c.Display, "["+string(c.Kind)+"]") {
+			t.Errorf("display %q does not start with kind %q", c.Display, c.Kind)
+		}
+		if !strings.Contains(c.Display, c.Image) {
+			t.Errorf("display %q does not contain image %q", c.Display, c.Image)
+		}
+	}
+	if !strings.Contains(containers[1].Display, "restarts=7") || !strings.HasSuffix(containers[1].Display, "waiting (CrashLoopBackOff)") {
+		t.Errorf("unexpected display for crashing container: %q", containers[1].Display)
+	}
+	items := containerPickerItems(containers)
+	if items[2].Key != "proxy" || items[2].Display != containers[2].Display {
+		t.Errorf("containerPickerItems[2] = %+v, want key proxy with its display", items[2])
+	}
+}
+
+func TestContainerLabels(t *testing.T) {
+	containers, _, err := parsePodContainers([]byte(podWithSidecarJSON))
+	if err != nil {
+		t.Fatalf("parsePodContainers error: %v", err)
+	}
+	got := containerLabels(containers)
+	want := "app, metrics, proxy (sidecar), migrate (init), debugger-x7k (ephemeral)"
+	if got != want {
+		t.Errorf("containerLabels() = %q, want %q", got, want)
+	}
+	if _, ok := findContainer(containers, "debugger-x7k"); !ok {
+		t.Error("expected findContainer to find running ephemeral container")
+	}
+	if _, ok := findContainer(containers, "debugger-old"); ok {
+		t.Error("expected findContainer to skip terminated ephemeral container")
+	}
+}
+
+func TestNotRunningContainerError(t *testing.T) {
+	tests := []struct {
+		name      string
+		container ContainerItem
+		want      string
+	}{
+		{"completed init", ContainerItem{Name: "migrate", Kind: ContainerKindInit, State: "terminated", Reason: "Completed"}, "init containers exit before"},
+		{"crash loop", ContainerItem{Name: "app", Kind: ContainerKindRegular, State: "waiting", Reason: "CrashLoopBackOff"}, "keeps crashing"},
+		{"unknown", ContainerItem{Name: "app", Kind: ContainerKindRegular}, `container "app" in pod "pod" is not running (container unknown)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := notRunningContainerError("pod", tt.container)
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("notRunningContainerError() = %q, want it to contain %q", err.Error(), tt.want)
+			}
+		})
+	}
+}
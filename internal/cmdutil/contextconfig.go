@@ -0,0 +1,157 @@
+package cmdutil
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const contextConfigKey = "context"
+
+// contextOverride is a [context."<glob>"] section: settings that apply when
+// the resolved kubernetes context matches pattern.
+type contextOverride struct {
+	pattern string
+	values  map[string]any
+	layer   configLayer
+}
+
+// contextMatch records a context section that was applied and which keys it
+// changed.
+type contextMatch struct {
+	pattern string
+	source  string
+	keys    []string
+}
+
+func (m contextMatch) describe(context string) string {
+	return fmt.Sprintf("context %q matches [context.%q] (%s): %s", context, m.pattern, m.source, strings.Join(m.keys, ", "))
+}
+
+// decodeContextOverrides converts the [context."<glob>"] tables of a file.
+func decodeContextOverrides(value any, layer configLayer) ([]contextOverride, error) {
+	tables, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a table of [%s.\"<glob>\"] tables", contextConfigKey, contextConfigKey)
+	}
+	overrides := make([]contextOverride, 0, len(tables))
+	for pattern, raw := range tables {
+		table, ok := raw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("[%s.%q] must be a table", contextConfigKey, pattern)
+		}
+		override := contextOverride{pattern: pattern, values: make(map[string]any, len(table)), layer: layer}
+		for key, value := range table {
+			def, ok := lookupSettingDef(key)
+			if !ok {
+				return nil, fmt.Errorf("[%s.%q]: unknown key %q", contextConfigKey, pattern, key)
+			}
+			converted, err := def.fromTOML(value)
+			if err == nil && layer.project() {
+				err = checkProjectSetting(key, converted)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("[%s.%q]: %w", contextConfigKey, pattern, err)
+			}
+			override.values[key] = converted
+		}
+		overrides = append(overrides, override)
+	}
+	sort.Slice(overrides, func(i, j int) bool { return overrides[i].pattern < overrides[j].pattern })
+	return overrides, nil
+}
+
+// ForContext returns the settings with the sections matching context applied.
+// Context sections override config files but not environment variables or
+// flags. When several sections match, the more specific pattern (fewer
+// wildcards, then longer) wins, and later files win ties.
+func (s *Settings) ForContext(context string) *Settings {
+	applied := &Settings{
+		values:           make(map[string]settingValue, len(s.values)),
+		snippets:         s.snippets,
+		snippetSources:   s.snippetSources,
+		pickers:          s.pickers,
+		pickerSources:    s.pickerSources,
+		files:            s.files,
+		contextOverrides: s.contextOverrides,
+	}
+	for key, value := range s.values {
+		applied.values[key] = value
+	}
+	if context == "" {
+		return applied
+	}
+	var matching []contextOverride
+	for _, override := range s.contextOverrides {
+		if contextGlobMatch(override.pattern, context) {
+			matching = append(matching, override)
+		}
+	}
+	sort.SliceStable(matching, func(i, j int) bool {
+		wi, wj := contextGlobWildcards(matching[i].pattern), contextGlobWildcards(matching[j].pattern)
+		if wi != wj {
+			return wi > wj
+		}
+		return len(matching[i].pattern) < len(matching[j].pattern)
+	})
+	for _, override := range matching {
+		source := override.layer.describe() + fmt.Sprintf(" [context.%q]", override.pattern)
+		match := contextMatch{pattern: override.pattern, source: override.layer.describe()}
+		for key, value := range override.values {
+			current := applied.Source(key)
+			if strings.HasPrefix(current, "flag ") || strings.HasPrefix(current, "env ") {
+				continue
+			}
+			if override.layer.project() {
+				value = projectValue(key, value, applied.values[key].value)
+			}
+			applied.values[key] = settingValue{value: value, source: source}
+			match.keys = append(match.keys, key)
+		}
+		if len(match.keys) > 0 {
+			sort.Strings(match.keys)
+			applied.contextMatches = append(applied.contextMatches, match)
+		}
+	}
+	return applied
+}
+
+// withOverride returns the settings with one context section applied
+// unconditionally, for validation.
+func (s *Settings) withOverride(override contextOverride) *Settings {
+	applied := *s
+	applied.values = make(map[string]settingValue, len(s.values))
+	for key, value := range s.values {
+		applied.values[key] = value
+	}
+	for key, value := range override.values {
+		applied.values[key] = settingValue{value: value, source: override.layer.describe()}
+	}
+	return &applied
+}
+
+// contextGlobMatch matches context names, where * matches any run of
+// characters (including "/" and ":", common in EKS and GKE context names)
+// and ? matches one character.
+func contextGlobMatch(pattern, name string) bool {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	matched, _ := regexp.MatchString(b.String(), name)
+	return matched
+}
+
+func contextGlobWildcards(pattern string) int {
+	return strings.Count(pattern, "*") + strings.Count(pattern, "?")
+}
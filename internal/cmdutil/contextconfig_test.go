@@ -0,0 +1,157 @@
+package cmdutil
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestContextGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{pattern: "prod-*", name: "prod-eu", want: true},
+		{pattern: "prod-*", name: "preprod-eu", want: false},
+		{pattern: "dev-eu", name: "dev-eu", want: true},
+		{pattern: "dev-eu", name: "dev-eu-2", want: false},
+		{pattern: "*:cluster/payments", name: "arn:aws:eks:eu-west-1:123:cluster/payments", want: true},
+		{pattern: "gke_*_prod", name: "gke_project_europe-west1_prod", want: true},
+		{pattern: "dev-?", name: "dev-1", want: true},
+		{pattern: "a.b", name: "axb", want: false},
+	}
+	for _, tt := range tests {
+		if got := contextGlobMatch(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("contextGlobMatch(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSettingsForContext(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+	path := filepath.Join(dir, ".config", "kubeexec", "kubeexec.toml")
+	writeConfigFile(t, path, `
+namespace = "default-ns"
+shell = "sh"
+
+[context."*"]
+picker-sort = "newest"
+
+[context."prod-*"]
+confirm-context = true
+shell = "ash"
+
+[context."prod-eu"]
+shell = "bash"
+
+[context."dev-eu"]
+namespace = "payments"
+`)
+	t.Setenv("KUBEEXEC_CONFIRM_CONTEXT", "false")
+	base, err := ResolveSettings(nil)
+	if err != nil {
+		t.Fatalf("ResolveSettings() error: %v", err)
+	}
+
+	prod := base.ForContext("prod-eu")
+	if prod.String("shell") != "bash" {
+		t.Errorf("prod-eu shell = %q, want bash from the most specific section", prod.String("shell"))
+	}
+	if prod.Bool("confirm-context") || prod.Source("confirm-context") != "env KUBEEXEC_CONFIRM_CONTEXT" {
+		t.Errorf("confirm-context = %v from %s, want env to win over the context section", prod.Bool("confirm-context"), prod.Source("confirm-context"))
+	}
+	if prod.String("picker-sort") != "newest" || prod.String("namespace") != "default-ns" {
+		t.Errorf("prod-eu picker-sort/namespace = %q/%q", prod.String("picker-sort"), prod.String("namespace"))
+	}
+	if got, want := prod.Source("shell"), "user "+path+` [context."prod-eu"]`; got != want {
+		t.Errorf("Source(shell) = %q, want %q", got, want)
+	}
+	var described []string
+	for _, match := range prod.contextMatches {
+		described = append(described, match.describe("prod-eu"))
+	}
+	want := []string{
+		`context "prod-eu" matches [context."*"] (user ` + path + `): picker-sort`,
+		`context "prod-eu" matches [context."prod-*"] (user ` + path + `): shell`,
+		`context "prod-eu" matches [context."prod-eu"] (user ` + path + `): shell`,
+	}
+	if strings.Join(described, "\n") != strings.Join(want, "\n") {
+		t.Errorf("context matches:\n%s\nwant:\n%s", strings.Join(described, "\n"), strings.Join(want, "\n"))
+	}
+
+	dev := base.ForContext("dev-eu")
+	if dev.String("namespace") != "payments" || dev.String("shell") != "sh" {
+		t.Errorf("dev-eu namespace/shell = %q/%q, want payments/sh", dev.String("namespace"), dev.String("shell"))
+	}
+	if base.String("namespace") != "default-ns" {
+		t.Errorf("ForContext modified the base settings")
+	}
+
+	var opts RunOptions
+	dev.ApplyRunOptions(&opts)
+	if opts.Namespace != "payments" {
+		t.Errorf("ApplyRunOptions namespace = %q, want payments", opts.Namespace)
+	}
+}
+
+func TestContextOverridesInvalid(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+	path := filepath.Join(dir, ".config", "kubeexec", "kubeexec.toml")
+	for _, content := range []string{
+		"[context.\"prod-*\"]\nconfirm-context = \"yes\"\n",
+		"[context.\"prod-*\"]\nunknown = 1\n",
+		"context = \"prod\"\n",
+	} {
+		writeConfigFile(t, path, content)
+		if _, err := ResolveSettings(nil); !IsKind(err, KindUsage) {
+			t.Errorf("config %q: error = %v, want usage error", content, err)
+		}
+	}
+
+	writeConfigFile(t, path, "[context.\"prod-*\"]\npicker-sort = \"random\"\n")
+	if _, err := ResolveSettings(nil); err != nil {
+		t.Fatalf("ResolveSettings() error: %v", err)
+	}
+	var out bytes.Buffer
+	if err := RunConfigCommand([]string{"validate"}, nil, &out); !IsKind(err, KindUsage) || !strings.Contains(err.Error(), `[context."prod-*"]`) {
+		t.Errorf("config validate error = %v, want usage error naming the section", err)
+	}
+}
+
+func TestProjectContextOverridesTightenOnly(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv(configEnvVar, "")
+	t.Chdir(dir)
+	writeConfigFile(t, filepath.Join(dir, userConfigFilename), "confirm-context = true\nconfirm-context-keywords = [\"prod\"]\n")
+	project := filepath.Join(dir, projectConfigFilename)
+	for _, content := range []string{
+		"[context.\"prod-*\"]\nconfirm-context = false\n",
+		"[context.\"prod-*\"]\nconfirm-style = \"countdown\"\n",
+	} {
+		writeConfigFile(t, project, content)
+		if _, err := ResolveSettings(nil); !IsKind(err, KindUsage) {
+			t.Errorf("project config %q: error = %v, want usage error", content, err)
+		}
+	}
+
+	writeConfigFile(t, project, "[context.\"prod-*\"]\nconfirm-context = true\nconfirm-context-keywords = [\"nothing\"]\n")
+	base, err := ResolveSettings(nil)
+	if err != nil {
+		t.Fatalf("ResolveSettings() error: %v", err)
+	}
+	prod := base.ForContext("prod-eu")
+	if !prod.Bool("confirm-context") {
+		t.Errorf("prod-eu confirm-context = false, want true")
+	}
+	if got, want := prod.Strings("confirm-context-keywords"), []string{"prod", "nothing"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("prod-eu confirm-context-keywords = %v, want %v", got, want)
+	}
+}
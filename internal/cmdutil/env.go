@@ -0,0 +1,192 @@
+package cmdutil
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const redactedEnvValue = "***"
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// EnvVar is an environment variable set for the remote command.
+type EnvVar struct {
+	Name  string
+	Value string
+}
+
+// ResolveEnv builds the remote environment from --forward-env patterns,
+// --env-from-file files and -e assignments, in increasing precedence. A bare
+// -e KEY forwards the local value of KEY.
+func ResolveEnv(assignments, files, forwardPatterns []string) ([]EnvVar, error) {
+	var env []EnvVar
+	forwarded, err := forwardedEnv(forwardPatterns, os.Environ())
+	if err != nil {
+		return nil, err
+	}
+	env = append(env, forwarded...)
+	for _, file := range files {
+		vars, err := loadEnvFile(file)
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, vars...)
+	}
+	for _, assignment := range assignments {
+		name, value, ok := strings.Cut(assignment, "=")
+		if !ok {
+			value, ok = os.LookupEnv(name)
+			if !ok {
+				return nil, usageErrorf("-e %s: variable is not set locally (use -e %s=VALUE)", name, name)
+			}
+		}
+		if !envNamePattern.MatchString(name) {
+			return nil, usageErrorf("invalid environment variable name %q in -e", name)
+		}
+		env = append(env, EnvVar{Name: name, Value: value})
+	}
+	return mergeEnv(env), nil
+}
+
+// forwardedEnv returns the local variables whose names match one of the glob
+// patterns, sorted by name.
+func forwardedEnv(patterns, environ []string) ([]EnvVar, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, usageErrorf("invalid --forward-env pattern %q", pattern)
+		}
+	}
+	var env []EnvVar
+	for _, entry := range environ {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok || !envNamePattern.MatchString(name) {
+			continue
+		}
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, name); matched {
+				env = append(env, EnvVar{Name: name, Value: value})
+				break
+			}
+		}
+	}
+	sort.Slice(env, func(i, j int) bool { return env[i].Name < env[j].Name })
+	return env, nil
+}
+
+// loadEnvFile reads KEY=VALUE lines. Blank lines and # comments are skipped,
+// an "export " prefix is allowed and matching surrounding quotes are removed.
+func loadEnvFile(file string) ([]EnvVar, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, usageErrorf("read env file: %w", err)
+	}
+	defer f.Close()
+	var env []EnvVar
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		text = strings.TrimPrefix(text, "export ")
+		name, value, ok := strings.Cut(text, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !envNamePattern.MatchString(name) {
+			return nil, usageErrorf("%s:%d: expected KEY=VALUE", file, line)
+		}
+		env = append(env, EnvVar{Name: name, Value: unquoteEnvValue(strings.TrimSpace(value))})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, usageErrorf("read env file: %w", err)
+	}
+	return env, nil
+}
+
+func unquoteEnvValue(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// mergeEnv keeps the last value of each variable at its first position.
+func mergeEnv(env []EnvVar) []EnvVar {
+	index := make(map[string]int, len(env))
+	var merged []EnvVar
+	for _, v := range env {
+		if i, ok := index[v.Name]; ok {
+			merged[i].Value = v.Value
+			continue
+		}
+		index[v.Name] = len(merged)
+		merged = append(merged, v)
+	}
+	return merged
+}
+
+// redactEnv returns env with every value masked, for output that may end up
+// in terminals, logs or shell history.
+func redactEnv(env []EnvVar) []EnvVar {
+	if len(env) == 0 {
+		return nil
+	}
+	redacted := make([]EnvVar, len(env))
+	for i, v := range env {
+		redacted[i] = EnvVar{Name: v.Name, Value: redactedEnvValue}
+	}
+	return redacted
+}
+
+// formatEnv lists env as KEY=VALUE words.
+func formatEnv(env []EnvVar) string {
+	words := make([]string, len(env))
+	for i, v := range env {
+		words[i] = v.Name + "=" + v.Value
+	}
+	return strings.Join(words, " ")
+}
+
+// envPreambleEnd ends the env preamble on the remote command's stdin.
+const envPreambleEnd = "# kubeexec: end of env"
+
+// envCommand prefixes command with env(1) assignments so the variables reach
+// the remote command, or the shell it starts. The values end up in process
+// listings and in the exec request the API server audits, so this is only
+// used for TTY sessions, whose stdin must be the terminal.
+func envCommand(env []EnvVar, command []string) []string {
+	if len(env) == 0 {
+		return command
+	}
+	prefixed := make([]string, 0, len(env)+len(command)+1)
+	prefixed = append(prefixed, "env")
+	for _, v := range env {
+		prefixed = append(prefixed, v.Name+"="+v.Value)
+	}
+	return append(prefixed, command...)
+}
+
+// envStdinCommand wraps command so the remote shell first reads the
+// envPreamble lines from stdin, evaluates them and then execs command with
+// the rest of stdin. The values never appear in an argv.
+func envStdinCommand(command []string) []string {
+	script := `e=; while IFS= read -r l && [ "$l" != '` + envPreambleEnd + `' ]; do e="$e$l
+"; done; eval "$e"; exec "$@"`
+	return append([]string{"sh", "-c", script, "sh"}, command...)
+}
+
+// envPreamble exports env in shell syntax for envStdinCommand.
+func envPreamble(env []EnvVar) string {
+	var b strings.Builder
+	for _, v := range env {
+		b.WriteString("export " + v.Name + "=" + shellQuote(v.Value) + "\n")
+	}
+	b.WriteString(envPreambleEnd + "\n")
+	return b.String()
+}
@@ -0,0 +1,96 @@
+package cmdutil
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveEnv(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.env")
+	content := "# database\nexport DB_URL=\"postgres://db:5432/app\"\nTOKEN='from-file'\n\nLEVEL=info\n"
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	t.Setenv("KX_TEST_TOKEN", "forwarded")
+	t.Setenv("KX_TEST_REGION", "eu-west-1")
+
+	env, err := ResolveEnv([]string{"LEVEL=debug", "KX_TEST_REGION", "EMPTY="}, []string{file}, []string{"KX_TEST_*"})
+	if err != nil {
+		t.Fatalf("ResolveEnv() error: %v", err)
+	}
+	got := fmt.Sprint(env)
+	want := "[{KX_TEST_REGION eu-west-1} {KX_TEST_TOKEN forwarded} {DB_URL postgres://db:5432/app} {TOKEN from-file} {LEVEL debug} {EMPTY }]"
+	if got != want {
+		t.Errorf("ResolveEnv() = %s, want %s", got, want)
+	}
+}
+
+func TestResolveEnvErrors(t *testing.T) {
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.env")
+	if err := os.WriteFile(bad, []byte("OK=1\nnot an assignment\n"), 0o600); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	tests := []struct {
+		name        string
+		assignments []string
+		files       []string
+		patterns    []string
+	}{
+		{name: "invalid name", assignments: []string{"1X=2"}},
+		{name: "unset local variable", assignments: []string{"KX_TEST_SURELY_UNSET"}},
+		{name: "missing file", files: []string{filepath.Join(dir, "missing.env")}},
+		{name: "malformed line", files: []string{bad}},
+		{name: "invalid pattern", patterns: []string{"["}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ResolveEnv(tt.assignments, tt.files, tt.patterns); !IsKind(err, KindUsage) {
+				t.Errorf("ResolveEnv() error = %v, want usage error", err)
+			}
+		})
+	}
+}
+
+func TestEnvCommand(t *testing.T) {
+	env := []EnvVar{{Name: "A", Value: "1"}, {Name: "B", Value: "two words"}}
+	got := envCommand(env, []string{"printenv", "B"})
+	want := []string{"env", "A=1", "B=two words", "printenv", "B"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("envCommand() = %q, want %q", got, want)
+	}
+	if got := envCommand(nil, []string{"ls"}); fmt.Sprint(got) != "[ls]" {
+		t.Errorf("envCommand(nil) = %q, want [ls]", got)
+	}
+	if got := formatEnv(env); got != "A=1 B=two words" {
+		t.Errorf("formatEnv() = %q", got)
+	}
+	redacted := redactEnv(env)
+	if fmt.Sprint(redacted) != "[{A ***} {B ***}]" || env[0].Value != "1" {
+		t.Errorf("redactEnv() = %v (original %v)", redacted, env)
+	}
+}
+
+func TestEnvStdinCommand(t *testing.T) {
+	env := []EnvVar{{Name: "A", Value: "it's"}, {Name: "B", Value: "two\nlines"}, {Name: "C", Value: ""}}
+	command := envStdinCommand([]string{"sh", "-c", `printf '%s|%s|%s|' "$A" "$B" "${C-unset}"; cat`})
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = strings.NewReader(envPreamble(env) + "rest of stdin\n")
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("run %q: %v", command, err)
+	}
+	if want := "it's|two\nlines||rest of stdin\n"; string(out) != want {
+		t.Errorf("remote command output = %q, want %q", out, want)
+	}
+	for _, arg := range command {
+		if strings.Contains(arg, "two") {
+			t.Errorf("envStdinCommand() = %q, want no values in the arguments", command)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package cmdutil
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+)
+
+// Exit codes for kubeexec's own failures. They sit above the range commonly
+// used by remote commands so scripts can tell them apart from the exit status
+// of the command run in the pod, which is propagated unchanged.
+const (
+	ExitOK         = 0
+	ExitFailure    = 1
+	ExitUsage      = 2
+	ExitResolution = 120
+	ExitAmbiguous  = 121
+	ExitKubectl    = 122
+	ExitDenied     = 123
+	ExitCancelled  = 130
+)
+
+// ErrorKind classifies kubeexec failures for exit codes and callers.
+type ErrorKind int
+
+const (
+	// KindUsage covers invalid flags, arguments, environment and config values.
+	KindUsage ErrorKind = iota + 1
+	// KindResolution means no context, pod or container matched, or the
+	// target is not usable (e.g. the container is not running).
+	KindResolution
+	// KindAmbiguous means a choice was required but no picker was available.
+	KindAmbiguous
+	// KindKubectl covers kubectl failures and cluster errors.
+	KindKubectl
+	// KindDenied means a policy check, such as context confirmation, refused.
+	KindDenied
+	// KindCancelled means the user dismissed a picker or prompt, or a signal
+	// or the caller's context cancelled the run.
+	KindCancelled
+)
+
+// Error is a classified kubeexec failure.
+type Error struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// RemoteExitError carries the exit status of the command run in the pod.
+type RemoteExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *RemoteExitError) Error() string {
+	return fmt.Sprintf("command terminated with exit code %d", e.Code)
+}
+
+func (e *RemoteExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode maps an error returned by Run to the process exit code: 128 plus
+// the signal that cancelled it, the remote command's own status, or the code
+// for the error's kind.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var sig *SignalError
+	if errors.As(err, &sig) {
+		return sig.exitCode()
+	}
+	var remote *RemoteExitError
+	if errors.As(err, &remote) {
+		return remote.Code
+	}
+	var classified *Error
+	if errors.As(err, &classified) {
+		switch classified.Kind {
+		case KindUsage:
+			return ExitUsage
+		case KindResolution:
+			return ExitResolution
+		case KindAmbiguous:
+			return ExitAmbiguous
+		case KindKubectl:
+			return ExitKubectl
+		case KindDenied:
+			return ExitDenied
+		case KindCancelled:
+			return ExitCancelled
+		}
+	}
+	return ExitFailure
+}
+
+// IsKind reports whether err is a kubeexec error of the given kind.
+func IsKind(err error, kind ErrorKind) bool {
+	var classified *Error
+	return errors.As(err, &classified) && classified.Kind == kind
+}
+
+func usageErrorf(format string, args ...any) error {
+	return &Error{Kind: KindUsage, Err: fmt.Errorf(format, args...)}
+}
+
+func resolutionErrorf(format string, args ...any) error {
+	return &Error{Kind: KindResolution, Err: fmt.Errorf(format, args...)}
+}
+
+func ambiguousErrorf(format string, args ...any) error {
+	return &Error{Kind: KindAmbiguous, Err: fmt.Errorf(format, args...)}
+}
+
+func kubectlErrorf(format string, args ...any) error {
+	return &Error{Kind: KindKubectl, Err: fmt.Errorf(format, args...)}
+}
+
+func deniedErrorf(format string, args ...any) error {
+	return &Error{Kind: KindDenied, Err: fmt.Errorf(format, args...)}
+}
+
+func cancelledErrorf(format string, args ...any) error {
+	return &Error{Kind: KindCancelled, Err: fmt.Errorf(format, args...)}
+}
+
+// kubectlErrorLine matches the lines kubectl writes when it fails rather
+// than the remote command: "error: ...", "Error from server ...", "Unable to
+// connect to the server: ..." and klog lines such as
+// "E0101 12:00:00.000000   42 v2.go:167] ...".
+var kubectlErrorLine = regexp.MustCompile(`^(error: |Error from server|Unable to connect to the server|[EWF]\d{4} \d{2}:\d{2}:\d{2}\.\d+ )`)
+
+// execError classifies the result of a kubectl exec given the tail of its
+// stderr. kubectl passes the remote command's non-zero status through, but
+// exits 1 with an error line of its own last on stderr when it fails itself
+// (the pod is gone, the API server refused the exec); that is a kubectl
+// error. Anything else means kubectl could not run.
+func execError(err error, stderr string) error {
+	if err == nil {
+		return nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		if line := lastLine(stderr); exitErr.ExitCode() == 1 && kubectlErrorLine.MatchString(line) {
+			return kubectlErrorf("kubectl exec failed: %s", line)
+		}
+		return &RemoteExitError{Code: exitErr.ExitCode(), Err: err}
+	}
+	return &Error{Kind: KindKubectl, Err: err}
+}
@@ -0,0 +1,64 @@
+package cmdutil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, ExitOK},
+		{"plain error", errors.New("boom"), ExitFailure},
+		{"usage", usageErrorf("invalid phase %q", "x"), ExitUsage},
+		{"resolution", resolutionErrorf("no pods match %q", "api"), ExitResolution},
+		{"ambiguous", ambiguousErrorf("pod query matches multiple entries"), ExitAmbiguous},
+		{"kubectl", kubectlErrorf("kubectl get pods failed: %w", errors.New("forbidden")), ExitKubectl},
+		{"denied", deniedErrorf("context confirmation failed"), ExitDenied},
+		{"cancelled", cancelledErrorf("no pod selected"), ExitCancelled},
+		{"wrapped kind", fmt.Errorf("outer: %w", resolutionErrorf("inner")), ExitResolution},
+		{"remote exit", &RemoteExitError{Code: 3}, 3},
+		{"interrupted", &Error{Kind: KindCancelled, Err: &SignalError{Signal: os.Interrupt}}, 130},
+		{"terminated", cancelledErrorf("wait: %w", &SignalError{Signal: syscall.SIGTERM}), 143},
+		{"not running container", notRunningContainerError("pod", ContainerItem{Name: "app"}), ExitResolution},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.err); got != tt.want {
+				t.Errorf("ExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecError(t *testing.T) {
+	if err := execError(nil, ""); err != nil {
+		t.Fatalf("execError(nil) = %v, want nil", err)
+	}
+	err := execError(exitError(t, "7"), "")
+	var remote *RemoteExitError
+	if !errors.As(err, &remote) || remote.Code != 7 {
+		t.Fatalf("execError(exit 7) = %v, want RemoteExitError with code 7", err)
+	}
+	if got := ExitCode(err); got != 7 {
+		t.Errorf("ExitCode = %d, want 7", got)
+	}
+	err = execError(errors.New(`exec: "kubectl": executable file not found in $PATH`), "")
+	if !IsKind(err, KindKubectl) {
+		t.Errorf("execError(start failure) = %v, want KindKubectl", err)
+	}
+	err = execError(exitError(t, "1"), "Error from server (NotFound): pods \"api-1\" not found\n")
+	if !IsKind(err, KindKubectl) || ExitCode(err) != ExitKubectl {
+		t.Errorf("execError(kubectl failure) = %v, want KindKubectl", err)
+	}
+	err = execError(exitError(t, "1"), "grep: no match\n")
+	if !errors.As(err, &remote) || remote.Code != 1 {
+		t.Errorf("execError(exit 1) = %v, want RemoteExitError with code 1", err)
+	}
+}
@@ -0,0 +1,84 @@
+package cmdutil
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Executor runs commands in a Target with kubectl exec. Streams left nil are
+// connected to the null device, as with exec.Cmd.
+type Executor struct {
+	// Stdin is passed to the command with -i; a TTY is requested too when
+	// Stdin and Stdout are both terminals.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	// Env is set for the command on top of the container's environment.
+	// Without a TTY the values are streamed over stdin ahead of Stdin, so
+	// they stay out of process listings and the API server's audit log; a
+	// TTY session can only get them on the kubectl command line.
+	Env []EnvVar
+}
+
+// stdExecutor uses the process's own streams.
+func stdExecutor(env []EnvVar) Executor {
+	return Executor{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr, Env: env}
+}
+
+// Exec runs command in target, or an interactive shell when command is
+// empty, and waits for it. When ctx is cancelled by a signal, kubectl gets
+// the same signal, and it is killed when ctx is cancelled otherwise or it
+// does not exit in time. A non-zero exit status of the remote command is
+// returned as a *RemoteExitError; kubectl failing itself is a KindKubectl
+// error.
+func (e Executor) Exec(ctx context.Context, target Target, command ...string) error {
+	tail := &tailBuffer{max: sessionStderrTailSize}
+	if e.Stderr == nil {
+		e.Stderr = tail
+	} else {
+		e.Stderr = io.MultiWriter(e.Stderr, tail)
+	}
+	return cancelled(ctx, execError(e.run(ctx, target, command), tail.String()))
+}
+
+func (e Executor) args(target Target, command []string) []string {
+	return execArgs(target.Context, target.Namespace, target.Pod, target.Container, e.Env, command, e.Stdin != nil, e.tty())
+}
+
+func (e Executor) tty() bool {
+	return e.Stdin != nil && terminalStream(e.Stdin) && terminalStream(e.Stdout)
+}
+
+// stdin is what kubectl reads: Stdin, after the Env preamble when Env is
+// streamed rather than put on the command line.
+func (e Executor) stdin() io.Reader {
+	if len(e.Env) == 0 || e.tty() {
+		return e.Stdin
+	}
+	preamble := strings.NewReader(envPreamble(e.Env))
+	if e.Stdin == nil {
+		return preamble
+	}
+	return io.MultiReader(preamble, e.Stdin)
+}
+
+// run runs kubectl exec once. The trace shows env values as ***, like a dry
+// run.
+func (e Executor) run(ctx context.Context, target Target, command []string) error {
+	cmd := commandContext(ctx, "kubectl", e.args(target, command)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = e.stdin(), e.Stdout, e.Stderr
+	start := time.Now()
+	err := cmd.Run()
+	traced := e
+	traced.Env = redactEnv(e.Env)
+	traceFrom(ctx).kubectl(traced.args(target, command), time.Since(start), err, "")
+	return err
+}
+
+func terminalStream(stream any) bool {
+	f, ok := stream.(*os.File)
+	return ok && isTerminal(f)
+}
@@ -0,0 +1,511 @@
+package cmdutil
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// The test binary doubles as a fake kubectl and a scripted picker: the
+// harness links it into a temp dir under those names and puts the dir first
+// on PATH, so Run talks to a cluster described by a fakeCluster fixture.
+const (
+	fakeKubectlName = "kubectl"
+	fakePickerName  = "fake-picker"
+
+	fakeClusterEnv = "KUBEEXEC_TEST_CLUSTER"
+	fakeLogEnv     = "KUBEEXEC_TEST_LOG"
+	fakePicksEnv   = "KUBEEXEC_TEST_PICKS"
+)
+
+func TestMain(m *testing.M) {
+	switch filepath.Base(os.Args[0]) {
+	case fakeKubectlName:
+		os.Exit(fakeKubectlMain(os.Args[1:], os.Stdout, os.Stderr))
+	case fakePickerName:
+		os.Exit(fakePickerMain(os.Stdin, os.Stdout))
+	}
+	os.Exit(m.Run())
+}
+
+// fakeCluster is the kubeconfig and cluster state the fake kubectl serves.
+// ExecExit is the exit status of every kubectl exec and ExecStderr what it
+// writes to stderr. With ExecHang, exec
+// prints "waiting" once it handles signals and runs until SIGINT or SIGTERM,
+// which it reports on stderr.
+type fakeCluster struct {
+	CurrentContext string
+	Contexts       []fakeContext
+	ExecExit       int
+	ExecStderr     string
+	ExecHang       bool
+}
+
+// fakeContext is a kubeconfig context, its cluster's API server and the
+// pods of its cluster.
+type fakeContext struct {
+	Name      string
+	Namespace string
+	Server    string
+	Pods      []fakePod
+}
+
+type fakePod struct {
+	Namespace   string
+	Name        string
+	Phase       string
+	Node        string
+	Labels      map[string]string
+	Annotations map[string]string
+	Containers  []fakeContainer
+}
+
+// fakeContainer is a container of Kind (regular when empty); it is running
+// and ready unless Waiting names a waiting reason.
+type fakeContainer struct {
+	Name    string
+	Waiting string
+	Kind    ContainerKind
+}
+
+// fakeCall is one recorded kubectl or picker invocation. Items are the keys
+// a picker was offered.
+type fakeCall struct {
+	Tool  string
+	Args  []string
+	Items []string
+}
+
+type fakeHarness struct {
+	dir  string
+	home string
+	log  string
+}
+
+// newFakeCluster installs the fake kubectl and picker for one test, with an
+// empty HOME and working directory so no real config is read. picks are the
+// keys the picker chooses, one per picker run; "" cancels.
+func newFakeCluster(t *testing.T, cluster fakeCluster, picks ...string) *fakeHarness {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("test executable: %v", err)
+	}
+	dir := t.TempDir()
+	for _, name := range []string{fakeKubectlName, fakePickerName} {
+		if err := os.Symlink(exe, filepath.Join(dir, name)); err != nil {
+			t.Fatalf("link %s: %v", name, err)
+		}
+	}
+	data, err := json.Marshal(cluster)
+	if err != nil {
+		t.Fatalf("encode cluster: %v", err)
+	}
+	fixture := filepath.Join(dir, "cluster.json")
+	if err := os.WriteFile(fixture, data, 0o600); err != nil {
+		t.Fatalf("write cluster: %v", err)
+	}
+	home := filepath.Join(dir, "home")
+	if err := os.Mkdir(home, 0o700); err != nil {
+		t.Fatalf("create home: %v", err)
+	}
+	h := &fakeHarness{dir: dir, home: home, log: filepath.Join(dir, "calls.jsonl")}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("KUBEEXEC_CONFIG", "")
+	t.Setenv(fakeClusterEnv, fixture)
+	t.Setenv(fakeLogEnv, h.log)
+	t.Setenv(fakePicksEnv, strings.Join(picks, "\n"))
+	t.Chdir(home)
+	oldSystem, oldOverride := systemConfigPath, configFileOverride
+	systemConfigPath, configFileOverride = filepath.Join(dir, "system.toml"), ""
+	t.Cleanup(func() { systemConfigPath, configFileOverride = oldSystem, oldOverride })
+	noTerminal(t)
+	return h
+}
+
+// fakeTerminal is a confirmation terminal with scripted input.
+type fakeTerminal struct {
+	in  io.Reader
+	out *bytes.Buffer
+}
+
+func (f fakeTerminal) Read(p []byte) (int, error)  { return f.in.Read(p) }
+func (f fakeTerminal) Write(p []byte) (int, error) { return f.out.Write(p) }
+func (f fakeTerminal) Close() error                { return nil }
+
+// withTerminal answers confirmations with input, or never when input is
+// empty, and returns what they printed.
+func withTerminal(t *testing.T, input string) *bytes.Buffer {
+	t.Helper()
+	var in io.Reader = strings.NewReader(input)
+	if input == "" {
+		r, w := io.Pipe()
+		t.Cleanup(func() { w.Close() })
+		in = r
+	}
+	out := &bytes.Buffer{}
+	old := openConfirmTerminal
+	openConfirmTerminal = func() (io.ReadWriteCloser, error) { return fakeTerminal{in: in, out: out}, nil }
+	t.Cleanup(func() { openConfirmTerminal = old })
+	return out
+}
+
+// noTerminal makes confirmations find no terminal, as in CI.
+func noTerminal(t *testing.T) {
+	t.Helper()
+	old := openConfirmTerminal
+	openConfirmTerminal = func() (io.ReadWriteCloser, error) { return nil, os.ErrNotExist }
+	t.Cleanup(func() { openConfirmTerminal = old })
+}
+
+// writeConfig writes the project .kubeexec.toml.
+func (h *fakeHarness) writeConfig(t *testing.T, content string) {
+	t.Helper()
+	writeConfigFile(t, filepath.Join(h.home, projectConfigFilename), content)
+}
+
+// picker is the --picker value that runs the scripted picker.
+func (h *fakeHarness) picker() string {
+	return filepath.Join(h.dir, fakePickerName)
+}
+
+func (h *fakeHarness) calls(t *testing.T) []fakeCall {
+	t.Helper()
+	calls, err := readFakeCalls(h.log)
+	if err != nil {
+		t.Fatalf("read calls: %v", err)
+	}
+	return calls
+}
+
+// execs returns the argv of every kubectl exec.
+func (h *fakeHarness) execs(t *testing.T) [][]string {
+	t.Helper()
+	var execs [][]string
+	for _, call := range h.calls(t) {
+		if call.Tool == fakeKubectlName && contains(call.Args, "exec") {
+			execs = append(execs, call.Args)
+		}
+	}
+	return execs
+}
+
+// pickerItems returns the keys offered to each picker run.
+func (h *fakeHarness) pickerItems(t *testing.T) [][]string {
+	t.Helper()
+	var items [][]string
+	for _, call := range h.calls(t) {
+		if call.Tool == fakePickerName {
+			items = append(items, call.Items)
+		}
+	}
+	return items
+}
+
+func readFakeCalls(path string) ([]fakeCall, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var calls []fakeCall
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if line == "" {
+			continue
+		}
+		var call fakeCall
+		if err := json.Unmarshal([]byte(line), &call); err != nil {
+			return nil, err
+		}
+		calls = append(calls, call)
+	}
+	return calls, nil
+}
+
+func recordFakeCall(call fakeCall) error {
+	data, err := json.Marshal(call)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(os.Getenv(fakeLogEnv), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+func loadFakeCluster() (fakeCluster, error) {
+	var cluster fakeCluster
+	data, err := os.ReadFile(os.Getenv(fakeClusterEnv))
+	if err != nil {
+		return cluster, err
+	}
+	return cluster, json.Unmarshal(data, &cluster)
+}
+
+// fakeKubectlMain serves the kubectl calls kubeexec makes from the fixture.
+func fakeKubectlMain(args []string, stdout, stderr io.Writer) int {
+	if err := recordFakeCall(fakeCall{Tool: fakeKubectlName, Args: args}); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	cluster, err := loadFakeCluster()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	contextName := cluster.CurrentContext
+	if len(args) >= 2 && args[0] == "--context" {
+		contextName, args = args[1], args[2:]
+	}
+	var context fakeContext
+	found := false
+	for _, c := range cluster.Contexts {
+		if c.Name == contextName {
+			context, found = c, true
+		}
+	}
+
+	switch {
+	case fakeArgsHavePrefix(args, "config", "current-context"):
+		fmt.Fprintln(stdout, cluster.CurrentContext)
+		return 0
+	case fakeArgsHavePrefix(args, "config", "get-contexts"):
+		for _, c := range cluster.Contexts {
+			fmt.Fprintln(stdout, c.Name)
+		}
+		return 0
+	case fakeArgsHavePrefix(args, "config", "view"):
+		if slices.Contains(args, "jsonpath={.clusters[0].cluster.server}") {
+			fmt.Fprint(stdout, context.Server)
+			return 0
+		}
+		fmt.Fprint(stdout, context.Namespace)
+		return 0
+	}
+	if !found {
+		fmt.Fprintf(stderr, "error: context %q does not exist\n", contextName)
+		return 1
+	}
+	flags := fakeKubectlFlags(args)
+
+	switch {
+	case fakeArgsHavePrefix(args, "get", "namespaces"):
+		seen := make(map[string]bool)
+		for _, pod := range context.Pods {
+			if !seen[pod.Namespace] {
+				seen[pod.Namespace] = true
+				fmt.Fprintln(stdout, "namespace/"+pod.Namespace)
+			}
+		}
+		return 0
+	case fakeArgsHavePrefix(args, "get", "pods"):
+		if flags["--watch"] != "" {
+			return 0
+		}
+		var items []any
+		for _, pod := range context.Pods {
+			if pod.matches(flags) {
+				items = append(items, pod.object())
+			}
+		}
+		return fakeWriteJSON(stdout, map[string]any{"items": items})
+	case fakeArgsHavePrefix(args, "get", "pod"):
+		for _, pod := range context.Pods {
+			if pod.Name == args[2] && pod.Namespace == flags["-n"] {
+				return fakeWriteJSON(stdout, pod.object())
+			}
+		}
+		fmt.Fprintf(stderr, "Error from server (NotFound): pods %q not found\n", args[2])
+		return 1
+	case fakeArgsHavePrefix(args, "exec"):
+		if cluster.ExecHang {
+			signals := make(chan os.Signal, 1)
+			signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+			fmt.Fprintln(stdout, "waiting")
+			sig := <-signals
+			fmt.Fprintf(stderr, "kubectl exec: %s\n", sig)
+			return 128 + int(sig.(syscall.Signal))
+		}
+		fmt.Fprint(stderr, cluster.ExecStderr)
+		return cluster.ExecExit
+	}
+	fmt.Fprintf(stderr, "unexpected kubectl args: %q\n", args)
+	return 1
+}
+
+func fakeArgsHavePrefix(args []string, prefix ...string) bool {
+	if len(args) < len(prefix) {
+		return false
+	}
+	for i, arg := range prefix {
+		if args[i] != arg {
+			return false
+		}
+	}
+	return true
+}
+
+// fakeKubectlFlags reads the flags of a get call; flags without a value are
+// set to "true".
+func fakeKubectlFlags(args []string) map[string]string {
+	flags := make(map[string]string)
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-n", "-l", "-o", "--field-selector":
+			if i+1 < len(args) {
+				flags[args[i]] = args[i+1]
+				i++
+			}
+		case "-A", "--watch", "--output-watch-events":
+			flags[args[i]] = "true"
+		}
+	}
+	return flags
+}
+
+// matches applies the namespace, label selector and field selector of a
+// get pods call. Selectors support comma-separated key=value terms.
+func (p fakePod) matches(flags map[string]string) bool {
+	if flags["-A"] == "" && p.Namespace != flags["-n"] {
+		return false
+	}
+	for _, term := range fakeSelectorTerms(flags["-l"]) {
+		if p.Labels[term[0]] != term[1] {
+			return false
+		}
+	}
+	for _, term := range fakeSelectorTerms(flags["--field-selector"]) {
+		switch term[0] {
+		case "status.phase":
+			if p.phase() != term[1] {
+				return false
+			}
+		case "spec.nodeName":
+			if p.Node != term[1] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func fakeSelectorTerms(selector string) [][2]string {
+	var terms [][2]string
+	for _, term := range strings.Split(selector, ",") {
+		if key, value, ok := strings.Cut(term, "="); ok {
+			terms = append(terms, [2]string{key, value})
+		}
+	}
+	return terms
+}
+
+func (p fakePod) phase() string {
+	if p.Phase == "" {
+		return "Running"
+	}
+	return p.Phase
+}
+
+// object renders the pod as `kubectl get pod -o json` would.
+func (p fakePod) object() map[string]any {
+	specs := map[string][]any{"containers": {}}
+	statuses := map[string][]any{"containerStatuses": {}}
+	for _, c := range p.Containers {
+		image := "example/" + c.Name + ":1.0"
+		state := map[string]any{"running": map[string]any{}}
+		if c.Waiting != "" {
+			state = map[string]any{"waiting": map[string]any{"reason": c.Waiting}}
+		}
+		spec := map[string]any{"name": c.Name, "image": image}
+		specKey, statusKey := "containers", "containerStatuses"
+		switch c.Kind {
+		case ContainerKindSidecar:
+			spec["restartPolicy"] = "Always"
+			specKey, statusKey = "initContainers", "initContainerStatuses"
+		case ContainerKindInit:
+			specKey, statusKey = "initContainers", "initContainerStatuses"
+		case ContainerKindEphemeral:
+			specKey, statusKey = "ephemeralContainers", "ephemeralContainerStatuses"
+		}
+		specs[specKey] = append(specs[specKey], spec)
+		statuses[statusKey] = append(statuses[statusKey], map[string]any{"name": c.Name, "image": image, "ready": c.Waiting == "", "state": state})
+	}
+	spec := map[string]any{"nodeName": p.Node}
+	for key, value := range specs {
+		spec[key] = value
+	}
+	status := map[string]any{"phase": p.phase()}
+	for key, value := range statuses {
+		status[key] = value
+	}
+	return map[string]any{
+		"metadata": map[string]any{
+			"name":              p.Name,
+			"namespace":         p.Namespace,
+			"creationTimestamp": "2026-01-01T00:00:00Z",
+			"labels":            p.Labels,
+			"annotations":       p.Annotations,
+		},
+		"spec":   spec,
+		"status": status,
+	}
+}
+
+func fakeWriteJSON(out io.Writer, value any) int {
+	if err := json.NewEncoder(out).Encode(value); err != nil {
+		return 1
+	}
+	return 0
+}
+
+// fakePickerMain records the offered keys and prints the line whose key is
+// the next scripted pick. A missing or empty pick cancels like fzf does.
+func fakePickerMain(stdin io.Reader, stdout io.Writer) int {
+	var keys, lines []string
+	scanner := bufio.NewScanner(stdin)
+	for scanner.Scan() {
+		key, _, _ := strings.Cut(scanner.Text(), pickerKeyDelimiter)
+		keys = append(keys, key)
+		lines = append(lines, scanner.Text())
+	}
+	previous, err := readFakeCalls(os.Getenv(fakeLogEnv))
+	if err != nil {
+		return 2
+	}
+	run := 0
+	for _, call := range previous {
+		if call.Tool == fakePickerName {
+			run++
+		}
+	}
+	if err := recordFakeCall(fakeCall{Tool: fakePickerName, Items: keys}); err != nil {
+		return 2
+	}
+	picks := strings.Split(os.Getenv(fakePicksEnv), "\n")
+	if run >= len(picks) || picks[run] == "" {
+		return 130
+	}
+	for i, key := range keys {
+		if key == picks[run] {
+			fmt.Fprintln(stdout, lines[i])
+			return 0
+		}
+	}
+	return 1
+}
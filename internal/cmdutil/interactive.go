@@ -2,40 +2,110 @@ package cmdutil
 
 import (
 	"bytes"
+	"context"
 	"errors"
-	"io"
 	"os"
 	"os/exec"
 	"strings"
 )
 
-func ChooseWithFzf(items []string, header string) (string, error) {
-	args := []string{"--ansi", "--no-preview"}
-	if header != "" {
-		args = append(args, "--header", header)
+// pickerKeyDelimiter separates the hidden selection key from the display
+// text on each picker line.
+const pickerKeyDelimiter = "\t"
+
+// PickerItem is one picker line. Key is hidden from the user and returned on
+// selection, so matching never depends on how Display is padded or colored.
+type PickerItem struct {
+	Key     string
+	Display string
+}
+
+// plainPickerItems wraps items that serve as their own keys.
+func plainPickerItems(items []string) []PickerItem {
+	pickerItems := make([]PickerItem, 0, len(items))
+	for _, item := range items {
+		pickerItems = append(pickerItems, PickerItem{Key: item, Display: item})
 	}
-	cmd := exec.Command("fzf", args...)
+	return pickerItems
+}
+
+// Select implements Selector: none refuses, builtin prompts on the terminal
+// and every other picker runs its command.
+func (p picker) Select(ctx context.Context, sel Selection) ([]string, error) {
+	if p.disabled() {
+		return nil, ErrSelectionDisabled
+	}
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+	if p.name == pickerBuiltin {
+		return chooseKeysBuiltin(ctx, sel)
+	}
+	return p.chooseKeysExternal(ctx, sel)
+}
+
+// chooseKeysExternal runs the picker command with one `key<TAB>display` line
+// per item on stdin and reads the selected lines from stdout. Pickers with
+// listen-args they understand are reloaded in place when a live selection
+// changes.
+func (p picker) chooseKeysExternal(ctx context.Context, sel Selection) ([]string, error) {
+	cmd := commandContext(ctx, p.command, p.selectionArgs(sel)...)
 	cmd.Stderr = os.Stderr
-	cmd.Stdin = strings.NewReader(strings.Join(items, "\n") + "\n")
+	cmd.Stdin = strings.NewReader(pickerInput(sel.current(), p.ansi))
+	if sel.Changed != nil && p.live() {
+		// Without a free port or temp file the list is just not live.
+		if reloads, err := p.startReloads(cmd, sel); err == nil {
+			defer reloads.stop()
+		}
+	}
 
 	var stdout bytes.Buffer
 	cmd.Stdout = &stdout
 	if err := cmd.Run(); err != nil {
-		// fzf returns non-zero on cancel or no matches; surface real errors.
+		// fzf and skim return non-zero on cancel or no matches; surface real
+		// errors.
 		var exitErr *exec.ExitError
 		if errors.As(err, &exitErr) {
 			switch exitErr.ExitCode() {
 			case 1, 130:
-				return "", nil
+				return nil, nil
 			}
 		}
-		return "", err
+		return nil, err
 	}
+	return pickerKeys(stdout.String()), nil
+}
 
-	choiceBytes, err := io.ReadAll(&stdout)
-	if err != nil {
-		return "", err
+// pickerInput renders the picker lines; colors are dropped unless the picker
+// renders ANSI escapes.
+func pickerInput(items []PickerItem, ansi bool) string {
+	var b strings.Builder
+	for _, item := range items {
+		display := item.Display
+		if display == "" {
+			display = item.Key
+		}
+		if !ansi {
+			display = stripANSI(display)
+		}
+		b.WriteString(item.Key)
+		b.WriteString(pickerKeyDelimiter)
+		b.WriteString(display)
+		b.WriteString("\n")
 	}
+	return b.String()
+}
 
-	return strings.TrimSpace(string(choiceBytes)), nil
+// pickerKeys extracts the hidden key from each selected line.
+func pickerKeys(output string) []string {
+	var keys []string
+	for _, line := range strings.Split(output, "\n") {
+		key, _, _ := strings.Cut(line, pickerKeyDelimiter)
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
 }
@@ -0,0 +1,50 @@
+package cmdutil
+
+import (
+	"testing"
+)
+
+func TestPickerInput(t *testing.T) {
+	items := []PickerItem{
+		{Key: "ns/pod-a", Display: "pod-a  1/1  \x1b[32mRunning\x1b[0m"},
+		{Key: "ctx-only"},
+	}
+	got := pickerInput(items, true)
+	want := "ns/pod-a\tpod-a  1/1  \x1b[32mRunning\x1b[0m\nctx-only\tctx-only\n"
+	if got != want {
+		t.Errorf("pickerInput() = %q, want %q", got, want)
+	}
+}
+
+func TestPickerKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []string
+	}{
+		{"single", "ns/pod-a\tpod-a  1/1  Running\n", []string{"ns/pod-a"}},
+		{"multi", "ns/pod-a\tpod-a  1/1  Running\nns/pod-b\tpod-b  1/1  Running\n", []string{"ns/pod-a", "ns/pod-b"}},
+		{"display with spaces and tabs", "key\tvalue with\ttabs\n", []string{"key"}},
+		{"empty", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pickerKeys(tt.output)
+			if len(got) != len(tt.want) {
+				t.Fatalf("pickerKeys(%q) = %v, want %v", tt.output, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("pickerKeys(%q)[%d] = %q, want %q", tt.output, i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPickerInputWithoutANSI(t *testing.T) {
+	items := []PickerItem{{Key: "ns/pod-a", Display: "pod-a  1/1  \x1b[32mRunning\x1b[0m"}}
+	if got, want := pickerInput(items, false), "ns/pod-a\tpod-a  1/1  Running\n"; got != want {
+		t.Errorf("pickerInput(ansi=false) = %q, want %q", got, want)
+	}
+}
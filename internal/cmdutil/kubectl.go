@@ -17,18 +17,55 @@ const (
 	kubectlTimeoutPods    = 15 * time.Second
 )
 
-func CurrentContext() (string, error) {
-	out, err := runKubectl(kubectlTimeoutDefault, "config", "current-context")
+// kubectlTimeouts bound quick kubectl calls and pod lists, from the
+// kubectl-timeout and pod-list-timeout settings. Like the tracer they travel
+// with the context, so concurrent callers each keep their own.
+type kubectlTimeouts struct {
+	quick time.Duration
+	pods  time.Duration
+}
+
+type kubectlTimeoutsKey struct{}
+
+// withKubectlTimeouts returns ctx carrying the timeouts for the kubectl calls
+// made under it; zero keeps the one ctx already has.
+func withKubectlTimeouts(ctx context.Context, quick, pods time.Duration) context.Context {
+	timeouts := kubectlTimeoutsFrom(ctx)
+	if quick > 0 {
+		timeouts.quick = quick
+	}
+	if pods > 0 {
+		timeouts.pods = pods
+	}
+	return context.WithValue(ctx, kubectlTimeoutsKey{}, timeouts)
+}
+
+// withSettingsTimeouts returns ctx carrying the kubectl-timeout and
+// pod-list-timeout of settings.
+func withSettingsTimeouts(ctx context.Context, settings *Settings) context.Context {
+	return withKubectlTimeouts(ctx, settings.Duration("kubectl-timeout"), settings.Duration("pod-list-timeout"))
+}
+
+// kubectlTimeoutsFrom returns the timeouts of ctx, or the defaults.
+func kubectlTimeoutsFrom(ctx context.Context) kubectlTimeouts {
+	if timeouts, ok := ctx.Value(kubectlTimeoutsKey{}).(kubectlTimeouts); ok {
+		return timeouts
+	}
+	return kubectlTimeouts{quick: kubectlTimeoutDefault, pods: kubectlTimeoutPods}
+}
+
+func CurrentContext(ctx context.Context) (string, error) {
+	out, err := runKubectl(ctx, kubectlTimeoutsFrom(ctx).quick, "config", "current-context")
 	if err != nil {
-		return "", fmt.Errorf("kubectl config current-context failed: %w", err)
+		return "", kubectlErrorf("kubectl config current-context failed: %w", err)
 	}
 	return strings.TrimSpace(string(out)), nil
 }
 
-func GetContexts() ([]string, error) {
-	out, err := runKubectl(kubectlTimeoutDefault, "config", "get-contexts", "-o", "name")
+func GetContexts(ctx context.Context) ([]string, error) {
+	out, err := runKubectl(ctx, kubectlTimeoutsFrom(ctx).quick, "config", "get-contexts", "-o", "name")
 	if err != nil {
-		return nil, fmt.Errorf("kubectl config get-contexts failed: %w", err)
+		return nil, kubectlErrorf("kubectl config get-contexts failed: %w", err)
 	}
 	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
 	var contexts []string
@@ -43,164 +80,145 @@ func GetContexts() ([]string, error) {
 }
 
 type PodItem struct {
-	Name    string
-	Namespace string
-	Ready   string
-	Status  string
-	Display string
+	Name        string
+	Namespace   string
+	Ready       string
+	Status      string
+	Restarts    int
+	Created     time.Time
+	Node        string
+	IP          string
+	OwnerKind   string
+	OwnerName   string
+	Image       string
+	Labels      map[string]string
+	Annotations map[string]string
+	Display     string
 }
 
-func CurrentNamespace(context string) (string, error) {
+func CurrentNamespace(ctx context.Context, context string) (string, error) {
 	args := []string{"config", "view", "--minify", "--output", "jsonpath={..namespace}"}
 	args = kubectlArgs(context, args...)
-	out, err := runKubectl(kubectlTimeoutDefault, args...)
+	out, err := runKubectl(ctx, kubectlTimeoutsFrom(ctx).quick, args...)
 	if err != nil {
-		return "", fmt.Errorf("kubectl config view failed: %w", err)
+		return "", kubectlErrorf("kubectl config view failed: %w", err)
 	}
 	return strings.TrimSpace(string(out)), nil
 }
 
-func GetPods(context, namespace, selector string, allNamespaces bool) ([]PodItem, error) {
-	columns := "NAME:.metadata.name,READY:.status.containerStatuses[*].ready,STATUS:.status.phase"
-	if allNamespaces {
-		columns = "NAMESPACE:.metadata.namespace," + columns
-	}
-	args := []string{"get", "pods", "-o", "custom-columns=" + columns, "--no-headers"}
-	if allNamespaces {
-		args = append(args, "-A")
-	} else if namespace != "" {
-		args = append(args, "-n", namespace)
-	}
-	if selector != "" {
-		args = append(args, "-l", selector)
+// ClusterServer returns the API server URL of the context's cluster.
+func ClusterServer(ctx context.Context, context string) (string, error) {
+	args := kubectlArgs(context, "config", "view", "--minify", "--output", "jsonpath={.clusters[0].cluster.server}")
+	out, err := runKubectl(ctx, kubectlTimeoutsFrom(ctx).quick, args...)
+	if err != nil {
+		return "", kubectlErrorf("kubectl config view failed: %w", err)
 	}
-	args = kubectlArgs(context, args...)
-	out, err := runKubectl(kubectlTimeoutPods, args...)
+	return strings.TrimSpace(string(out)), nil
+}
+
+func GetNamespaces(ctx context.Context, context string) ([]string, error) {
+	out, err := runKubectl(ctx, kubectlTimeoutsFrom(ctx).quick, kubectlArgs(context, "get", "namespaces", "-o", "name")...)
 	if err != nil {
-		return nil, fmt.Errorf("kubectl get pods failed: %w", err)
+		return nil, kubectlErrorf("kubectl get namespaces failed: %w", err)
 	}
-	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
-	var pods []PodItem
-	maxNamespace := 0
-	maxName := 0
-	maxReady := 0
-	maxStatus := 0
-	for _, l := range lines {
-		l = strings.TrimSpace(l)
+	var namespaces []string
+	for _, l := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		l = strings.TrimPrefix(strings.TrimSpace(l), "namespace/")
 		if l == "" {
 			continue
 		}
-		fields := strings.Fields(l)
-		if len(fields) == 0 {
-			continue
-		}
-		namespaceValue := ""
-		name := ""
-		readyRaw := ""
-		status := ""
-		if allNamespaces {
-			if len(fields) > 0 {
-				namespaceValue = fields[0]
-			}
-			if len(fields) > 1 {
-				name = fields[1]
-			}
-			if len(fields) > 2 {
-				readyRaw = fields[2]
-			}
-			if len(fields) > 3 {
-				status = fields[3]
-			}
-		} else {
-			name = fields[0]
-			if len(fields) > 1 {
-				readyRaw = fields[1]
-			}
-			if len(fields) > 2 {
-				status = fields[2]
-			}
-			namespaceValue = namespace
-		}
-		ready := formatReady(readyRaw)
-		pods = append(pods, PodItem{
-			Name:      name,
-			Namespace: namespaceValue,
-			Ready:     ready,
-			Status:    status,
-		})
-		if len(namespaceValue) > maxNamespace {
-			maxNamespace = len(namespaceValue)
-		}
-		if len(name) > maxName {
-			maxName = len(name)
-		}
-		if len(ready) > maxReady {
-			maxReady = len(ready)
-		}
-		if len(status) > maxStatus {
-			maxStatus = len(status)
+		namespaces = append(namespaces, l)
+	}
+	return namespaces, nil
+}
+
+func GetPods(ctx context.Context, context, namespace string, query PodQuery, allNamespaces bool) ([]PodItem, error) {
+	objects, err := listPods(ctx, context, namespace, query, allNamespaces, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 && query.Node != "" {
+		// --node may be a partial name; retry without the exact-match pushdown.
+		traceFrom(ctx).decision("pods", "no pods on a node named exactly %q; matching it as a partial name", query.Node)
+		objects, err = listPods(ctx, context, namespace, query, allNamespaces, false)
+		if err != nil {
+			return nil, err
 		}
 	}
-	for i := range pods {
-		if allNamespaces {
-			pods[i].Display = fmt.Sprintf("%-*s  %-*s  %-*s  %-*s", maxNamespace, pods[i].Namespace, maxName, pods[i].Name, maxReady, pods[i].Ready, maxStatus, pods[i].Status)
-		} else {
-			pods[i].Display = fmt.Sprintf("%-*s  %-*s  %-*s", maxName, pods[i].Name, maxReady, pods[i].Ready, maxStatus, pods[i].Status)
+	objects, err = filterPodObjects(objects, query)
+	if err != nil {
+		return nil, err
+	}
+
+	pods := make([]PodItem, 0, len(objects))
+	for _, obj := range objects {
+		pod := podItemFromJSON(obj)
+		if pod.Namespace == "" {
+			pod.Namespace = namespace
 		}
+		pods = append(pods, pod)
 	}
+	traceFrom(ctx).decision("pods", "listed %d pods (%s)", len(pods), tracePodScope(namespace, query, allNamespaces))
 	return pods, nil
 }
 
-func GetPodContainers(context, namespace, pod string) ([]string, string, error) {
-	args := []string{
-		"get",
-		"pod",
-		pod,
-		"-o",
-		"jsonpath={.metadata.annotations.kubectl\\.kubernetes\\.io/default-container}{\"\\n\"}{range .spec.containers[*]}{.name}{\"\\n\"}{end}",
+func listPods(ctx context.Context, context, namespace string, query PodQuery, allNamespaces bool, nodePushdown bool) ([]podJSON, error) {
+	out, err := runKubectl(ctx, kubectlTimeoutsFrom(ctx).pods, podListArgs(context, namespace, query, allNamespaces, nodePushdown)...)
+	if err != nil {
+		return nil, kubectlErrorf("kubectl get pods failed: %w", err)
 	}
-	if namespace != "" {
+	objects, err := parsePodList(out)
+	if err != nil {
+		return nil, kubectlErrorf("kubectl get pods failed: %w", err)
+	}
+	return objects, nil
+}
+
+func podListArgs(context, namespace string, query PodQuery, allNamespaces bool, nodePushdown bool) []string {
+	args := []string{"get", "pods", "-o", "json"}
+	if allNamespaces {
+		args = append(args, "-A")
+	} else if namespace != "" {
 		args = append(args, "-n", namespace)
 	}
-	args = kubectlArgs(context, args...)
-	out, err := runKubectl(kubectlTimeoutDefault, args...)
-	if err != nil {
-		return nil, "", fmt.Errorf("kubectl get pod failed: %w", err)
+	if query.Selector != "" {
+		args = append(args, "-l", query.Selector)
+	}
+	if fieldSelector := query.fieldSelector(nodePushdown); fieldSelector != "" {
+		args = append(args, "--field-selector", fieldSelector)
 	}
+	return kubectlArgs(context, args...)
+}
 
-	raw := strings.TrimRight(string(out), "\n")
-	lines := strings.Split(raw, "\n")
-	defaultContainer := ""
-	if len(lines) > 0 {
-		defaultContainer = strings.TrimSpace(lines[0])
+func GetPodContainers(ctx context.Context, context, namespace, pod string) ([]ContainerItem, string, error) {
+	out, err := getPodJSON(ctx, context, namespace, pod)
+	if err != nil {
+		return nil, "", kubectlErrorf("kubectl get pod failed: %w", err)
 	}
 
-	var containers []string
-	if len(lines) > 1 {
-		for _, l := range lines[1:] {
-			l = strings.TrimSpace(l)
-			if l == "" {
-				continue
-			}
-			containers = append(containers, l)
-		}
+	containers, defaultContainer, err := parsePodContainers(out)
+	if err != nil {
+		return nil, "", kubectlErrorf("kubectl get pod failed: %w", err)
 	}
 
-	if defaultContainer != "" && !contains(containers, defaultContainer) {
+	regular := containerNames(filterContainersByKind(containers, ContainerKindRegular))
+	if defaultContainer != "" && !contains(regular, defaultContainer) {
 		defaultContainer = ""
 	}
-	if defaultContainer == "" && len(containers) == 1 {
-		defaultContainer = containers[0]
+	if defaultContainer == "" && len(regular) == 1 {
+		defaultContainer = regular[0]
 	}
 
 	return containers, defaultContainer, nil
 }
 
-func ExecPod(context, namespace, pod, container string, command []string, nonInteractive bool) error {
-	args := ExecArgs(context, namespace, pod, container, command, nonInteractive)
-	cmd := exec.Command("kubectl", args...)
-	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
-	return cmd.Run()
+func getPodJSON(ctx context.Context, context, namespace, pod string) ([]byte, error) {
+	args := []string{"get", "pod", pod, "-o", "json"}
+	if namespace != "" {
+		args = append(args, "-n", namespace)
+	}
+	args = kubectlArgs(context, args...)
+	return runKubectl(ctx, kubectlTimeoutsFrom(ctx).quick, args...)
 }
 
 func kubectlArgs(context string, args ...string) []string {
@@ -210,10 +228,9 @@ func kubectlArgs(context string, args ...string) []string {
 	return append([]string{"--context", context}, args...)
 }
 
-func runKubectl(timeout time.Duration, args ...string) ([]byte, error) {
-	ctx := context.Background()
-	var cancel context.CancelFunc
+func runKubectl(ctx context.Context, timeout time.Duration, args ...string) ([]byte, error) {
 	if timeout > 0 {
+		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, timeout)
 		defer cancel()
 	}
@@ -222,8 +239,13 @@ func runKubectl(timeout time.Duration, args ...string) ([]byte, error) {
 	var stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
+	start := time.Now()
 	err := cmd.Run()
+	traceFrom(ctx).kubectl(args, time.Since(start), err, stderr.String())
 	if err != nil {
+		if cause := context.Cause(ctx); cause != nil && !errors.Is(cause, context.DeadlineExceeded) {
+			return nil, cause
+		}
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
 			msg := strings.TrimSpace(stderr.String())
 			if msg != "" {
@@ -240,13 +262,25 @@ func runKubectl(timeout time.Duration, args ...string) ([]byte, error) {
 	return stdout.Bytes(), nil
 }
 
-func ExecArgs(context, namespace, pod, container string, command []string, nonInteractive bool) []string {
+// execArgs builds the kubectl exec arguments. Without a TTY, env is streamed
+// over stdin (see envStdinCommand), which needs -i; with one it can only go
+// on the command line.
+func execArgs(context, namespace, pod, container string, env []EnvVar, command []string, stdin, tty bool) []string {
+	if len(command) == 0 {
+		command = []string{"sh", "-c", "command -v bash >/dev/null 2>&1 && exec bash || exec sh"}
+	}
+	if len(env) > 0 && tty {
+		command = envCommand(env, command)
+	} else if len(env) > 0 {
+		stdin = true
+		command = envStdinCommand(command)
+	}
 	args := []string{"exec"}
-	if !nonInteractive {
+	if stdin {
 		args = append(args, "-i")
-		if isTerminal(os.Stdin) && isTerminal(os.Stdout) {
-			args = append(args, "-t")
-		}
+	}
+	if tty {
+		args = append(args, "-t")
 	}
 	if namespace != "" {
 		args = append(args, "-n", namespace)
@@ -255,12 +289,8 @@ func ExecArgs(context, namespace, pod, container string, command []string, nonIn
 	if container != "" {
 		args = append(args, "-c", container)
 	}
-	if len(command) > 0 {
-		args = append(args, "--")
-		args = append(args, command...)
-	} else {
-		args = append(args, "--", "sh", "-c", "command -v bash >/dev/null 2>&1 && exec bash || exec sh")
-	}
+	args = append(args, "--")
+	args = append(args, command...)
 	return kubectlArgs(context, args...)
 }
 
@@ -1,55 +1,53 @@
 package cmdutil
 
 import (
+	"context"
+	"reflect"
 	"strings"
 	"testing"
 )
 
-func TestExecArgsDefaultShell(t *testing.T) {
-	args := ExecArgs("ctx", "ns", "pod", "cont", nil, false)
-	args = removeArg(args, "-t")
-
-	expectedSuffix := []string{"--", "sh", "-c", "command -v bash >/dev/null 2>&1 && exec bash || exec sh"}
-	if !hasSubsequence(args, expectedSuffix) {
-		t.Fatalf("expected default shell command, got %v", args)
+func TestExecutorExecArgs(t *testing.T) {
+	h := newFakeCluster(t, fakeRunCluster())
+	target := Target{Context: "dev", Namespace: "payments", Pod: "api-1", Container: "app"}
+	if err := (Executor{Stdin: strings.NewReader("")}).Exec(context.Background(), target); err != nil {
+		t.Fatalf("Exec() error: %v", err)
 	}
-	if !containsArg(args, "-i") {
-		t.Fatalf("expected -i when nonInteractive=false, got %v", args)
+	if err := (Executor{}).Exec(context.Background(), target, "ls", "-la", "/"); err != nil {
+		t.Fatalf("Exec() error: %v", err)
 	}
-}
-
-func TestExecArgsCommandOverride(t *testing.T) {
-	command := []string{"ls", "-la", "/"}
-	args := ExecArgs("ctx", "ns", "pod", "cont", command, true)
-	args = removeArg(args, "-t")
-
-	if containsArg(args, "-i") {
-		t.Fatalf("did not expect -i when nonInteractive=true, got %v", args)
+	want := [][]string{
+		{"--context", "dev", "exec", "-i", "-n", "payments", "api-1", "-c", "app", "--", "sh", "-c", "command -v bash >/dev/null 2>&1 && exec bash || exec sh"},
+		{"--context", "dev", "exec", "-n", "payments", "api-1", "-c", "app", "--", "ls", "-la", "/"},
 	}
-	expected := []string{"--context", "ctx", "exec", "-n", "ns", "pod", "-c", "cont", "--", "ls", "-la", "/"}
-	if !hasSubsequence(args, expected) {
-		t.Fatalf("expected args to include %v, got %v", expected, args)
+	if got := h.execs(t); !reflect.DeepEqual(got, want) {
+		t.Errorf("kubectl exec calls = %q, want %q", got, want)
 	}
 }
 
-func containsArg(args []string, target string) bool {
-	for _, arg := range args {
-		if arg == target {
-			return true
-		}
+func TestExecArgsScriptInput(t *testing.T) {
+	args := execArgs("ctx", "ns", "pod", "cont", nil, []string{"sh", "-s", "--", "x"}, true, false)
+	expected := []string{"--context", "ctx", "exec", "-i", "-n", "ns", "pod", "-c", "cont", "--", "sh", "-s", "--", "x"}
+	if strings.Join(args, " ") != strings.Join(expected, " ") {
+		t.Fatalf("execArgs = %v, want %v", args, expected)
 	}
-	return false
 }
 
-func removeArg(args []string, target string) []string {
-	filtered := make([]string, 0, len(args))
-	for _, arg := range args {
-		if arg == target {
-			continue
-		}
-		filtered = append(filtered, arg)
+func TestExecArgsEnv(t *testing.T) {
+	env := []EnvVar{{Name: "LEVEL", Value: "debug"}}
+	args := execArgs("ctx", "ns", "pod", "cont", env, nil, true, true)
+	expected := []string{"--", "env", "LEVEL=debug", "sh", "-c", "command -v bash >/dev/null 2>&1 && exec bash || exec sh"}
+	if !hasSubsequence(args, expected) {
+		t.Fatalf("expected env prefix before the default shell in a TTY session, got %v", args)
+	}
+	args = execArgs("ctx", "ns", "pod", "cont", env, []string{"ls"}, false, false)
+	expected = append([]string{"--context", "ctx", "exec", "-i", "-n", "ns", "pod", "-c", "cont", "--"}, envStdinCommand([]string{"ls"})...)
+	if strings.Join(args, " ") != strings.Join(expected, " ") {
+		t.Fatalf("execArgs = %v, want %v", args, expected)
+	}
+	if strings.Contains(strings.Join(args, " "), "debug") {
+		t.Fatalf("execArgs = %v, want the value streamed over stdin", args)
 	}
-	return filtered
 }
 
 func hasSubsequence(args []string, expected []string) bool {
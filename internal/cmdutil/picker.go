@@ -0,0 +1,357 @@
+package cmdutil
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const (
+	pickerFzf     = "fzf"
+	pickerSkim    = "skim"
+	pickerBuiltin = "builtin"
+	pickerNone    = "none"
+)
+
+// pickerConfig is a [pickers.<name>] table: the command to run and its
+// argument templates. {header} and {delimiter} in any argument are replaced
+// with the picker header and the key delimiter; multi-args are added for
+// multi-selection and header-args only when there is a header. preview-args
+// are added when the selection has a preview command, filled in for
+// {preview}. listen-args start fzf's HTTP server on {port} so a live list can
+// be reloaded.
+type pickerConfig struct {
+	Command     string   `toml:"command"`
+	Args        []string `toml:"args"`
+	MultiArgs   []string `toml:"multi-args"`
+	HeaderArgs  []string `toml:"header-args"`
+	PreviewArgs []string `toml:"preview-args"`
+	ListenArgs  []string `toml:"listen-args"`
+	// ANSI keeps the status colors in the picker lines.
+	ANSI *bool `toml:"ansi"`
+}
+
+// defaultPickers are the built-in external backends. A [pickers.fzf] or
+// [pickers.skim] table replaces only the fields it sets.
+var defaultPickers = map[string]picker{
+	pickerFzf: {
+		command:     "fzf",
+		args:        []string{"--ansi", "--no-preview", "--delimiter", "{delimiter}", "--with-nth", "2.."},
+		multiArgs:   []string{"--multi"},
+		headerArgs:  []string{"--header", "{header}"},
+		previewArgs: []string{"--preview", "{preview}", "--preview-window", "hidden", "--bind", "?:toggle-preview"},
+		listenArgs:  []string{"--listen={port}"},
+		ansi:        true,
+	},
+	pickerSkim: {
+		command:     "sk",
+		args:        []string{"--ansi", "--delimiter", "{delimiter}", "--with-nth", "2.."},
+		multiArgs:   []string{"--multi"},
+		headerArgs:  []string{"--header", "{header}"},
+		previewArgs: []string{"--preview", "{preview}", "--preview-window", "right:50%:hidden", "--bind", "?:toggle-preview"},
+		ansi:        true,
+	},
+}
+
+// picker is the backend used when a choice is required. name is the picker
+// setting: fzf, skim, builtin, none, a [pickers.<name>] table or a command
+// line.
+type picker struct {
+	name        string
+	command     string
+	args        []string
+	multiArgs   []string
+	headerArgs  []string
+	previewArgs []string
+	listenArgs  []string
+	ansi        bool
+}
+
+func resolvePicker(name string) (picker, error) {
+	settings, err := loadSettings()
+	if err != nil {
+		return picker{}, err
+	}
+	return pickerFromSettings(settings, name)
+}
+
+// pickerFromSettings resolves name against the built-in backends and the
+// configured [pickers.<name>] tables. Any other name is run as a command
+// line, e.g. "peco --prompt 'pod> '", split like a shell would.
+func pickerFromSettings(settings *Settings, name string) (picker, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return picker{}, usageErrorf("picker must not be empty (use fzf, skim, builtin, none or a command)")
+	}
+	if name == pickerNone || name == pickerBuiltin {
+		return picker{name: name}, nil
+	}
+	p, builtin := defaultPickers[name]
+	config, configured := settings.pickers[name]
+	if !builtin && !configured {
+		fields, err := splitCommandLine(name)
+		if err != nil {
+			return picker{}, usageErrorf("picker %q: %w", name, err)
+		}
+		if fields[0] == "" {
+			return picker{}, usageErrorf("picker %q has no command", name)
+		}
+		return picker{name: name, command: fields[0], args: fields[1:]}, nil
+	}
+	p.name = name
+	if config.Command != "" {
+		p.command = config.Command
+	}
+	if config.Args != nil {
+		p.args = config.Args
+	}
+	if config.MultiArgs != nil {
+		p.multiArgs = config.MultiArgs
+	}
+	if config.HeaderArgs != nil {
+		p.headerArgs = config.HeaderArgs
+	}
+	if config.PreviewArgs != nil {
+		p.previewArgs = config.PreviewArgs
+	}
+	if config.ListenArgs != nil {
+		p.listenArgs = config.ListenArgs
+	}
+	if config.ANSI != nil {
+		p.ansi = *config.ANSI
+	}
+	if strings.TrimSpace(p.command) == "" {
+		return picker{}, usageErrorf("picker %q has no command", name)
+	}
+	return p, nil
+}
+
+// splitCommandLine splits a command line into words. Single quotes keep
+// everything up to the closing quote; in double quotes and unquoted text a
+// backslash escapes the next character. Nothing is expanded.
+func splitCommandLine(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'' && r != '\'':
+			word.WriteRune(r)
+		case r == '\\':
+			escaped, inWord = true, true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '\'' || r == '"'):
+			quote, inWord = r, true
+		case quote == 0 && (r == ' ' || r == '\t' || r == '\n'):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if escaped {
+		return nil, fmt.Errorf("trailing backslash")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// validatePickers checks the configured [pickers.<name>] tables.
+func validatePickers(settings *Settings) error {
+	for _, name := range settings.pickerNames() {
+		if name == pickerNone || name == pickerBuiltin {
+			return usageErrorf("picker %q is built in and cannot be configured", name)
+		}
+		if _, err := pickerFromSettings(settings, name); err != nil {
+			return err
+		}
+	}
+	_, err := pickerFromSettings(settings, settings.pickerName())
+	return err
+}
+
+// disabled reports whether choices must fail fast instead of prompting.
+func (p picker) disabled() bool {
+	return p.name == pickerNone
+}
+
+// check reports an ambiguous-target error when the picker command is missing.
+func (p picker) check() error {
+	if p.name == pickerBuiltin {
+		return nil
+	}
+	if _, err := exec.LookPath(p.command); err != nil {
+		return ambiguousErrorf("selection required but %s not found", p.command)
+	}
+	return nil
+}
+
+// selectionArgs fills the argument templates for sel. The {key} of a preview
+// command becomes the picker's first field, which holds the item key.
+func (p picker) selectionArgs(sel Selection) []string {
+	templates := append([]string(nil), p.args...)
+	if sel.Multi {
+		templates = append(templates, p.multiArgs...)
+	}
+	if sel.Header != "" {
+		templates = append(templates, p.headerArgs...)
+	}
+	if sel.Preview != "" {
+		templates = append(templates, p.previewArgs...)
+	}
+	preview := strings.ReplaceAll(sel.Preview, "{key}", "{1}")
+	replacer := strings.NewReplacer("{header}", sel.Header, "{delimiter}", pickerKeyDelimiter, "{preview}", preview)
+	args := make([]string, len(templates))
+	for i, template := range templates {
+		args[i] = replacer.Replace(template)
+	}
+	return args
+}
+
+// chooseKeysBuiltin prompts on the terminal, so it works when stdin and
+// stdout are redirected.
+func chooseKeysBuiltin(ctx context.Context, sel Selection) ([]string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, ambiguousErrorf("selection required but no terminal is available for the builtin picker")
+	}
+	defer tty.Close()
+	return promptKeys(ctx, tty, tty, sel)
+}
+
+// promptKeys lists the items with numbers and reads a choice: numbers select
+// (several, separated by spaces or commas, when multi), other text narrows the
+// list, and an empty line, EOF or ctx cancels. A live list is printed again
+// when it changes; numbers refer to the last list printed.
+func promptKeys(ctx context.Context, in io.Reader, out io.Writer, sel Selection) ([]string, error) {
+	lines := make(chan string)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(lines)
+		reader := bufio.NewReader(in)
+		for {
+			line, err := reader.ReadString('\n')
+			if line != "" || err == nil {
+				select {
+				case lines <- line:
+				case <-done:
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	items := sel.current()
+	query := ""
+	for {
+		shown := items
+		if query != "" {
+			shown = filterPickerItems(items, query)
+		}
+		printPickerItems(out, shown, sel.Header, sel.Multi)
+		select {
+		case <-ctx.Done():
+			fmt.Fprintln(out)
+			return nil, cancelledErrorf("%w", context.Cause(ctx))
+		case <-sel.Changed:
+			items = sel.current()
+			fmt.Fprintln(out)
+		case line, ok := <-lines:
+			if !ok {
+				return nil, nil
+			}
+			line = strings.TrimSpace(line)
+			if line == "" {
+				return nil, nil
+			}
+			if keys, numeric, ok := parseSelection(line, shown, sel.Multi); numeric {
+				if ok {
+					return keys, nil
+				}
+				fmt.Fprintf(out, "invalid selection %q\n", line)
+			} else if filtered := filterPickerItems(items, line); len(filtered) > 0 {
+				query = line
+			} else {
+				fmt.Fprintf(out, "nothing matches %q\n", line)
+			}
+		}
+	}
+}
+
+func printPickerItems(out io.Writer, items []PickerItem, header string, multi bool) {
+	if header != "" {
+		fmt.Fprintln(out, header)
+	}
+	width := len(strconv.Itoa(len(items)))
+	for i, item := range items {
+		display := item.Display
+		if display == "" {
+			display = item.Key
+		}
+		fmt.Fprintf(out, "%*d) %s\n", width, i+1, display)
+	}
+	if multi {
+		fmt.Fprint(out, "select numbers, type to filter, or press enter to cancel: ")
+	} else {
+		fmt.Fprint(out, "select a number, type to filter, or press enter to cancel: ")
+	}
+}
+
+// parseSelection reads item numbers from line. numeric is false when line is
+// filter text rather than numbers.
+func parseSelection(line string, items []PickerItem, multi bool) (keys []string, numeric bool, ok bool) {
+	fields := strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+	var indexes []int
+	for _, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, false, false
+		}
+		indexes = append(indexes, n)
+	}
+	if len(indexes) > 1 && !multi {
+		return nil, true, false
+	}
+	for _, n := range indexes {
+		if n < 1 || n > len(items) {
+			return nil, true, false
+		}
+		keys = append(keys, items[n-1].Key)
+	}
+	return keys, true, true
+}
+
+func filterPickerItems(items []PickerItem, query string) []PickerItem {
+	query = strings.ToLower(query)
+	var matches []PickerItem
+	for _, item := range items {
+		if strings.Contains(strings.ToLower(item.Key), query) || strings.Contains(strings.ToLower(stripANSI(item.Display)), query) {
+			matches = append(matches, item)
+		}
+	}
+	return matches
+}
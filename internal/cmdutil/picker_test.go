@@ -0,0 +1,319 @@
+package cmdutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPickerFromSettings(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+	writeConfigFile(t, filepath.Join(dir, ".config", "kubeexec", "kubeexec.toml"), `
+[pickers.fzf]
+args = ["--ansi", "--height", "40%", "--delimiter", "{delimiter}", "--with-nth", "2.."]
+
+[pickers.peco]
+command = "peco"
+header-args = ["--prompt", "{header}>"]
+`)
+	settings, err := ResolveSettings(nil)
+	if err != nil {
+		t.Fatalf("ResolveSettings() error: %v", err)
+	}
+
+	fzf, err := pickerFromSettings(settings, "fzf")
+	if err != nil {
+		t.Fatalf("pickerFromSettings(fzf) error: %v", err)
+	}
+	want := []string{"--ansi", "--height", "40%", "--delimiter", "\t", "--with-nth", "2..", "--multi", "--header", "pods"}
+	if fzf.command != "fzf" || !fzf.ansi || !reflect.DeepEqual(fzf.selectionArgs(Selection{Header: "pods", Multi: true}), want) {
+		t.Errorf("fzf picker = %q %v (ansi %v), want fzf %v", fzf.command, fzf.selectionArgs(Selection{Header: "pods", Multi: true}), fzf.ansi, want)
+	}
+
+	skim, err := pickerFromSettings(settings, "skim")
+	if err != nil || skim.command != "sk" {
+		t.Errorf("pickerFromSettings(skim) = %+v, %v", skim, err)
+	}
+
+	peco, err := pickerFromSettings(settings, "peco")
+	if err != nil {
+		t.Fatalf("pickerFromSettings(peco) error: %v", err)
+	}
+	if got := peco.selectionArgs(Selection{Header: "select pod"}); peco.command != "peco" || peco.ansi || !reflect.DeepEqual(got, []string{"--prompt", "select pod>"}) {
+		t.Errorf("peco picker = %q %v (ansi %v)", peco.command, got, peco.ansi)
+	}
+	if got := peco.selectionArgs(Selection{}); len(got) != 0 {
+		t.Errorf("peco args without header = %v, want none", got)
+	}
+
+	custom, err := pickerFromSettings(settings, "gum filter --limit 1")
+	if err != nil || custom.command != "gum" || !reflect.DeepEqual(custom.args, []string{"filter", "--limit", "1"}) {
+		t.Errorf("pickerFromSettings(command line) = %+v, %v", custom, err)
+	}
+	quoted, err := pickerFromSettings(settings, `peco --prompt 'pod> ' --query "a \"b\"" c\ d`)
+	if err != nil || quoted.command != "peco" || !reflect.DeepEqual(quoted.args, []string{"--prompt", "pod> ", "--query", `a "b"`, "c d"}) {
+		t.Errorf("pickerFromSettings(quoted command line) = %+v, %v", quoted, err)
+	}
+	for _, name := range []string{"peco --prompt 'pod>", `peco \`, "''"} {
+		if _, err := pickerFromSettings(settings, name); !IsKind(err, KindUsage) {
+			t.Errorf("pickerFromSettings(%q) error = %v, want usage error", name, err)
+		}
+	}
+
+	for _, name := range []string{"none", "builtin"} {
+		p, err := pickerFromSettings(settings, name)
+		if err != nil || p.name != name || p.disabled() != (name == "none") {
+			t.Errorf("pickerFromSettings(%s) = %+v, %v", name, p, err)
+		}
+	}
+	if _, err := pickerFromSettings(settings, " "); !IsKind(err, KindUsage) {
+		t.Errorf("pickerFromSettings(empty) error = %v, want usage error", err)
+	}
+}
+
+func TestValidatePickers(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+	path := filepath.Join(dir, ".config", "kubeexec", "kubeexec.toml")
+	for _, content := range []string{
+		"[pickers.none]\ncommand = \"true\"\n",
+		"[pickers.peco]\nargs = [\"--select-1\"]\n",
+		"picker = \"\"\n",
+	} {
+		writeConfigFile(t, path, content)
+		settings, err := ResolveSettings(nil)
+		if err != nil {
+			t.Fatalf("ResolveSettings(%q) error: %v", content, err)
+		}
+		if err := validatePickers(settings); !IsKind(err, KindUsage) {
+			t.Errorf("validatePickers(%q) error = %v, want usage error", content, err)
+		}
+	}
+
+	writeConfigFile(t, path, "[pickers.peco]\ncommand = \"peco\"\nunknown = 1\n")
+	if _, err := ResolveSettings(nil); !IsKind(err, KindUsage) {
+		t.Errorf("unknown picker field error = %v, want usage error", err)
+	}
+}
+
+func TestPickerName(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+	path := filepath.Join(dir, ".config", "kubeexec", "kubeexec.toml")
+	writeConfigFile(t, path, "picker = \"builtin\"\n")
+	tests := []struct {
+		name  string
+		env   string
+		flags map[string]string
+		want  string
+	}{
+		{name: "config", want: "builtin"},
+		{name: "env ignore-fzf beats config picker", env: "1", want: "none"},
+		{name: "flag picker beats env ignore-fzf", env: "1", flags: map[string]string{"picker": "skim"}, want: "skim"},
+		{name: "flag ignore-fzf", flags: map[string]string{"ignore-fzf": "true"}, want: "none"},
+		{name: "ignore-fzf off", env: "0", want: "builtin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv("KUBEEXEC_IGNORE_FZF", tt.env)
+			}
+			settings, err := ResolveSettings(tt.flags)
+			if err != nil {
+				t.Fatalf("ResolveSettings() error: %v", err)
+			}
+			var opts RunOptions
+			settings.ApplyRunOptions(&opts)
+			if opts.Picker != tt.want {
+				t.Errorf("Picker = %q, want %q", opts.Picker, tt.want)
+			}
+		})
+	}
+}
+
+func TestPromptKeys(t *testing.T) {
+	items := []PickerItem{
+		{Key: "ns/api-1", Display: "api-1  \x1b[32mRunning\x1b[0m"},
+		{Key: "ns/api-2", Display: "api-2  \x1b[31mCrashLoopBackOff\x1b[0m"},
+		{Key: "ns/worker-1", Display: "worker-1  \x1b[32mRunning\x1b[0m"},
+	}
+	tests := []struct {
+		name  string
+		input string
+		multi bool
+		want  []string
+	}{
+		{name: "number", input: "2\n", want: []string{"ns/api-2"}},
+		{name: "filter then number", input: "worker\n1\n", want: []string{"ns/worker-1"}},
+		{name: "filter on plain display", input: "crashloop\n1\n", want: []string{"ns/api-2"}},
+		{name: "multi", input: "1, 3\n", multi: true, want: []string{"ns/api-1", "ns/worker-1"}},
+		{name: "several numbers without multi", input: "1 3\n\n", want: nil},
+		{name: "out of range then pick", input: "9\n3\n", want: []string{"ns/worker-1"}},
+		{name: "cancel", input: "\n", want: nil},
+		{name: "eof", input: "", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			got, err := promptKeys(context.Background(), strings.NewReader(tt.input), &out, Selection{Items: items, Header: "select pod", Multi: tt.multi})
+			if err != nil {
+				t.Fatalf("promptKeys() error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("promptKeys(%q) = %v, want %v\n%s", tt.input, got, tt.want, out.String())
+			}
+		})
+	}
+}
+
+func TestPromptKeysReloads(t *testing.T) {
+	lists := [][]PickerItem{
+		{{Key: "ns/api-1", Display: "api-1  Running"}},
+		{{Key: "ns/api-1", Display: "api-1  Deleted"}, {Key: "ns/api-2", Display: "api-2  Running"}},
+	}
+	changed := make(chan struct{}, 1)
+	changed <- struct{}{}
+	reloaded := make(chan struct{})
+	calls := 0
+	sel := Selection{
+		Refresh: func() []PickerItem {
+			calls++
+			if calls == 2 {
+				close(reloaded)
+			}
+			return lists[min(calls, len(lists))-1]
+		},
+		Changed: changed,
+	}
+	in, input := io.Pipe()
+	go func() {
+		<-reloaded
+		io.WriteString(input, "2\n")
+	}()
+	var out bytes.Buffer
+	got, err := promptKeys(context.Background(), in, &out, sel)
+	if err != nil || !reflect.DeepEqual(got, []string{"ns/api-2"}) {
+		t.Errorf("promptKeys() after a reload = %v, %v, want [ns/api-2]\n%s", got, err, out.String())
+	}
+	if !strings.Contains(out.String(), "2) api-2  Running") {
+		t.Errorf("reloaded list not printed:\n%s", out.String())
+	}
+}
+
+func TestPromptKeysCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	in, _ := io.Pipe()
+	var out bytes.Buffer
+	keys, err := promptKeys(ctx, in, &out, Selection{Items: []PickerItem{{Key: "ns/api-1", Display: "api-1"}}})
+	if keys != nil || !IsKind(err, KindCancelled) {
+		t.Errorf("promptKeys() with a cancelled context = %v, %v, want cancelled error", keys, err)
+	}
+}
+
+func TestChooseKeysExternal(t *testing.T) {
+	items := []PickerItem{
+		{Key: "ns/api-1", Display: "api-1  \x1b[32mRunning\x1b[0m"},
+		{Key: "ns/api-2", Display: "api-2  Running"},
+	}
+	p := picker{name: "tail -n 1", command: "tail", args: []string{"-n", "1"}}
+	if err := p.check(); err != nil {
+		t.Skipf("tail not available: %v", err)
+	}
+	got, err := p.Select(context.Background(), Selection{Kind: "pod", Header: "select pod", Items: items})
+	if err != nil || !reflect.DeepEqual(got, []string{"ns/api-2"}) {
+		t.Errorf("Select() = %v, %v, want [ns/api-2]", got, err)
+	}
+
+	p = picker{name: "false", command: "false"}
+	if keys, err := p.Select(context.Background(), Selection{Items: items}); err != nil || keys != nil {
+		t.Errorf("Select() with exit status 1 = %v, %v, want cancel", keys, err)
+	}
+	if _, err := (picker{name: pickerNone}).Select(context.Background(), Selection{Items: items}); !errors.Is(err, ErrSelectionDisabled) {
+		t.Errorf("Select() with picker none = %v, want ErrSelectionDisabled", err)
+	}
+	if err := (picker{name: "missing", command: "kubeexec-no-such-picker"}).check(); !IsKind(err, KindAmbiguous) {
+		t.Errorf("check() for a missing command = %v, want ambiguous error", err)
+	}
+}
+
+func TestPickerReloads(t *testing.T) {
+	var actions []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		actions = append(actions, string(body))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "items")
+	r := &pickerReloads{url: server.URL, apiKey: "secret", path: path}
+	items := []PickerItem{{Key: "ns/api-1", Display: "api-1  \x1b[90mDeleted\x1b[0m"}}
+	if err := r.reload(items); err != nil {
+		t.Fatalf("reload() error: %v", err)
+	}
+	if want := []string{"reload(cat " + shellQuote(path) + ")"}; !reflect.DeepEqual(actions, want) {
+		t.Errorf("actions = %q, want %q", actions, want)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "ns/api-1\tapi-1  Deleted\n" {
+		t.Errorf("reload file = %q", data)
+	}
+	r.apiKey = "wrong"
+	if err := r.reload(items); err == nil {
+		t.Errorf("reload() with a rejected key succeeded")
+	}
+}
+
+func TestPickerLive(t *testing.T) {
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{version: "0.44.1 (d7d2ac3)", want: true},
+		{version: "0.36.0", want: true},
+		{version: "1.0.0 (brew)", want: true},
+		{version: "0.35.1 (brew)"},
+		{version: "0.29.0"},
+		{version: "unknown"},
+	}
+	for _, tt := range tests {
+		if got := fzfHasListen(tt.version); got != tt.want {
+			t.Errorf("fzfHasListen(%q) = %t, want %t", tt.version, got, tt.want)
+		}
+	}
+
+	dir := t.TempDir()
+	fzf := filepath.Join(dir, "fzf")
+	if err := os.WriteFile(fzf, []byte("#!/bin/sh\necho '0.35.1 (brew)'\n"), 0o755); err != nil {
+		t.Fatalf("write fzf: %v", err)
+	}
+	pickers := []struct {
+		picker picker
+		want   bool
+	}{
+		{picker: picker{name: pickerBuiltin}, want: true},
+		{picker: picker{name: pickerFzf, command: fzf, listenArgs: []string{"--listen={port}"}}},
+		{picker: picker{name: "peco", command: "peco", listenArgs: []string{"--listen={port}"}}, want: true},
+		{picker: picker{name: "gum", command: "gum"}},
+	}
+	for _, tt := range pickers {
+		if got := tt.picker.live(); got != tt.want {
+			t.Errorf("%s picker live() = %t, want %t", tt.picker.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package cmdutil
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pickerReloadRetry is how soon a failed reload is tried again, e.g. while
+// fzf is still starting its server.
+const pickerReloadRetry = 500 * time.Millisecond
+
+// fzfVersionTimeout bounds fzf --version.
+const fzfVersionTimeout = 2 * time.Second
+
+var pickerReloadClient = &http.Client{Timeout: time.Second}
+
+// fzfListenVersion is the first fzf release with --listen.
+var fzfListenVersion = [2]int{0, 36}
+
+var fzfVersionPattern = regexp.MustCompile(`^(\d+)\.(\d+)`)
+
+// listenSupport caches, per fzf command, whether it has --listen, so
+// fzf --version runs once per process.
+var listenSupport sync.Map
+
+// live reports whether the picker shows a list that changes while it is open:
+// the builtin prompt, or a command with listen-args it understands.
+func (p picker) live() bool {
+	if p.name == pickerBuiltin {
+		return true
+	}
+	return len(p.listenArgs) > 0 && p.listens()
+}
+
+// listens reports whether the picker command takes its listen-args. Only fzf
+// is checked, by its version; older fzf exits with "unknown option". Other
+// commands are trusted to accept what their table configures.
+func (p picker) listens() bool {
+	if filepath.Base(p.command) != "fzf" {
+		return true
+	}
+	if ok, found := listenSupport.Load(p.command); found {
+		return ok.(bool)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), fzfVersionTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, p.command, "--version").Output()
+	ok := err == nil && fzfHasListen(string(out))
+	listenSupport.Store(p.command, ok)
+	return ok
+}
+
+// fzfHasListen reports whether fzf --version output, e.g. "0.44.1 (d7d2ac3)",
+// is a release with --listen.
+func fzfHasListen(version string) bool {
+	m := fzfVersionPattern.FindStringSubmatch(strings.TrimSpace(version))
+	if m == nil {
+		return false
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	return major > fzfListenVersion[0] || major == fzfListenVersion[0] && minor >= fzfListenVersion[1]
+}
+
+// pickerReloads pushes a changing list into a running picker through fzf's
+// --listen HTTP API: each change is written to a file and the picker is asked
+// to reload(cat <file>). FZF_API_KEY keeps other local users from driving it.
+type pickerReloads struct {
+	url      string
+	apiKey   string
+	path     string
+	ansi     bool
+	done     chan struct{}
+	finished chan struct{}
+}
+
+// startReloads adds the listen-args to cmd and reloads the picker whenever
+// sel changes, until stop.
+func (p picker) startReloads(cmd *exec.Cmd, sel Selection) (*pickerReloads, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+	listener.Close()
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	file, err := os.CreateTemp("", "kubeexec-picker-*")
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+
+	r := &pickerReloads{
+		url:      "http://127.0.0.1:" + port,
+		apiKey:   hex.EncodeToString(key),
+		path:     file.Name(),
+		ansi:     p.ansi,
+		done:     make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+	replacer := strings.NewReplacer("{port}", port)
+	for _, arg := range p.listenArgs {
+		cmd.Args = append(cmd.Args, replacer.Replace(arg))
+	}
+	cmd.Env = append(os.Environ(), "FZF_API_KEY="+r.apiKey)
+	go r.run(sel)
+	return r, nil
+}
+
+func (r *pickerReloads) run(sel Selection) {
+	defer close(r.finished)
+	var retry <-chan time.Time
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-sel.Changed:
+		case <-retry:
+		}
+		retry = nil
+		if err := r.reload(sel.current()); err != nil {
+			retry = time.After(pickerReloadRetry)
+		}
+	}
+}
+
+func (r *pickerReloads) reload(items []PickerItem) error {
+	next := r.path + ".next"
+	if err := os.WriteFile(next, []byte(pickerInput(items, r.ansi)), 0o600); err != nil {
+		return err
+	}
+	// Renaming keeps a reload that is still reading the old file consistent.
+	if err := os.Rename(next, r.path); err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, r.url, strings.NewReader("reload(cat "+shellQuote(r.path)+")"))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-api-key", r.apiKey)
+	resp, err := pickerReloadClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("picker reload: %s", resp.Status)
+	}
+	return nil
+}
+
+func (r *pickerReloads) stop() {
+	close(r.done)
+	<-r.finished
+	os.Remove(r.path)
+	os.Remove(r.path + ".next")
+}
@@ -0,0 +1,249 @@
+package cmdutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// podJSON is the subset of a Pod object kubeexec reads from `kubectl get -o json`.
+type podJSON struct {
+	Metadata struct {
+		Name              string               `json:"name"`
+		Namespace         string               `json:"namespace"`
+		CreationTimestamp time.Time            `json:"creationTimestamp"`
+		DeletionTimestamp *time.Time           `json:"deletionTimestamp"`
+		Labels            map[string]string    `json:"labels"`
+		Annotations       map[string]string    `json:"annotations"`
+		OwnerReferences   []ownerReferenceJSON `json:"ownerReferences"`
+	} `json:"metadata"`
+	Spec struct {
+		NodeName            string              `json:"nodeName"`
+		Containers          []containerSpecJSON `json:"containers"`
+		InitContainers      []containerSpecJSON `json:"initContainers"`
+		EphemeralContainers []containerSpecJSON `json:"ephemeralContainers"`
+	} `json:"spec"`
+	Status struct {
+		Phase                      string                `json:"phase"`
+		PodIP                      string                `json:"podIP"`
+		ContainerStatuses          []containerStatusJSON `json:"containerStatuses"`
+		InitContainerStatuses      []containerStatusJSON `json:"initContainerStatuses"`
+		EphemeralContainerStatuses []containerStatusJSON `json:"ephemeralContainerStatuses"`
+	} `json:"status"`
+}
+
+type ownerReferenceJSON struct {
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Controller bool   `json:"controller"`
+}
+
+type podListJSON struct {
+	Items []podJSON `json:"items"`
+}
+
+var podPhases = []string{"Pending", "Running", "Succeeded", "Failed", "Unknown"}
+
+// PodQuery narrows the pods listed by GetPods. Selector, FieldSelector and
+// Phase are passed to the API server; Node is pushed down when it names a
+// node exactly and matched as a substring otherwise; Owner is always
+// matched client-side because owner references are not field-selectable.
+type PodQuery struct {
+	Selector      string
+	FieldSelector string
+	Node          string
+	Owner         string
+	Phase         string
+}
+
+// NormalizePhase maps a case-insensitive phase name to its API spelling.
+func NormalizePhase(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	for _, phase := range podPhases {
+		if strings.EqualFold(phase, value) {
+			return phase, nil
+		}
+	}
+	return "", usageErrorf("invalid phase %q (use %s)", value, strings.Join(podPhases, ", "))
+}
+
+// ParseOwner splits an owner filter of the form kind/name. A bare name
+// matches owners of any kind.
+func ParseOwner(value string) (string, string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", "", nil
+	}
+	kind, name, found := strings.Cut(value, "/")
+	if !found {
+		return "", value, nil
+	}
+	kind = strings.TrimSpace(kind)
+	name = strings.TrimSpace(name)
+	if kind == "" || name == "" {
+		return "", "", usageErrorf("invalid owner %q (expected kind/name)", value)
+	}
+	return kind, name, nil
+}
+
+func (q PodQuery) fieldSelector(nodePushdown bool) string {
+	var parts []string
+	if q.FieldSelector != "" {
+		parts = append(parts, q.FieldSelector)
+	}
+	if q.Phase != "" {
+		parts = append(parts, "status.phase="+q.Phase)
+	}
+	if nodePushdown && q.Node != "" {
+		parts = append(parts, "spec.nodeName="+q.Node)
+	}
+	return strings.Join(parts, ",")
+}
+
+// describe renders the query for picker headers.
+func (q PodQuery) describe() []string {
+	var parts []string
+	if q.Selector != "" {
+		parts = append(parts, "selector: "+q.Selector)
+	}
+	if q.FieldSelector != "" {
+		parts = append(parts, "field-selector: "+q.FieldSelector)
+	}
+	if q.Node != "" {
+		parts = append(parts, "node: "+q.Node)
+	}
+	if q.Owner != "" {
+		parts = append(parts, "owner: "+q.Owner)
+	}
+	if q.Phase != "" {
+		parts = append(parts, "phase: "+q.Phase)
+	}
+	return parts
+}
+
+func parsePodList(data []byte) ([]podJSON, error) {
+	var list podListJSON
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("parse pods: %w", err)
+	}
+	return list.Items, nil
+}
+
+func filterPodObjects(pods []podJSON, q PodQuery) ([]podJSON, error) {
+	ownerKind, ownerName, err := ParseOwner(q.Owner)
+	if err != nil {
+		return nil, err
+	}
+	var matches []podJSON
+	for _, pod := range pods {
+		if q.Node != "" && !strings.Contains(pod.Spec.NodeName, q.Node) {
+			continue
+		}
+		if ownerName != "" && !podOwnedBy(pod, ownerKind, ownerName) {
+			continue
+		}
+		matches = append(matches, pod)
+	}
+	return matches, nil
+}
+
+// podOwnedBy matches the pod's owner references against kind/name. A
+// Deployment owner is matched through the ReplicaSet it manages, whose name
+// is the deployment name followed by the pod template hash.
+func podOwnedBy(pod podJSON, kind, name string) bool {
+	for _, ref := range pod.Metadata.OwnerReferences {
+		if kind == "" || strings.EqualFold(ref.Kind, kind) {
+			if ref.Name == name {
+				return true
+			}
+		}
+		if isDeploymentKind(kind) && ref.Kind == "ReplicaSet" {
+			if hash, ok := strings.CutPrefix(ref.Name, name+"-"); ok && hash != "" && !strings.Contains(hash, "-") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isDeploymentKind(kind string) bool {
+	switch strings.ToLower(kind) {
+	case "deployment", "deploy", "deployments":
+		return true
+	}
+	return false
+}
+
+func podItemFromJSON(pod podJSON) PodItem {
+	ready := make([]string, 0, len(pod.Status.ContainerStatuses))
+	restarts := 0
+	for _, st := range pod.Status.ContainerStatuses {
+		ready = append(ready, strconv.FormatBool(st.Ready))
+		restarts += st.RestartCount
+	}
+	item := PodItem{
+		Name:        pod.Metadata.Name,
+		Namespace:   pod.Metadata.Namespace,
+		Ready:       formatReady(strings.Join(ready, ",")),
+		Status:      podStatus(pod),
+		Restarts:    restarts,
+		Created:     pod.Metadata.CreationTimestamp,
+		Node:        pod.Spec.NodeName,
+		IP:          pod.Status.PodIP,
+		Image:       podDefaultImage(pod),
+		Labels:      pod.Metadata.Labels,
+		Annotations: pod.Metadata.Annotations,
+	}
+	if owner, ok := podController(pod); ok {
+		item.OwnerKind = owner.Kind
+		item.OwnerName = owner.Name
+	}
+	return item
+}
+
+// podStatus mirrors the STATUS column of kubectl get pods: Terminating for
+// pods being deleted, the first waiting reason (e.g. CrashLoopBackOff), or
+// the pod phase.
+func podStatus(pod podJSON) string {
+	if pod.Metadata.DeletionTimestamp != nil {
+		return "Terminating"
+	}
+	for _, st := range pod.Status.ContainerStatuses {
+		if waiting, ok := st.State[containerStateWaiting]; ok && waiting.Reason != "" {
+			return waiting.Reason
+		}
+	}
+	return pod.Status.Phase
+}
+
+func podController(pod podJSON) (ownerReferenceJSON, bool) {
+	for _, ref := range pod.Metadata.OwnerReferences {
+		if ref.Controller {
+			return ref, true
+		}
+	}
+	if len(pod.Metadata.OwnerReferences) > 0 {
+		return pod.Metadata.OwnerReferences[0], true
+	}
+	return ownerReferenceJSON{}, false
+}
+
+// podDefaultImage returns the image of the default container: the one named
+// by the default-container annotation, or the first container.
+func podDefaultImage(pod podJSON) string {
+	name := pod.Metadata.Annotations[defaultContainerAnnotation]
+	for _, c := range pod.Spec.Containers {
+		if c.Name == name {
+			return c.Image
+		}
+	}
+	if len(pod.Spec.Containers) > 0 {
+		return pod.Spec.Containers[0].Image
+	}
+	return ""
+}
@@ -0,0 +1,121 @@
+package cmdutil
+
+import (
+	"testing"
+)
+
+const podListJSONFixture = `{"items": [
+  {"metadata": {"name": "api-7d9f8c-abcde", "namespace": "shop", "ownerReferences": [{"kind": "ReplicaSet", "name": "api-7d9f8c", "controller": true}]},
+   "spec": {"nodeName": "ip-10-1-2-3.ec2.internal"},
+   "status": {"phase": "Running", "containerStatuses": [{"name": "app", "ready": true}, {"name": "proxy", "ready": false}]}},
+  {"metadata": {"name": "api-canary-5f6b7c-zzzzz", "namespace": "shop", "ownerReferences": [{"kind": "ReplicaSet", "name": "api-canary-5f6b7c", "controller": true}]},
+   "spec": {"nodeName": "ip-10-1-2-4.ec2.internal"},
+   "status": {"phase": "Running", "containerStatuses": [{"name": "app", "ready": true}]}},
+  {"metadata": {"name": "db-0", "namespace": "shop", "ownerReferences": [{"kind": "StatefulSet", "name": "db", "controller": true}]},
+   "spec": {"nodeName": "ip-10-1-2-3.ec2.internal"},
+   "status": {"phase": "Pending"}}
+]}`
+
+func TestParsePodListAndItem(t *testing.T) {
+	objects, err := parsePodList([]byte(podListJSONFixture))
+	if err != nil {
+		t.Fatalf("parsePodList error: %v", err)
+	}
+	if len(objects) != 3 {
+		t.Fatalf("expected 3 pods, got %d", len(objects))
+	}
+	item := podItemFromJSON(objects[0])
+	if item.Name != "api-7d9f8c-abcde" || item.Namespace != "shop" || item.Ready != "1/2" || item.Status != "Running" || item.Node != "ip-10-1-2-3.ec2.internal" {
+		t.Errorf("unexpected pod item: %+v", item)
+	}
+	if got := podItemFromJSON(objects[2]).Ready; got != "-" {
+		t.Errorf("ready for pod without statuses = %q, want %q", got, "-")
+	}
+}
+
+func TestFilterPodObjects(t *testing.T) {
+	objects, err := parsePodList([]byte(podListJSONFixture))
+	if err != nil {
+		t.Fatalf("parsePodList error: %v", err)
+	}
+	tests := []struct {
+		name  string
+		query PodQuery
+		want  []string
+	}{
+		{"no filters", PodQuery{}, []string{"api-7d9f8c-abcde", "api-canary-5f6b7c-zzzzz", "db-0"}},
+		{"partial node", PodQuery{Node: "ip-10-1-2-3"}, []string{"api-7d9f8c-abcde", "db-0"}},
+		{"deployment owner", PodQuery{Owner: "deployment/api"}, []string{"api-7d9f8c-abcde"}},
+		{"deploy alias", PodQuery{Owner: "deploy/api-canary"}, []string{"api-canary-5f6b7c-zzzzz"}},
+		{"replicaset owner", PodQuery{Owner: "ReplicaSet/api-7d9f8c"}, []string{"api-7d9f8c-abcde"}},
+		{"statefulset owner case-insensitive", PodQuery{Owner: "statefulset/db"}, []string{"db-0"}},
+		{"owner without kind", PodQuery{Owner: "db"}, []string{"db-0"}},
+		{"node and owner", PodQuery{Node: "ip-10-1-2-4", Owner: "deployment/api"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := filterPodObjects(objects, tt.query)
+			if err != nil {
+				t.Fatalf("filterPodObjects error: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("filterPodObjects(%+v) returned %d pods, want %d", tt.query, len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i].Metadata.Name != tt.want[i] {
+					t.Errorf("pod[%d] = %q, want %q", i, got[i].Metadata.Name, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPodQueryFieldSelector(t *testing.T) {
+	q := PodQuery{FieldSelector: "metadata.name!=db-0", Phase: "Running", Node: "ip-10-1-2-3"}
+	if got, want := q.fieldSelector(true), "metadata.name!=db-0,status.phase=Running,spec.nodeName=ip-10-1-2-3"; got != want {
+		t.Errorf("fieldSelector(true) = %q, want %q", got, want)
+	}
+	if got, want := q.fieldSelector(false), "metadata.name!=db-0,status.phase=Running"; got != want {
+		t.Errorf("fieldSelector(false) = %q, want %q", got, want)
+	}
+}
+
+func TestNormalizePhase(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"running", "Running", false},
+		{" PENDING ", "Pending", false},
+		{"CrashLoopBackOff", "", true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizePhase(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("NormalizePhase(%q) = %q, %v; want %q, err=%v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseOwner(t *testing.T) {
+	tests := []struct {
+		value    string
+		wantKind string
+		wantName string
+		wantErr  bool
+	}{
+		{"deployment/api", "deployment", "api", false},
+		{"api", "", "api", false},
+		{"", "", "", false},
+		{"deployment/", "", "", true},
+		{"/api", "", "", true},
+	}
+	for _, tt := range tests {
+		kind, name, err := ParseOwner(tt.value)
+		if (err != nil) != tt.wantErr || kind != tt.wantKind || name != tt.wantName {
+			t.Errorf("ParseOwner(%q) = (%q, %q, %v); want (%q, %q, err=%v)", tt.value, kind, name, err, tt.wantKind, tt.wantName, tt.wantErr)
+		}
+	}
+}
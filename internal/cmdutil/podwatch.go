@@ -0,0 +1,178 @@
+package cmdutil
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os/exec"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const (
+	// podStatusDeleted marks pods that were deleted while the picker was open.
+	podStatusDeleted = "Deleted"
+	// podWatchInterval is the shortest time between two picker updates, so a
+	// rollout does not redraw the list for every event.
+	podWatchInterval = time.Second
+)
+
+// podWatchEvent is one event of `kubectl get pods --watch
+// --output-watch-events -o json`.
+type podWatchEvent struct {
+	Type   string  `json:"type"`
+	Object podJSON `json:"object"`
+}
+
+// podWatch keeps a pod list current from a pod watch while a picker is open.
+// Pods keep their position so a number or cursor chosen from an older list
+// still points at the same pod: new pods are appended and deleted ones stay,
+// marked Deleted, until the picker closes.
+type podWatch struct {
+	namespace string
+	query     PodQuery
+	layout    podLayout
+	// changed receives a value, at most once per podWatchInterval, after the
+	// list changed.
+	changed chan struct{}
+	updates chan struct{}
+	cmd     *exec.Cmd
+
+	mu    sync.Mutex
+	pods  []PodItem
+	index map[string]int
+}
+
+func newPodWatch(pods []PodItem, namespace string, query PodQuery, layout podLayout) *podWatch {
+	w := &podWatch{
+		namespace: namespace,
+		query:     query,
+		layout:    layout,
+		changed:   make(chan struct{}, 1),
+		updates:   make(chan struct{}, 1),
+		pods:      append([]PodItem(nil), pods...),
+		index:     make(map[string]int, len(pods)),
+	}
+	for i, pod := range w.pods {
+		w.index[pod.Key()] = i
+	}
+	go w.notify()
+	return w
+}
+
+// startPodWatch watches the pods GetPods listed, starting from pods in their
+// picker order. The node filter is matched client-side, like a partial
+// --node name.
+func startPodWatch(ctx context.Context, context, namespace string, query PodQuery, allNamespaces bool, pods []PodItem, layout podLayout) (*podWatch, error) {
+	args := append(podListArgs(context, namespace, query, allNamespaces, false), "--watch", "--output-watch-events")
+	cmd := exec.CommandContext(ctx, "kubectl", args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, kubectlErrorf("kubectl get pods --watch failed: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, kubectlErrorf("kubectl get pods --watch failed: %w", err)
+	}
+	w := newPodWatch(pods, namespace, query, layout)
+	w.cmd = cmd
+	go func() {
+		w.read(stdout)
+		cmd.Wait()
+	}()
+	return w, nil
+}
+
+// stop ends the watch; the list keeps its last state.
+func (w *podWatch) stop() {
+	if w.cmd != nil && w.cmd.Process != nil {
+		w.cmd.Process.Kill()
+	}
+}
+
+// read applies events until the watch ends.
+func (w *podWatch) read(r io.Reader) {
+	defer close(w.updates)
+	decoder := json.NewDecoder(r)
+	for {
+		var event podWatchEvent
+		if err := decoder.Decode(&event); err != nil {
+			return
+		}
+		if w.apply(event) {
+			select {
+			case w.updates <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func (w *podWatch) notify() {
+	for range w.updates {
+		select {
+		case w.changed <- struct{}{}:
+		default:
+		}
+		time.Sleep(podWatchInterval)
+	}
+}
+
+// apply updates the list with one event and reports whether it changed.
+// Pods that stop matching the query count as deleted.
+func (w *podWatch) apply(event podWatchEvent) bool {
+	if event.Type != "ADDED" && event.Type != "MODIFIED" && event.Type != "DELETED" {
+		return false
+	}
+	matches, err := filterPodObjects([]podJSON{event.Object}, w.query)
+	if err != nil {
+		return false
+	}
+	pod := podItemFromJSON(event.Object)
+	if pod.Namespace == "" {
+		pod.Namespace = w.namespace
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	i, known := w.index[pod.Key()]
+	switch {
+	case event.Type == "DELETED" || len(matches) == 0:
+		if !known || w.pods[i].Status == podStatusDeleted {
+			return false
+		}
+		w.pods[i].Status = podStatusDeleted
+		w.pods[i].Ready = "-"
+	case known:
+		// The watch starts by replaying the pods already listed.
+		pod.Display = w.pods[i].Display
+		if reflect.DeepEqual(pod, w.pods[i]) {
+			return false
+		}
+		w.pods[i] = pod
+	default:
+		w.index[pod.Key()] = len(w.pods)
+		w.pods = append(w.pods, pod)
+	}
+	return true
+}
+
+// items returns the current pods with their picker displays.
+func (w *podWatch) items() []PodItem {
+	w.mu.Lock()
+	pods := append([]PodItem(nil), w.pods...)
+	w.mu.Unlock()
+	fillPodDisplays(pods, w.layout)
+	return pods
+}
+
+// lookup returns the current state of the pod with key.
+func (w *podWatch) lookup(key string) (PodItem, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	i, ok := w.index[key]
+	if !ok {
+		return PodItem{}, false
+	}
+	return w.pods[i], true
+}
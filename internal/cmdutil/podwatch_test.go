@@ -0,0 +1,83 @@
+package cmdutil
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func watchEvent(t *testing.T, data string) podWatchEvent {
+	t.Helper()
+	var event podWatchEvent
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		t.Fatalf("parse event: %v", err)
+	}
+	return event
+}
+
+func TestPodWatchApply(t *testing.T) {
+	layout := podLayout{columns: []podColumn{{name: "name", value: podColumnValues["name"]}, {name: "status", value: podColumnValues["status"]}}}
+	pods := []PodItem{
+		{Name: "api-1", Namespace: "ns", Ready: "-", Status: "Running", Node: "node-a"},
+		{Name: "api-2", Namespace: "ns", Ready: "-", Status: "Running", Node: "node-a"},
+	}
+	w := newPodWatch(pods, "ns", PodQuery{Node: "node-a"}, layout)
+
+	events := []struct {
+		event   string
+		changed bool
+	}{
+		{event: `{"type":"ADDED","object":{"metadata":{"name":"api-1","namespace":"ns"},"spec":{"nodeName":"node-a"},"status":{"phase":"Running"}}}`, changed: false},
+		{event: `{"type":"MODIFIED","object":{"metadata":{"name":"api-2","namespace":"ns"},"spec":{"nodeName":"node-a"},"status":{"phase":"Running","containerStatuses":[{"ready":false,"state":{"waiting":{"reason":"CrashLoopBackOff"}}}]}}}`, changed: true},
+		{event: `{"type":"ADDED","object":{"metadata":{"name":"api-0","namespace":"ns"},"spec":{"nodeName":"node-a"},"status":{"phase":"Pending"}}}`, changed: true},
+		{event: `{"type":"ADDED","object":{"metadata":{"name":"other","namespace":"ns"},"spec":{"nodeName":"node-b"},"status":{"phase":"Running"}}}`, changed: false},
+		{event: `{"type":"DELETED","object":{"metadata":{"name":"api-1","namespace":"ns"},"spec":{"nodeName":"node-a"},"status":{"phase":"Running"}}}`, changed: true},
+		{event: `{"type":"DELETED","object":{"metadata":{"name":"api-1","namespace":"ns"},"spec":{"nodeName":"node-a"},"status":{"phase":"Running"}}}`, changed: false},
+		{event: `{"type":"BOOKMARK","object":{"metadata":{"name":"api-2","namespace":"ns"}}}`, changed: false},
+	}
+	for _, tt := range events {
+		if got := w.apply(watchEvent(t, tt.event)); got != tt.changed {
+			t.Errorf("apply(%s) = %v, want %v", tt.event, got, tt.changed)
+		}
+	}
+
+	var got []string
+	for _, pod := range w.items() {
+		got = append(got, pod.Display)
+	}
+	want := []string{"api-1  Deleted", "api-2  CrashLoopBackOff", "api-0  Pending"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("items() = %q, want %q (positions kept, new pods appended)", got, want)
+	}
+	if pod, ok := w.lookup("ns/api-2"); !ok || pod.Ready != "0/1" {
+		t.Errorf("lookup(ns/api-2) = %+v, %v, want the latest state", pod, ok)
+	}
+	if _, ok := w.lookup("ns/other"); ok {
+		t.Errorf("lookup(ns/other) found a pod that does not match the query")
+	}
+
+	// A pod that moves off the node no longer matches and counts as deleted.
+	w.apply(watchEvent(t, `{"type":"MODIFIED","object":{"metadata":{"name":"api-0","namespace":"ns"},"spec":{"nodeName":"node-b"},"status":{"phase":"Running"}}}`))
+	if pod, _ := w.lookup("ns/api-0"); pod.Status != podStatusDeleted {
+		t.Errorf("pod moved off the node has status %q, want %s", pod.Status, podStatusDeleted)
+	}
+}
+
+func TestPodWatchRead(t *testing.T) {
+	w := newPodWatch(nil, "ns", PodQuery{}, podLayout{})
+	stream := `{
+    "type": "ADDED",
+    "object": {"metadata": {"name": "api-1", "namespace": "ns"}, "status": {"phase": "Running"}}
+}
+{
+    "type": "ADDED",
+    "object": {"metadata": {"name": "api-2", "namespace": "ns"}, "status": {"phase": "Pending"}}
+}
+`
+	w.read(strings.NewReader(stream))
+	<-w.changed
+	if pods := w.items(); len(pods) != 2 || pods[1].Status != "Pending" {
+		t.Errorf("items() after read = %+v", pods)
+	}
+}
@@ -0,0 +1,132 @@
+package cmdutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultReconnectAttempts = 3
+	reconnectWaitTimeout     = 2 * time.Minute
+	reconnectMaxBackoff      = 10 * time.Second
+	sessionStderrTailSize    = 4096
+)
+
+// sessionDropPatterns are kubectl error messages that mean the exec stream
+// was cut (idle timeouts, API server restarts, proxies) rather than the
+// remote command exiting. They count only on a line kubectl wrote itself.
+var sessionDropPatterns = []string{
+	"unexpected eof",
+	"lost connection",
+	"connection reset by peer",
+	"use of closed network connection",
+	"i/o timeout",
+	"websocket: close",
+	"http2: client connection lost",
+	"error dialing backend",
+	"unable to connect to the server",
+	"tls handshake timeout",
+	"stream error",
+}
+
+// execWithReconnect runs the exec session and re-attaches when it drops
+// abnormally, up to budget times. Before each attempt the target is resolved
+// again, following a replacement pod from the same owner if the original one
+// is gone. A remote command exiting, even with a non-zero status, ends the
+// session normally. Only interactive shells are re-attached unless
+// opts.replay allows running a command or script again.
+func execWithReconnect(ctx context.Context, target execTarget, opts execOptions, budget int, out io.Writer) error {
+	for attempt := 1; ; attempt++ {
+		tail := &tailBuffer{max: sessionStderrTailSize}
+		e := opts.executor()
+		e.Stderr = io.MultiWriter(out, tail)
+		err := e.run(ctx, target.target(), opts.command)
+		reason, dropped := sessionDropped(err, tail.String())
+		if !dropped {
+			return execError(err, tail.String())
+		}
+		if !opts.replay {
+			fmt.Fprintf(out, "\n*** kubeexec: session to %s dropped (%s); not running the command again (use --reconnect-command to allow it) ***\n", target.describe(), reason)
+			return kubectlErrorf("session dropped: %s", reason)
+		}
+		if attempt > budget {
+			fmt.Fprintf(out, "\n*** kubeexec: session to %s dropped (%s); giving up after %d reconnect attempts ***\n", target.describe(), reason, budget)
+			return kubectlErrorf("session dropped: %s", reason)
+		}
+		fmt.Fprintf(out, "\n*** kubeexec: session to %s dropped (%s); reconnecting (attempt %d/%d) ***\n", target.describe(), reason, attempt, budget)
+		if err := sleepContext(ctx, reconnectBackoff(attempt)); err != nil {
+			return err
+		}
+		pod, err := waitForContainer(ctx, target, reconnectWaitTimeout, out)
+		if err != nil {
+			return kubectlErrorf("reconnect failed: %w", err)
+		}
+		target.pod = pod
+		fmt.Fprintf(out, "*** kubeexec: reconnected to %s ***\n", target.describe())
+		if opts.terminalTitle && terminalStream(opts.streams.Stderr) {
+			setTerminalTitle(opts.streams.Stderr, target)
+		}
+	}
+}
+
+// sessionDropped reports whether kubectl exited because the stream was cut:
+// it exited 1, as kubectl does for its own errors, and the last stderr line
+// is a kubectl error naming a transport failure. That line is the reason.
+// Remote output that merely mentions such a failure does not count.
+func sessionDropped(err error, stderr string) (string, bool) {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		return "", false
+	}
+	line := lastLine(stderr)
+	if !kubectlErrorLine.MatchString(line) {
+		return "", false
+	}
+	lower := strings.ToLower(line)
+	for _, pattern := range sessionDropPatterns {
+		if strings.Contains(lower, pattern) {
+			return line, true
+		}
+	}
+	return "", false
+}
+
+func reconnectBackoff(attempt int) time.Duration {
+	backoff := time.Second << (attempt - 1)
+	if backoff <= 0 || backoff > reconnectMaxBackoff {
+		return reconnectMaxBackoff
+	}
+	return backoff
+}
+
+func lastLine(value string) string {
+	lines := strings.Split(strings.TrimSpace(value), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+func (t execTarget) describe() string {
+	return fmt.Sprintf("%s/%s/%s (container %s)", t.context, t.namespace, t.pod, t.container)
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	buf []byte
+	max int
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.max {
+		t.buf = t.buf[len(t.buf)-t.max:]
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	return string(t.buf)
+}
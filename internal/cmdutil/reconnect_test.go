@@ -0,0 +1,114 @@
+package cmdutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func exitError(t *testing.T, code string) error {
+	t.Helper()
+	err := exec.Command("sh", "-c", "exit "+code).Run()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("expected exit error, got %v", err)
+	}
+	return err
+}
+
+func TestSessionDropped(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		stderr     string
+		wantReason string
+		wantDrop   bool
+	}{
+		{"clean exit", nil, "", "", false},
+		{"remote command failed", exitError(t, "2"), "ls: cannot access '/nope': No such file or directory\n", "", false},
+		{"idle timeout", exitError(t, "1"), "some output\nerror: unexpected EOF\n", "error: unexpected EOF", true},
+		{"api server restart", exitError(t, "1"), "E0101 12:00:00.000000   42 v2.go:167] websocket: close 1006 (abnormal closure)\n", "E0101 12:00:00.000000   42 v2.go:167] websocket: close 1006 (abnormal closure)", true},
+		{"lost connection", exitError(t, "1"), "error: lost connection to pod\n", "error: lost connection to pod", true},
+		{"unreachable server", exitError(t, "1"), "Unable to connect to the server: dial tcp 10.0.0.1:6443: i/o timeout\n", "Unable to connect to the server: dial tcp 10.0.0.1:6443: i/o timeout", true},
+		{"remote output mentions a timeout", exitError(t, "1"), "curl: (28) Connection timed out: i/o timeout\n", "", false},
+		{"remote prints a kubectl-like line", exitError(t, "3"), "error: unexpected EOF\n", "", false},
+		{"drop message before remote output", exitError(t, "1"), "error: unexpected EOF\nls: cannot access '/nope'\n", "", false},
+		{"not an exit error", errors.New("exec: kubectl not found"), "unexpected EOF", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, dropped := sessionDropped(tt.err, tt.stderr)
+			if dropped != tt.wantDrop || reason != tt.wantReason {
+				t.Errorf("sessionDropped() = (%q, %v), want (%q, %v)", reason, dropped, tt.wantReason, tt.wantDrop)
+			}
+		})
+	}
+}
+
+func TestRunReconnectDoesNotReplayCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     RunOptions
+		exit     int
+		wantCode int
+		wantNote bool
+	}{
+		{name: "command", opts: RunOptions{Command: []string{"make", "migrate"}}, exit: 1, wantCode: ExitKubectl, wantNote: true},
+		{name: "non-interactive shell", opts: RunOptions{NonInteractive: true}, exit: 1, wantCode: ExitKubectl, wantNote: true},
+		{name: "remote exit", opts: RunOptions{Command: []string{"make", "migrate"}}, exit: 3, wantCode: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := fakeRunCluster()
+			cluster.ExecExit = tt.exit
+			cluster.ExecStderr = "error: unexpected EOF\n"
+			h := newFakeCluster(t, cluster)
+			opts := tt.opts
+			opts.Pod, opts.Picker, opts.Reconnect = "api-1", "none", 2
+			var stderr bytes.Buffer
+			opts.Stdout, opts.Stderr = io.Discard, &stderr
+			err := Run(context.Background(), opts)
+			if code := ExitCode(err); code != tt.wantCode {
+				t.Errorf("ExitCode(Run()) = %d (%v), want %d", code, err, tt.wantCode)
+			}
+			if got := len(h.execs(t)); got != 1 {
+				t.Errorf("kubectl exec ran %d times, want once", got)
+			}
+			if got := strings.Contains(stderr.String(), "not running the command again"); got != tt.wantNote {
+				t.Errorf("Run() stderr = %q, want the no-replay note %t", stderr.String(), tt.wantNote)
+			}
+		})
+	}
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{5, reconnectMaxBackoff},
+		{100, reconnectMaxBackoff},
+	}
+	for _, tt := range tests {
+		if got := reconnectBackoff(tt.attempt); got != tt.want {
+			t.Errorf("reconnectBackoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	tail := &tailBuffer{max: 8}
+	tail.Write([]byte("hello "))
+	tail.Write([]byte("world"))
+	if got := tail.String(); got != "lo world" {
+		t.Errorf("tailBuffer = %q, want %q", got, "lo world")
+	}
+}
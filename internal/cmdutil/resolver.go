@@ -0,0 +1,252 @@
+package cmdutil
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+)
+
+// Target is a resolved exec destination.
+type Target struct {
+	Context   string
+	Namespace string
+	Pod       string
+	Container string
+}
+
+func (t execTarget) target() Target {
+	return Target{Context: t.context, Namespace: t.namespace, Pod: t.pod, Container: t.container}
+}
+
+// Query describes a target with the kubeexec command line's matching rules.
+type Query struct {
+	// Context is the kube context; empty means the current one. With
+	// ChooseContext it is a partial name, and empty asks the Selector.
+	Context       string
+	ChooseContext bool
+	// Namespace defaults to the context's namespace, then "default".
+	Namespace     string
+	AllNamespaces bool
+	// Pod is an exact or partial pod name, or namespace/pod with
+	// AllNamespaces; empty asks the Selector.
+	Pod  string
+	Pods PodQuery
+	// Container is required to exist; PreferredContainer is used only when
+	// the pod has it. Otherwise the only or default container is used.
+	Container          string
+	PreferredContainer string
+	// Wait waits up to this long for a matching pod and for its container to
+	// be running and ready.
+	Wait time.Duration
+}
+
+// Resolver turns a Query into a Target, asking the Selector for the choices
+// the query leaves open.
+type Resolver struct {
+	// Selector is nil for a resolver that never prompts.
+	Selector Selector
+	// Stderr receives notes and wait progress; nil discards them.
+	Stderr io.Writer
+	// Settings supplies the picker layout, with the sections of the
+	// resolved context applied; nil loads them from the config files and
+	// the environment.
+	Settings *Settings
+}
+
+// resolution is a resolved target with the container it names.
+type resolution struct {
+	target    execTarget
+	container ContainerItem
+}
+
+// Resolve resolves q. Cancelling ctx stops kubectl, the selector and any
+// wait, and Resolve returns a KindCancelled error. kubectl calls are bounded
+// by the kubectl-timeout and pod-list-timeout settings, with the sections of
+// the resolved context applied once it is known.
+func (r Resolver) Resolve(ctx context.Context, q Query) (Target, error) {
+	if r.Settings == nil {
+		settings, err := loadSettings()
+		if err != nil {
+			return Target{}, err
+		}
+		r.Settings = settings
+	}
+	kubeContext, err := r.context(withSettingsTimeouts(ctx, r.Settings), q)
+	if err != nil {
+		return Target{}, cancelled(ctx, err)
+	}
+	settings := r.Settings.ForContext(kubeContext)
+	resolved, err := r.target(withSettingsTimeouts(ctx, settings), kubeContext, q)
+	if err != nil {
+		return Target{}, cancelled(ctx, err)
+	}
+	return resolved.target.target(), nil
+}
+
+func (r Resolver) selector() Selector {
+	if r.Selector == nil {
+		return NonInteractiveSelector{}
+	}
+	return r.Selector
+}
+
+// settings returns the Settings for kubeContext.
+func (r Resolver) settings(kubeContext string) (*Settings, error) {
+	settings := r.Settings
+	if settings == nil {
+		var err error
+		if settings, err = loadSettings(); err != nil {
+			return nil, err
+		}
+	}
+	return settings.ForContext(kubeContext), nil
+}
+
+func (r Resolver) stderr() io.Writer {
+	if r.Stderr == nil {
+		return io.Discard
+	}
+	return r.Stderr
+}
+
+// context resolves the kube context. Run applies the context's settings
+// between this and target.
+func (r Resolver) context(ctx context.Context, q Query) (string, error) {
+	kubeContext := q.Context
+	if q.ChooseContext {
+		resolved, err := resolveContext(ctx, kubeContext, r.selector())
+		if err != nil {
+			return "", err
+		}
+		kubeContext = resolved
+	}
+	if kubeContext != "" {
+		if !q.ChooseContext {
+			traceFrom(ctx).decision("context", "%q as given", kubeContext)
+		}
+		return kubeContext, nil
+	}
+	kubeContext, err := CurrentContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	traceFrom(ctx).decision("context", "%q is kubectl's current context", kubeContext)
+	if kubeContext == "" && q.Namespace == "" {
+		return "", usageErrorf("no kubernetes context is set")
+	}
+	return kubeContext, nil
+}
+
+// target resolves the namespace, pod and container of q in kubeContext.
+func (r Resolver) target(ctx context.Context, kubeContext string, q Query) (resolution, error) {
+	query := q.Pods
+	phase, err := NormalizePhase(query.Phase)
+	if err != nil {
+		return resolution{}, err
+	}
+	query.Phase = phase
+	if _, _, err := ParseOwner(query.Owner); err != nil {
+		return resolution{}, err
+	}
+	if q.AllNamespaces && q.Namespace != "" {
+		return resolution{}, usageErrorf("cannot use --all-namespaces with --namespace")
+	}
+
+	trace := traceFrom(ctx)
+	namespace := q.Namespace
+	switch {
+	case q.AllNamespaces:
+		trace.decision("namespace", "all namespaces")
+	case namespace != "":
+		trace.decision("namespace", "%q as given", namespace)
+	default:
+		namespace, err = CurrentNamespace(ctx, kubeContext)
+		if err != nil {
+			return resolution{}, err
+		}
+		if namespace == "" {
+			namespace = "default"
+			trace.decision("namespace", "%q since context %q sets none", namespace, kubeContext)
+		} else {
+			trace.decision("namespace", "%q from context %q", namespace, kubeContext)
+		}
+	}
+
+	pods, err := GetPods(ctx, kubeContext, namespace, query, q.AllNamespaces)
+	if err != nil {
+		return resolution{}, err
+	}
+	if q.Wait > 0 && !podsMatchArg(pods, q.Pod, q.AllNamespaces) {
+		pods, err = waitForPods(ctx, kubeContext, namespace, query, q.Pod, q.AllNamespaces, q.Wait, r.stderr())
+		if err != nil {
+			return resolution{}, err
+		}
+	}
+	if len(pods) == 0 {
+		if filters := query.describe(); len(filters) > 0 {
+			return resolution{}, resolutionErrorf("no pods found (%s)", strings.Join(filters, ", "))
+		}
+		return resolution{}, resolutionErrorf("no pods found")
+	}
+	settings, err := r.settings(kubeContext)
+	if err != nil {
+		return resolution{}, err
+	}
+	layout, err := podLayoutFromSettings(settings, q.AllNamespaces)
+	if err != nil {
+		return resolution{}, err
+	}
+	formatPodDisplays(pods, layout)
+	chooser := podChooser{selector: r.selector(), pods: pods, layout: layout, context: kubeContext, namespace: namespace, query: query, allNamespaces: q.AllNamespaces}
+	selected, err := chooser.resolve(ctx, q.Pod)
+	if err != nil {
+		return resolution{}, err
+	}
+	pod := selected.Name
+	podNamespace := selected.Namespace
+	if podNamespace == "" {
+		podNamespace = namespace
+	}
+
+	containers, defaultContainer, err := GetPodContainers(ctx, kubeContext, podNamespace, pod)
+	if err != nil {
+		return resolution{}, err
+	}
+	regular := containerNames(filterContainersByKind(containers, ContainerKindRegular))
+	if len(regular) == 0 {
+		return resolution{}, resolutionErrorf("no containers found in pod %q", pod)
+	}
+	container := q.Container
+	if container != "" {
+		trace.decision("container", "%q as requested", container)
+	} else if q.PreferredContainer != "" {
+		if _, ok := findContainer(containers, q.PreferredContainer); ok {
+			container = q.PreferredContainer
+			trace.decision("container", "%q is the preferred container", container)
+		} else {
+			trace.decision("container", "preferred container %q is not in pod %q", q.PreferredContainer, pod)
+		}
+	}
+	waiting := q.Wait > 0
+	containerName, err := chooseContainer(ctx, pod, containers, regular, defaultContainer, container, r.selector(), waiting, r.stderr())
+	if err != nil {
+		return resolution{}, err
+	}
+	selectedContainer, _ := findContainer(containers, containerName)
+
+	target := execTarget{
+		context:   kubeContext,
+		namespace: podNamespace,
+		pod:       pod,
+		container: containerName,
+		query:     query,
+	}
+	if waiting {
+		target.pod, err = waitForContainer(ctx, target, q.Wait, r.stderr())
+		if err != nil {
+			return resolution{}, err
+		}
+	}
+	return resolution{target: target, container: selectedContainer}, nil
+}
@@ -0,0 +1,142 @@
+package cmdutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolverResolve(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    Query
+		selector Selector
+		want     Target
+		wantKind ErrorKind
+	}{
+		{
+			name:  "partial pod in the context namespace",
+			query: Query{Pod: "work"},
+			want:  Target{Context: "dev", Namespace: "payments", Pod: "worker-1", Container: "app"},
+		},
+		{
+			name:  "partial context",
+			query: Query{Context: "stag", ChooseContext: true, Pod: "core"},
+			want:  Target{Context: "staging", Namespace: "kube-system", Pod: "coredns-1", Container: "app"},
+		},
+		{
+			name:  "preferred container",
+			query: Query{Pod: "multi-2", PreferredContainer: "proxy"},
+			want:  Target{Context: "dev", Namespace: "payments", Pod: "multi-2", Container: "proxy"},
+		},
+		{
+			name:     "ambiguous without a selector",
+			query:    Query{Pod: "api"},
+			wantKind: KindAmbiguous,
+		},
+		{
+			name:     "ambiguous with a scripted selector",
+			query:    Query{AllNamespaces: true, Pod: "api-1"},
+			selector: &ScriptedSelector{Keys: []string{"kube-system/api-1"}},
+			want:     Target{Context: "dev", Namespace: "kube-system", Pod: "api-1", Container: "app"},
+		},
+		{
+			name:     "invalid phase",
+			query:    Query{Pod: "api-1", Pods: PodQuery{Phase: "Sleeping"}},
+			wantKind: KindUsage,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newFakeCluster(t, fakeRunCluster())
+			got, err := Resolver{Selector: tt.selector}.Resolve(context.Background(), tt.query)
+			if tt.wantKind != 0 {
+				if !IsKind(err, tt.wantKind) {
+					t.Errorf("Resolve() error = %v, want kind %d", err, tt.wantKind)
+				}
+			} else if err != nil || got != tt.want {
+				t.Errorf("Resolve() = %+v, %v, want %+v", got, err, tt.want)
+			}
+			if execs := h.execs(t); execs != nil {
+				t.Errorf("Resolve() ran kubectl exec: %q", execs)
+			}
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	newFakeCluster(t, fakeRunCluster())
+	if _, err := (Resolver{}).Resolve(ctx, Query{Pod: "api-1"}); !IsKind(err, KindCancelled) || !errors.Is(err, context.Canceled) {
+		t.Errorf("Resolve() with a cancelled context = %v, want cancelled error", err)
+	}
+}
+
+func TestResolverTimeoutsFromSettings(t *testing.T) {
+	newFakeCluster(t, fakeRunCluster())
+	t.Setenv("KUBEEXEC_POD_LIST_TIMEOUT", "1ns")
+	short, err := ResolveSettings(nil)
+	if err != nil {
+		t.Fatalf("ResolveSettings() error: %v", err)
+	}
+	os.Unsetenv("KUBEEXEC_POD_LIST_TIMEOUT")
+	query := Query{Pod: "api-1"}
+	if _, err := (Resolver{Settings: short}).Resolve(context.Background(), query); !IsKind(err, KindKubectl) || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Resolve() with pod-list-timeout 1ns error = %v, want a kubectl timeout", err)
+	}
+	// Nothing carries over to a resolver with its own settings.
+	if _, err := (Resolver{}).Resolve(context.Background(), query); err != nil {
+		t.Errorf("Resolve() with the default timeouts error: %v", err)
+	}
+}
+
+func TestExecutorExec(t *testing.T) {
+	cluster := fakeRunCluster()
+	cluster.ExecExit = 3
+	h := newFakeCluster(t, cluster)
+	target := Target{Context: "dev", Namespace: "payments", Pod: "api-1", Container: "app"}
+
+	err := Executor{Stdin: strings.NewReader("input")}.Exec(context.Background(), target, "cat")
+	var remote *RemoteExitError
+	if !errors.As(err, &remote) || remote.Code != 3 {
+		t.Errorf("Exec() error = %v, want the remote status 3", err)
+	}
+	err = Executor{Env: []EnvVar{{Name: "DEBUG", Value: "1"}}}.Exec(context.Background(), target, "env")
+	if ExitCode(err) != 3 {
+		t.Errorf("Exec() error = %v, want the remote status 3", err)
+	}
+
+	want := [][]string{
+		{"--context", "dev", "exec", "-i", "-n", "payments", "api-1", "-c", "app", "--", "cat"},
+		append([]string{"--context", "dev", "exec", "-i", "-n", "payments", "api-1", "-c", "app", "--"}, envStdinCommand([]string{"env"})...),
+	}
+	if got := h.execs(t); !reflect.DeepEqual(got, want) {
+		t.Errorf("kubectl exec calls = %q, want %q", got, want)
+	}
+}
+
+func TestRunStreams(t *testing.T) {
+	newFakeCluster(t, fakeRunCluster())
+	var stdout, stderr bytes.Buffer
+	err := Run(context.Background(), RunOptions{
+		Pod:     "multi-1",
+		Command: []string{"uptime"},
+		DryRun:  true,
+		Picker:  "none",
+		Stdin:   strings.NewReader(""),
+		Stdout:  &stdout,
+		Stderr:  &stderr,
+	})
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if want := "kubectl --context dev exec -i -n payments multi-1 -c app -- uptime\n"; stdout.String() != want {
+		t.Errorf("Run() printed %q, want %q", stdout.String(), want)
+	}
+	if !strings.Contains(stderr.String(), `using default "app"`) {
+		t.Errorf("Run() stderr = %q, want the default container note", stderr.String())
+	}
+}
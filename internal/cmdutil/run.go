@@ -1,236 +1,291 @@
 package cmdutil
 
 import (
+	"bytes"
+	"context"
 	"fmt"
-	"os"
+	"io"
 	"os/exec"
 	"strings"
+	"time"
 )
 
 type RunOptions struct {
-	Context          string
-	Namespace        string
-	Container        string
-	Selector         string
-	Pod              string
-	Command          []string
-	DryRun           bool
-	ContextRequested bool
-	ConfirmContext   bool
+	Context   string
+	Namespace string
+	Container string
+	// PreferredContainer is used instead of the pod's default container when
+	// Container is empty and the pod has it.
+	PreferredContainer string
+	Selector           string
+	FieldSelector      string
+	Node               string
+	Owner              string
+	Phase              string
+	Pod                string
+	Command            []string
+	DryRun             bool
+	ContextRequested   bool
+	ConfirmContext     bool
+	// ConfirmStyle and ConfirmCountdown set how ConfirmContext asks.
+	ConfirmStyle     string
+	ConfirmCountdown time.Duration
+	// YesIKnow skips the confirmation when the SHA-256 of
+	// KUBEEXEC_CONFIRM_TOKEN is the confirm-token-hash setting.
+	YesIKnow         bool
 	NonInteractive   bool
-	IgnoreFzf        bool
+	Picker           string
 	AllNamespaces    bool
+	Wait             time.Duration
+	Reconnect        int
+	ReconnectCommand bool
+	Script           string
+	Snippet          string
+	SnippetRequested bool
+	SnippetArgs      []string
+	Env              []string
+	EnvFiles         []string
+	ForwardEnv       []string
+	KubectlTimeout   time.Duration
+	PodListTimeout   time.Duration
+	// Banner prints where the session goes to Stderr before exec, in
+	// BannerColor.
+	Banner      bool
+	BannerColor string
+	// TerminalTitle sets the terminal title to context/namespace/pod while
+	// the session runs.
+	TerminalTitle bool
+	// Verbose logs kubectl calls and resolution decisions to Stderr; 2 adds
+	// kubectl's stderr and the source of each setting.
+	Verbose int
+	// TraceFile, when set, receives the same as JSON lines.
+	TraceFile string
+	// Settings, when set, is re-applied with the [context."<glob>"] sections
+	// that match the resolved context.
+	Settings *Settings
+	// Chooser, when set, makes every choice instead of the Picker setting.
+	Chooser Selector
+	// Stdin, Stdout and Stderr replace the process's streams when set.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
 }
 
-func Run(opts RunOptions) error {
-	if _, err := exec.LookPath("kubectl"); err != nil {
-		return fmt.Errorf("kubectl not found")
+// runSelector returns the selector for opts: its Chooser or its picker.
+func runSelector(opts RunOptions) (Selector, error) {
+	if opts.Chooser != nil {
+		return opts.Chooser, nil
+	}
+	if opts.Settings != nil {
+		return pickerFromSettings(opts.Settings, opts.Picker)
 	}
+	return resolvePicker(opts.Picker)
+}
 
-	context := opts.Context
-	namespace := opts.Namespace
-	container := opts.Container
-	selector := opts.Selector
-	podArg := opts.Pod
-	command := opts.Command
-	dryRun := opts.DryRun
-	confirmContext := opts.ConfirmContext
-	nonInteractive := opts.NonInteractive
-	ignoreFzf := opts.IgnoreFzf
-	allNamespaces := opts.AllNamespaces
-
-	if opts.ContextRequested {
-		resolved, err := resolveContext(context, ignoreFzf)
-		if err != nil {
-			return err
-		}
-		context = resolved
+// query returns the target query of opts.
+func (opts RunOptions) query() Query {
+	query := Query{
+		Context:       opts.Context,
+		ChooseContext: opts.ContextRequested,
+		Namespace:     opts.Namespace,
+		AllNamespaces: opts.AllNamespaces,
+		Pod:           opts.Pod,
+		Pods: PodQuery{
+			Selector:      opts.Selector,
+			FieldSelector: opts.FieldSelector,
+			Node:          opts.Node,
+			Owner:         opts.Owner,
+			Phase:         opts.Phase,
+		},
+		Container:          opts.Container,
+		PreferredContainer: opts.PreferredContainer,
+	}
+	if !opts.DryRun {
+		query.Wait = opts.Wait
+	}
+	return query
+}
+
+// executor returns opts' streams, defaulting to the process's own.
+func (opts RunOptions) executor() Executor {
+	e := stdExecutor(nil)
+	if opts.Stdin != nil {
+		e.Stdin = opts.Stdin
 	}
-	if context == "" {
-		var err error
-		context, err = CurrentContext()
-		if err != nil {
-			return err
-		}
-		if context == "" && namespace == "" {
-			return fmt.Errorf("no kubernetes context is set")
-		}
+	if opts.Stdout != nil {
+		e.Stdout = opts.Stdout
+	}
+	if opts.Stderr != nil {
+		e.Stderr = opts.Stderr
 	}
+	return e
+}
+
+// Run resolves the target of opts with a Resolver and execs into it, or
+// prints the kubectl command for a dry run. Cancelling ctx stops whatever
+// step is running, including the exec session, and Run returns a
+// KindCancelled error.
+func Run(ctx context.Context, opts RunOptions) error {
+	return cancelled(ctx, run(ctx, opts))
+}
 
-	if allNamespaces && namespace != "" {
-		return fmt.Errorf("cannot use --all-namespaces with --namespace")
+func run(ctx context.Context, opts RunOptions) error {
+	if _, err := exec.LookPath("kubectl"); err != nil {
+		return kubectlErrorf("kubectl not found")
 	}
 
-	if namespace == "" {
-		var err error
-		namespace, err = CurrentNamespace(context)
-		if err != nil {
+	streams := opts.executor()
+	trace, err := newTracer(opts.Verbose, opts.TraceFile, streams.Stderr)
+	if err != nil {
+		return err
+	}
+	defer trace.close()
+	ctx = withTracer(ctx, trace)
+	ctx = withKubectlTimeouts(ctx, opts.KubectlTimeout, opts.PodListTimeout)
+	selector, err := runSelector(opts)
+	if err != nil {
+		return err
+	}
+	base := opts.Settings
+	if base == nil {
+		if base, err = loadSettings(); err != nil {
 			return err
 		}
-		if namespace == "" {
-			namespace = "default"
-		}
 	}
-
-	if allNamespaces {
-		namespace = ""
+	resolver := Resolver{Selector: selector, Stderr: streams.Stderr, Settings: base}
+	kubeContext, err := resolver.context(ctx, opts.query())
+	if err != nil {
+		return err
 	}
+	settings := base.ForContext(kubeContext)
+	var contextMatches []contextMatch
+	if opts.Settings != nil {
+		settings.ApplyRunOptions(&opts)
+		contextMatches = settings.contextMatches
+		trace.settings(settings)
+	}
+	ctx = withKubectlTimeouts(ctx, opts.KubectlTimeout, opts.PodListTimeout)
 
-	pods, err := GetPods(context, namespace, selector, allNamespaces)
+	command := opts.Command
+	shell, err := shellFromSettings(settings)
 	if err != nil {
 		return err
 	}
-	if len(pods) == 0 {
-		return fmt.Errorf("no pods found")
+	bannerColor, err := parseBannerColor(opts.BannerColor)
+	if err != nil {
+		return err
+	}
+	confirm, err := newConfirmPolicy(opts.ConfirmStyle, opts.ConfirmCountdown, opts.YesIKnow, settings.String("confirm-token-hash"))
+	if err != nil {
+		return err
+	}
+	script, err := loadScript(opts.Script, streams.Stdin)
+	if err != nil {
+		return err
+	}
+	env, err := ResolveEnv(opts.Env, opts.EnvFiles, opts.ForwardEnv)
+	if err != nil {
+		return err
+	}
+	snippetValues, err := ParseSnippetArgs(opts.SnippetArgs)
+	if err != nil {
+		return err
+	}
+	if len(snippetValues) > 0 && !opts.SnippetRequested {
+		return usageErrorf("--arg requires -s/--snippet")
+	}
+	if opts.SnippetRequested && (script != nil || len(command) > 0) {
+		return usageErrorf("cannot combine a snippet with --script or -- <CMD>")
+	}
+	if script != nil {
+		command = scriptCommand(shell, command)
+	} else if len(command) == 0 && shell != "" {
+		command = interactiveShellCommand(shell)
+	}
+	// Context sections may select another picker.
+	resolver.Selector, err = runSelector(opts)
+	if err != nil {
+		return err
 	}
 
-	pod := ""
-	podNamespace := namespace
-	if podArg == "" {
-		if ignoreFzf {
-			return fmt.Errorf("pod not specified and fzf is disabled; provide a pod name or enable fzf")
-		}
-		if err := checkFzf(); err != nil {
+	resolved, err := resolver.target(ctx, kubeContext, opts.query())
+	if err != nil {
+		return err
+	}
+	if opts.SnippetRequested {
+		name, snippet, err := resolveSnippet(ctx, settings, opts.Snippet, resolved.container, resolver.Selector)
+		if err != nil {
 			return err
 		}
-		header := buildPodHeader(context, namespace, selector, "", allNamespaces)
-		choice, err := ChooseWithFzf(podDisplays(pods), header)
+		rendered, err := renderSnippet(name, snippet.Command, snippetValues)
 		if err != nil {
 			return err
 		}
-		if choice == "" {
-			return fmt.Errorf("no pod selected")
-		}
-		selected, ok := podFromChoice(pods, choice)
-		if !ok {
-			return fmt.Errorf("no pod selected")
-		}
-		pod = selected.Name
-		podNamespace = selected.Namespace
-	} else if allNamespaces && strings.Contains(podArg, "/") {
-		ns, name, ok := splitPodNamespaceArg(podArg)
-		if !ok {
-			return fmt.Errorf("invalid pod argument %q (expected namespace/pod)", podArg)
-		}
-		if !podExistsInNamespace(pods, ns, name) {
-			return fmt.Errorf("pod %q not found in namespace %q", name, ns)
-		}
-		pod = name
-		podNamespace = ns
-	} else if podExists(pods, podArg) && !allNamespaces {
-		pod = podArg
-	} else if allNamespaces {
-		exact := filterPodsByExactName(pods, podArg)
-		if len(exact) == 1 {
-			pod = exact[0].Name
-			podNamespace = exact[0].Namespace
-		} else {
-			matches := filterPodsByQuery(pods, podArg)
-			if len(matches) == 0 {
-				return fmt.Errorf("no pods match %q", podArg)
-			}
-			if len(matches) == 1 {
-				pod = matches[0].Name
-				podNamespace = matches[0].Namespace
-			} else {
-				if ignoreFzf {
-					return fmt.Errorf("pod query %q matches multiple entries and fzf is disabled; provide namespace/pod or enable fzf", podArg)
-				}
-				if err := checkFzf(); err != nil {
-					return err
-				}
-				header := buildPodHeader(context, namespace, selector, "pod: "+podArg, allNamespaces)
-				choice, err := ChooseWithFzf(podDisplays(matches), header)
-				if err != nil {
-					return err
-				}
-				if choice == "" {
-					return fmt.Errorf("no pod selected")
-				}
-				selected, ok := podFromChoice(matches, choice)
-				if !ok {
-					return fmt.Errorf("no pod selected")
-				}
-				pod = selected.Name
-				podNamespace = selected.Namespace
-			}
-		}
-	} else {
-		matches := filterPodsByQuery(pods, podArg)
-		if len(matches) == 0 {
-			return fmt.Errorf("no pods match %q", podArg)
-		}
-		if len(matches) == 1 {
-			pod = matches[0].Name
-			podNamespace = matches[0].Namespace
-		} else {
-			if ignoreFzf {
-				if allNamespaces {
-					return fmt.Errorf("pod query %q matches multiple entries and fzf is disabled; provide namespace/pod or enable fzf", podArg)
-				}
-				return fmt.Errorf("pod query %q matches multiple entries and fzf is disabled; provide a full pod name or enable fzf", podArg)
-			}
-			if err := checkFzf(); err != nil {
-				return err
-			}
-			header := buildPodHeader(context, namespace, selector, "pod: "+podArg, allNamespaces)
-			choice, err := ChooseWithFzf(podDisplays(matches), header)
-			if err != nil {
-				return err
-			}
-			if choice == "" {
-				return fmt.Errorf("no pod selected")
-			}
-			selected, ok := podFromChoice(matches, choice)
-			if !ok {
-				return fmt.Errorf("no pod selected")
-			}
-			pod = selected.Name
-			podNamespace = selected.Namespace
+		snippetShell := shell
+		if snippetShell == "" {
+			snippetShell = defaultShell
 		}
+		command = []string{snippetShell, "-c", rendered}
 	}
 
-	if podNamespace == "" {
-		podNamespace = namespace
-	}
-	containers, defaultContainer, err := GetPodContainers(context, podNamespace, pod)
-	if err != nil {
-		return err
-	}
-	if len(containers) == 0 {
-		return fmt.Errorf("no containers found in pod %q", pod)
-	}
+	return execOrPrint(ctx, resolved.target, execOptions{
+		command:        command,
+		dryRun:         opts.DryRun,
+		confirmContext: opts.ConfirmContext,
+		confirm:        confirm,
+		keywords:       confirmContextKeywords(settings),
+		nonInteractive: opts.NonInteractive,
+		reconnect:      opts.Reconnect,
+		replay:         opts.ReconnectCommand || (len(opts.Command) == 0 && script == nil && !opts.SnippetRequested && !opts.NonInteractive),
+		script:         script,
+		env:            env,
+		contextMatches: contextMatches,
+		banner:         opts.Banner,
+		bannerColor:    bannerColor,
+		terminalTitle:  opts.TerminalTitle,
+		streams:        streams,
+	})
+}
 
-	if container != "" {
-		if !contains(containers, container) {
-			return fmt.Errorf("container %q not found in pod %q (available: %s)", container, pod, strings.Join(containers, ", "))
+// chooseContainer picks the exec container: the -c value, the only regular
+// container, the pod's default, or a picker choice. Containers that are not
+// running are refused unless waiting, in which case kubeexec waits for them.
+func chooseContainer(ctx context.Context, pod string, containers []ContainerItem, regular []string, defaultContainer, requested string, selector Selector, waiting bool, out io.Writer) (string, error) {
+	if requested != "" {
+		selected, ok := findContainer(containers, requested)
+		if !ok {
+			return "", resolutionErrorf("container %q not found in pod %q (available: %s)", requested, pod, containerLabels(containers))
+		}
+		if selected.Kind != ContainerKindRegular && !selected.Running() && !waiting {
+			return "", notRunningContainerError(pod, selected)
 		}
-		return execOrPrint(context, podNamespace, pod, container, command, dryRun, confirmContext, nonInteractive)
+		return requested, nil
 	}
 
-	if len(containers) == 1 {
-		return execOrPrint(context, podNamespace, pod, containers[0], command, dryRun, confirmContext, nonInteractive)
+	if len(regular) == 1 {
+		traceFrom(ctx).decision("container", "%q is the only regular container", regular[0])
+		return regular[0], nil
 	}
 	if defaultContainer != "" {
-		fmt.Fprintf(os.Stderr, "note: pod has multiple containers (%s); using default %q. Use -c to select another.\n", strings.Join(containers, ", "), defaultContainer)
-		return execOrPrint(context, podNamespace, pod, defaultContainer, command, dryRun, confirmContext, nonInteractive)
+		traceFrom(ctx).decision("container", "%q is the pod's default container", defaultContainer)
+		fmt.Fprintf(out, "note: pod has multiple containers (%s); using default %q. Use -c to select another.\n", containerLabels(containers), defaultContainer)
+		return defaultContainer, nil
 	}
 
-	if ignoreFzf {
-		return fmt.Errorf("pod %q has multiple containers and fzf is disabled; use -c to select a container or enable fzf", pod)
-	}
-	if err := checkFzf(); err != nil {
-		return err
-	}
-	containerChoice, err := ChooseWithFzf(containers, fmt.Sprintf("pod: %s", pod))
+	formatContainerDisplays(containers)
+	sel := Selection{Kind: "container", Header: fmt.Sprintf("pod: %s", pod), Items: containerPickerItems(containers)}
+	containerChoice, err := selectOne(ctx, selector, sel, fmt.Sprintf("pod %q has multiple containers and the picker is disabled; use -c to select a container or choose a --picker", pod))
 	if err != nil {
-		return err
+		return "", err
 	}
-	if containerChoice == "" {
-		return fmt.Errorf("no container selected")
+	selectedContainer, ok := findContainer(containers, containerChoice)
+	if !ok {
+		return "", cancelledErrorf("no container selected")
 	}
-
-	return execOrPrint(context, podNamespace, pod, containerChoice, command, dryRun, confirmContext, nonInteractive)
+	if !selectedContainer.Running() && !waiting {
+		return "", notRunningContainerError(pod, selectedContainer)
+	}
+	return selectedContainer.Name, nil
 }
 
 func contains(items []string, item string) bool {
@@ -272,31 +327,132 @@ func filterPodsByExactName(pods []PodItem, name string) []PodItem {
 	return matches
 }
 
-func podExists(pods []PodItem, name string) bool {
+// Key identifies the pod in picker selections.
+func (p PodItem) Key() string {
+	return p.Namespace + "/" + p.Name
+}
+
+func podPickerItems(pods []PodItem) []PickerItem {
+	items := make([]PickerItem, 0, len(pods))
 	for _, pod := range pods {
-		if pod.Name == name {
-			return true
+		display := pod.Display
+		if display == "" {
+			display = pod.Name
 		}
+		items = append(items, PickerItem{Key: pod.Key(), Display: display})
 	}
-	return false
+	return items
 }
 
-func podDisplays(pods []PodItem) []string {
-	displays := make([]string, 0, len(pods))
-	for _, pod := range pods {
-		if pod.Display != "" {
-			displays = append(displays, pod.Display)
-			continue
+// podChooser resolves the pod argument against the listed pods, asking the
+// selector when it is missing or ambiguous. With picker-watch and a picker
+// that can show changes, the list follows a pod watch while the picker is
+// open and the pod returned is its latest state.
+type podChooser struct {
+	selector      Selector
+	pods          []PodItem
+	layout        podLayout
+	context       string
+	namespace     string
+	query         PodQuery
+	allNamespaces bool
+}
+
+// resolve finds the pod podArg names: namespace/pod in all-namespaces mode,
+// an exact name, or a unique partial match. Anything else is chosen.
+func (c podChooser) resolve(ctx context.Context, podArg string) (PodItem, error) {
+	trace := traceFrom(ctx)
+	if podArg == "" {
+		trace.decision("pod", "none named; choosing from %d pods", len(c.pods))
+		return c.choose(ctx, "", nil, "pod not specified and the picker is disabled; provide a pod name or choose a --picker")
+	}
+	if c.allNamespaces && strings.Contains(podArg, "/") {
+		ns, name, ok := splitPodNamespaceArg(podArg)
+		if !ok {
+			return PodItem{}, usageErrorf("invalid pod argument %q (expected namespace/pod)", podArg)
+		}
+		if pod, ok := podFromKey(c.pods, ns+"/"+name); ok {
+			trace.decision("pod", "%q names %s", podArg, pod.Key())
+			return pod, nil
+		}
+		return PodItem{}, resolutionErrorf("pod %q not found in namespace %q", name, ns)
+	}
+	exact := filterPodsByExactName(c.pods, podArg)
+	if len(exact) == 1 {
+		trace.decision("pod", "%q matches %s exactly", podArg, exact[0].Key())
+		return exact[0], nil
+	}
+	matches := filterPodsByQuery(c.pods, podArg)
+	switch len(matches) {
+	case 0:
+		return PodItem{}, resolutionErrorf("no pods match %q", podArg)
+	case 1:
+		trace.decision("pod", "%q is part of the name of %s only", podArg, matches[0].Key())
+		return matches[0], nil
+	}
+	if len(exact) > 1 {
+		trace.decision("pod", "%q is the name of %d pods (%s)", podArg, len(exact), traceKeys(exact, traceKeyLimit))
+	}
+	trace.decision("pod", "%q is part of the name of %d pods (%s); choosing", podArg, len(matches), traceKeys(matches, traceKeyLimit))
+	hint := "provide a full pod name"
+	if c.allNamespaces {
+		hint = "provide namespace/pod"
+	}
+	narrow := func(pods []PodItem) []PodItem { return filterPodsByQuery(pods, podArg) }
+	return c.choose(ctx, "pod: "+podArg, narrow, fmt.Sprintf("pod query %q matches multiple entries and the picker is disabled; %s or choose a --picker", podArg, hint))
+}
+
+// choose asks for one of the pods narrow keeps (all when nil).
+func (c podChooser) choose(ctx context.Context, podQuery string, narrow func([]PodItem) []PodItem, disabled string) (PodItem, error) {
+	if narrow == nil {
+		narrow = func(pods []PodItem) []PodItem { return pods }
+	}
+	sel := Selection{
+		Kind:    "pod",
+		Header:  buildPodHeader(c.context, c.namespace, c.query, podQuery, c.allNamespaces),
+		Items:   podPickerItems(narrow(c.pods)),
+		Preview: podPreview(c.context),
+	}
+	var watch *podWatch
+	if c.layout.watch && showsChanges(c.selector) {
+		// A list that cannot be watched is still worth choosing from.
+		if w, err := startPodWatch(ctx, c.context, c.namespace, c.query, c.allNamespaces, c.pods, c.layout); err == nil {
+			defer w.stop()
+			watch = w
+			sel.Refresh = func() []PickerItem { return podPickerItems(narrow(w.items())) }
+			sel.Changed = w.changed
 		}
-		displays = append(displays, pod.Name)
 	}
-	return displays
+	choice, err := selectOne(ctx, c.selector, sel, disabled)
+	if err != nil {
+		return PodItem{}, err
+	}
+	selected, ok := podFromKey(c.pods, choice)
+	if watch != nil {
+		selected, ok = watch.lookup(choice)
+	}
+	if !ok {
+		return PodItem{}, cancelledErrorf("no pod selected")
+	}
+	if selected.Status == podStatusDeleted {
+		return PodItem{}, resolutionErrorf("pod %q was deleted while choosing", selected.Name)
+	}
+	return selected, nil
 }
 
-func podFromChoice(pods []PodItem, choice string) (PodItem, bool) {
-	trimmed := strings.TrimSpace(choice)
+// podPreview describes the highlighted pod; the key is split into namespace
+// and name by sh so the preview works whatever the user's shell is.
+func podPreview(context string) string {
+	script := `kubectl --context "$0" describe pod -n "${1%%/*}" "${1#*/}"`
+	if context == "" {
+		script = `kubectl describe pod -n "${1%%/*}" "${1#*/}"`
+	}
+	return "sh -c " + shellQuote(script) + " " + shellQuote(context) + " {key}"
+}
+
+func podFromKey(pods []PodItem, key string) (PodItem, bool) {
 	for _, pod := range pods {
-		if strings.TrimSpace(pod.Display) == trimmed {
+		if pod.Key() == key {
 			return pod, true
 		}
 	}
@@ -316,16 +472,7 @@ func splitPodNamespaceArg(value string) (string, string, bool) {
 	return ns, name, true
 }
 
-func podExistsInNamespace(pods []PodItem, namespace, name string) bool {
-	for _, pod := range pods {
-		if pod.Name == name && pod.Namespace == namespace {
-			return true
-		}
-	}
-	return false
-}
-
-func buildPodHeader(context, namespace, selector, podQuery string, allNamespaces bool) string {
+func buildPodHeader(context, namespace string, query PodQuery, podQuery string, allNamespaces bool) string {
 	var parts []string
 	if context != "" {
 		parts = append(parts, "context: "+context)
@@ -335,82 +482,125 @@ func buildPodHeader(context, namespace, selector, podQuery string, allNamespaces
 	} else if namespace != "" {
 		parts = append(parts, "namespace: "+namespace)
 	}
-	if selector != "" {
-		parts = append(parts, "selector: "+selector)
-	}
+	parts = append(parts, query.describe()...)
 	if podQuery != "" {
 		parts = append(parts, podQuery)
 	}
 	return strings.Join(parts, "  ")
 }
 
-func resolveContext(query string, ignoreFzf bool) (string, error) {
-	contexts, err := GetContexts()
+func resolveContext(ctx context.Context, query string, selector Selector) (string, error) {
+	contexts, err := GetContexts(ctx)
 	if err != nil {
 		return "", err
 	}
 	if len(contexts) == 0 {
-		return "", fmt.Errorf("no kubernetes contexts found")
+		return "", resolutionErrorf("no kubernetes contexts found")
 	}
 	if query == "" {
-		if ignoreFzf {
-			return "", fmt.Errorf("context not specified and fzf is disabled; provide --context <name> or enable fzf")
-		}
-		if err := checkFzf(); err != nil {
-			return "", err
-		}
-		choice, err := ChooseWithFzf(contexts, "select context")
-		if err != nil {
-			return "", err
-		}
-		if choice == "" {
-			return "", fmt.Errorf("no context selected")
-		}
-		return choice, nil
+		sel := Selection{Kind: "context", Header: "select context", Items: plainPickerItems(contexts)}
+		return selectOne(ctx, selector, sel, "context not specified and the picker is disabled; provide --context <name> or choose a --picker")
 	}
 	if contains(contexts, query) {
+		traceFrom(ctx).decision("context", "%q matches a context exactly", query)
 		return query, nil
 	}
 	matches := filterByQuery(contexts, query)
 	if len(matches) == 0 {
-		return "", fmt.Errorf("no contexts match %q", query)
+		return "", resolutionErrorf("no contexts match %q", query)
 	}
 	if len(matches) == 1 {
+		traceFrom(ctx).decision("context", "%q is part of the name of %q only", query, matches[0])
 		return matches[0], nil
 	}
-	if ignoreFzf {
-		return "", fmt.Errorf("context query %q matches multiple entries and fzf is disabled; provide a full context name or enable fzf", query)
-	}
-	if err := checkFzf(); err != nil {
-		return "", err
-	}
-	choice, err := ChooseWithFzf(matches, "context query: "+query)
-	if err != nil {
-		return "", err
-	}
-	if choice == "" {
-		return "", fmt.Errorf("no context selected")
-	}
-	return choice, nil
+	traceFrom(ctx).decision("context", "%q is part of %d context names (%s); choosing", query, len(matches), strings.Join(matches, ", "))
+	sel := Selection{Kind: "context", Header: "context query: " + query, Items: plainPickerItems(matches)}
+	return selectOne(ctx, selector, sel, fmt.Sprintf("context query %q matches multiple entries and the picker is disabled; provide a full context name or choose a --picker", query))
 }
 
-func checkFzf() error {
-	if _, err := exec.LookPath("fzf"); err != nil {
-		return fmt.Errorf("fzf not found")
-	}
-	return nil
+// execTarget is the resolved exec destination. Query is the pod query used
+// during resolution; it scopes the search for replacement pods when the
+// original one goes away.
+type execTarget struct {
+	context   string
+	namespace string
+	pod       string
+	container string
+	query     PodQuery
+}
+
+// execOptions controls how the resolved target is exec'd.
+type execOptions struct {
+	command        []string
+	dryRun         bool
+	confirmContext bool
+	confirm        confirmPolicy
+	// keywords are the confirm-context-keywords of the context.
+	keywords       []string
+	nonInteractive bool
+	reconnect      int
+	replay         bool
+	script         *execScript
+	env            []EnvVar
+	contextMatches []contextMatch
+	banner         bool
+	bannerColor    string
+	terminalTitle  bool
+	streams        Executor
+}
+
+// executor returns the Executor for one exec attempt. Scripts are streamed
+// over stdin, so they always get -i and never a TTY.
+func (o execOptions) executor() Executor {
+	e := o.streams
+	e.Env = o.env
+	if o.script != nil {
+		e.Stdin = bytes.NewReader(o.script.content)
+	} else if o.nonInteractive {
+		e.Stdin = nil
+	}
+	return e
 }
 
-func execOrPrint(context, namespace, pod, container string, command []string, dryRun bool, confirmContext bool, nonInteractive bool) error {
-	if dryRun {
-		args := ExecArgs(context, namespace, pod, container, command, nonInteractive)
-		fmt.Fprintln(os.Stdout, "kubectl "+strings.Join(args, " "))
+// args returns the kubectl exec arguments.
+func (o execOptions) args(target execTarget) []string {
+	return o.executor().args(target.target(), o.command)
+}
+
+func execOrPrint(ctx context.Context, target execTarget, opts execOptions) error {
+	if opts.dryRun {
+		for _, match := range opts.contextMatches {
+			fmt.Fprintln(opts.streams.Stdout, "# "+match.describe(target.context))
+		}
+		printed := opts
+		printed.env = redactEnv(opts.env)
+		if len(printed.env) > 0 && !printed.executor().tty() {
+			fmt.Fprintln(opts.streams.Stdout, "# env over stdin: "+formatEnv(printed.env))
+		}
+		line := "kubectl " + strings.Join(printed.args(target), " ")
+		if opts.script != nil {
+			line += " < " + opts.script.name
+		}
+		fmt.Fprintln(opts.streams.Stdout, line)
 		return nil
 	}
-	if confirmContext && confirmContextMatch(context, namespace) {
-		if err := confirmContextPrompt(context, namespace); err != nil {
+	if opts.banner {
+		writeBanner(ctx, opts.streams.Stderr, target, opts.bannerColor, time.Now())
+	}
+	if opts.confirmContext && confirmContextMatch(target.context, target.namespace, opts.keywords) {
+		if err := confirmContextPrompt(ctx, target.context, target.namespace, opts.confirm, opts.streams.Stderr); err != nil {
 			return err
 		}
 	}
-	return ExecPod(context, namespace, pod, container, command, nonInteractive)
+	if opts.terminalTitle && terminalStream(opts.streams.Stderr) {
+		pushTerminalTitle(opts.streams.Stderr, target)
+		defer popTerminalTitle(opts.streams.Stderr)
+	}
+	if len(opts.env) > 0 && opts.executor().tty() {
+		fmt.Fprintln(opts.streams.Stderr, "kubeexec: a TTY session gets environment values on the kubectl command line; use --non-interactive to send them over stdin")
+	}
+	if opts.reconnect > 0 {
+		return execWithReconnect(ctx, target, opts, opts.reconnect, opts.streams.Stderr)
+	}
+	return opts.executor().Exec(ctx, target.target(), opts.command...)
 }
@@ -1,34 +1,41 @@
 package cmdutil
 
 import (
+	"context"
+	"os"
+	"reflect"
 	"testing"
 )
 
-func TestPodFromChoice(t *testing.T) {
+func TestPodFromKey(t *testing.T) {
 	pods := []PodItem{
 		{Name: "pod-a", Namespace: "ns1", Display: "pod-a  1/1  Running"},
 		{Name: "pod-b", Namespace: "ns2", Display: "pod-b  0/1  Pending"},
+		{Name: "pod-a", Namespace: "ns2", Display: "pod-a  1/1  Running"},
 	}
 
 	tests := []struct {
-		name    string
-		choice  string
+		name     string
+		key      string
 		wantName string
-		wantOk  bool
+		wantNs   string
+		wantOk   bool
 	}{
-		{"match first", "pod-a  1/1  Running", "pod-a", true},
-		{"match second", "pod-b  0/1  Pending", "pod-b", true},
-		{"no match", "pod-c  1/1  Running", "", false},
-		{"empty", "", "", false},
+		{"match first", "ns1/pod-a", "pod-a", "ns1", true},
+		{"match second", "ns2/pod-b", "pod-b", "ns2", true},
+		{"same display different namespace", "ns2/pod-a", "pod-a", "ns2", true},
+		{"display text is not a key", "pod-a  1/1  Running", "", "", false},
+		{"no match", "ns1/pod-c", "", "", false},
+		{"empty", "", "", "", false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, ok := podFromChoice(pods, tt.choice)
+			got, ok := podFromKey(pods, tt.key)
 			if ok != tt.wantOk {
-				t.Errorf("podFromChoice(pods, %q) ok = %v, want %v", tt.choice, ok, tt.wantOk)
+				t.Errorf("podFromKey(pods, %q) ok = %v, want %v", tt.key, ok, tt.wantOk)
 			}
-			if ok && got.Name != tt.wantName {
-				t.Errorf("podFromChoice(pods, %q).Name = %q, want %q", tt.choice, got.Name, tt.wantName)
+			if ok && (got.Name != tt.wantName || got.Namespace != tt.wantNs) {
+				t.Errorf("podFromKey(pods, %q) = %s, want %s/%s", tt.key, got.Key(), tt.wantNs, tt.wantName)
 			}
 		})
 	}
@@ -61,47 +68,31 @@ func TestSplitPodNamespaceArg(t *testing.T) {
 	}
 }
 
-func TestPodExistsInNamespace(t *testing.T) {
-	pods := []PodItem{
-		{Name: "pod-a", Namespace: "ns1"},
-		{Name: "pod-b", Namespace: "ns2"},
-	}
-
-	if !podExistsInNamespace(pods, "ns1", "pod-a") {
-		t.Error("expected to find pod-a in ns1")
-	}
-	if podExistsInNamespace(pods, "ns2", "pod-a") {
-		t.Error("expected not to find pod-a in ns2")
-	}
-	if podExistsInNamespace(pods, "ns1", "pod-c") {
-		t.Error("expected not to find pod-c")
-	}
-}
-
 func TestBuildPodHeader(t *testing.T) {
 	tests := []struct {
 		name          string
 		context       string
 		namespace     string
-		selector      string
+		query         PodQuery
 		podQuery      string
 		allNamespaces bool
 		want          string
 	}{
-		{"all fields", "ctx", "ns", "app=api", "pod: my-pod", false, "context: ctx  namespace: ns  selector: app=api  pod: my-pod"},
-		{"context and namespace only", "ctx", "ns", "", "", false, "context: ctx  namespace: ns"},
-		{"namespace only", "", "ns", "", "", false, "namespace: ns"},
-		{"empty", "", "", "", "", false, ""},
-		{"selector only", "", "", "app=web", "", false, "selector: app=web"},
-		{"context and pod query", "ctx", "", "", "pod: api", false, "context: ctx  pod: api"},
-		{"all namespaces", "ctx", "", "", "", true, "context: ctx  namespace: all"},
+		{"all fields", "ctx", "ns", PodQuery{Selector: "app=api"}, "pod: my-pod", false, "context: ctx  namespace: ns  selector: app=api  pod: my-pod"},
+		{"context and namespace only", "ctx", "ns", PodQuery{}, "", false, "context: ctx  namespace: ns"},
+		{"namespace only", "", "ns", PodQuery{}, "", false, "namespace: ns"},
+		{"empty", "", "", PodQuery{}, "", false, ""},
+		{"selector only", "", "", PodQuery{Selector: "app=web"}, "", false, "selector: app=web"},
+		{"context and pod query", "ctx", "", PodQuery{}, "pod: api", false, "context: ctx  pod: api"},
+		{"all namespaces", "ctx", "", PodQuery{}, "", true, "context: ctx  namespace: all"},
+		{"filters", "ctx", "ns", PodQuery{Selector: "app=api", Node: "ip-10", Owner: "deployment/api", Phase: "Running"}, "", false, "context: ctx  namespace: ns  selector: app=api  node: ip-10  owner: deployment/api  phase: Running"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := buildPodHeader(tt.context, tt.namespace, tt.selector, tt.podQuery, tt.allNamespaces)
+			got := buildPodHeader(tt.context, tt.namespace, tt.query, tt.podQuery, tt.allNamespaces)
 			if got != tt.want {
-				t.Errorf("buildPodHeader(%q, %q, %q, %q, %v) = %q, want %q",
-					tt.context, tt.namespace, tt.selector, tt.podQuery, tt.allNamespaces, got, tt.want)
+				t.Errorf("buildPodHeader(%q, %q, %+v, %q, %v) = %q, want %q",
+					tt.context, tt.namespace, tt.query, tt.podQuery, tt.allNamespaces, got, tt.want)
 			}
 		})
 	}
@@ -185,38 +176,25 @@ func TestFilterByQuery(t *testing.T) {
 	}
 }
 
-func TestPodExists(t *testing.T) {
+func TestPodPickerItems(t *testing.T) {
 	pods := []PodItem{
-		{Name: "pod-a"},
-		{Name: "pod-b"},
-	}
-
-	if !podExists(pods, "pod-a") {
-		t.Error("expected podExists to find pod-a")
+		{Name: "pod-a", Namespace: "ns", Display: "pod-a  1/1  Running"},
+		{Name: "pod-b", Namespace: "ns", Display: ""},
+		{Name: "pod-c", Namespace: "ns", Display: "pod-c  0/1  Pending"},
 	}
-	if podExists(pods, "pod-c") {
-		t.Error("expected podExists not to find pod-c")
-	}
-	if podExists(nil, "pod-a") {
-		t.Error("expected podExists to return false for nil slice")
-	}
-}
 
-func TestPodDisplays(t *testing.T) {
-	pods := []PodItem{
-		{Name: "pod-a", Display: "pod-a  1/1  Running"},
-		{Name: "pod-b", Display: ""},
-		{Name: "pod-c", Display: "pod-c  0/1  Pending"},
+	got := podPickerItems(pods)
+	expected := []PickerItem{
+		{Key: "ns/pod-a", Display: "pod-a  1/1  Running"},
+		{Key: "ns/pod-b", Display: "pod-b"},
+		{Key: "ns/pod-c", Display: "pod-c  0/1  Pending"},
 	}
-
-	got := podDisplays(pods)
-	expected := []string{"pod-a  1/1  Running", "pod-b", "pod-c  0/1  Pending"}
 	if len(got) != len(expected) {
-		t.Fatalf("podDisplays returned %d items, want %d", len(got), len(expected))
+		t.Fatalf("podPickerItems returned %d items, want %d", len(got), len(expected))
 	}
 	for i, v := range got {
 		if v != expected[i] {
-			t.Errorf("podDisplays[%d] = %q, want %q", i, v, expected[i])
+			t.Errorf("podPickerItems[%d] = %+v, want %+v", i, v, expected[i])
 		}
 	}
 }
@@ -233,3 +211,320 @@ func TestContains(t *testing.T) {
 		t.Error("expected contains to return false for nil slice")
 	}
 }
+
+func fakeRunCluster() fakeCluster {
+	app := []fakeContainer{{Name: "app"}}
+	pods := []fakePod{
+		{Namespace: "payments", Name: "api-1", Node: "node-a", Labels: map[string]string{"app": "api"}, Containers: app},
+		{Namespace: "payments", Name: "api-2", Node: "node-b", Labels: map[string]string{"app": "api"}, Containers: app},
+		{Namespace: "payments", Name: "worker-1", Node: "node-b", Labels: map[string]string{"app": "worker"}, Containers: app},
+		{Namespace: "payments", Name: "multi-1", Annotations: map[string]string{defaultContainerAnnotation: "app"}, Containers: []fakeContainer{{Name: "app"}, {Name: "proxy"}}},
+		{Namespace: "payments", Name: "multi-2", Containers: []fakeContainer{{Name: "app"}, {Name: "proxy", Waiting: "CrashLoopBackOff"}}},
+		{Namespace: "kube-system", Name: "api-1", Containers: app},
+		{Namespace: "kube-system", Name: "coredns-1", Containers: app},
+	}
+	return fakeCluster{
+		CurrentContext: "dev",
+		Contexts: []fakeContext{
+			{Name: "dev", Namespace: "payments", Server: "https://dev.example.com:6443", Pods: pods},
+			{Name: "prod-eu", Namespace: "payments", Pods: pods},
+			{Name: "staging", Namespace: "kube-system", Pods: pods},
+		},
+	}
+}
+
+// wantExec is the kubectl exec argv for an interactive shell, or for command.
+func wantExec(context, namespace, pod, container string, command ...string) []string {
+	args := []string{"--context", context, "exec", "-i"}
+	if isTerminal(os.Stdin) && isTerminal(os.Stdout) {
+		args = append(args, "-t")
+	}
+	args = append(args, "-n", namespace, pod, "-c", container, "--")
+	if len(command) == 0 {
+		command = []string{"sh", "-c", "command -v bash >/dev/null 2>&1 && exec bash || exec sh"}
+	}
+	return append(args, command...)
+}
+
+func TestRunResolution(t *testing.T) {
+	const scripted = "scripted"
+	tests := []struct {
+		name   string
+		opts   RunOptions
+		config string
+		picks  []string
+		// offered are the keys of each picker run.
+		offered  [][]string
+		wantExec []string
+		wantKind ErrorKind
+	}{
+		{
+			name:     "exact pod in the context namespace",
+			opts:     RunOptions{Pod: "api-1", Picker: "none"},
+			wantExec: wantExec("dev", "payments", "api-1", "app"),
+		},
+		{
+			name:     "unique partial match",
+			opts:     RunOptions{Pod: "work", Picker: "none"},
+			wantExec: wantExec("dev", "payments", "worker-1", "app"),
+		},
+		{
+			name:     "partial match in a namespace flag",
+			opts:     RunOptions{Pod: "core", Namespace: "kube-system", Picker: "none"},
+			wantExec: wantExec("dev", "kube-system", "coredns-1", "app"),
+		},
+		{
+			name:     "ambiguous partial match with the picker disabled",
+			opts:     RunOptions{Pod: "api", Picker: "none"},
+			wantKind: KindAmbiguous,
+		},
+		{
+			name:     "ambiguous partial match chosen in the picker",
+			opts:     RunOptions{Pod: "api", Picker: scripted},
+			picks:    []string{"payments/api-2"},
+			offered:  [][]string{{"payments/api-1", "payments/api-2"}},
+			wantExec: wantExec("dev", "payments", "api-2", "app"),
+		},
+		{
+			name:     "no pod with the picker disabled",
+			opts:     RunOptions{Picker: "none"},
+			wantKind: KindAmbiguous,
+		},
+		{
+			name:     "picker cancelled",
+			opts:     RunOptions{Picker: scripted},
+			picks:    []string{""},
+			offered:  [][]string{{"payments/api-1", "payments/api-2", "payments/worker-1", "payments/multi-1", "payments/multi-2"}},
+			wantKind: KindCancelled,
+		},
+		{
+			name:     "selector narrows the picker",
+			opts:     RunOptions{Selector: "app=api", Picker: scripted},
+			picks:    []string{"payments/api-1"},
+			offered:  [][]string{{"payments/api-1", "payments/api-2"}},
+			wantExec: wantExec("dev", "payments", "api-1", "app"),
+		},
+		{
+			name:     "partial node name",
+			opts:     RunOptions{Node: "-a", Pod: "api", Picker: "none"},
+			wantExec: wantExec("dev", "payments", "api-1", "app"),
+		},
+		{
+			name:     "no pods match",
+			opts:     RunOptions{Pod: "nginx", Picker: "none"},
+			wantKind: KindResolution,
+		},
+		{
+			name:     "all namespaces with namespace/pod",
+			opts:     RunOptions{AllNamespaces: true, Pod: "kube-system/api-1", Picker: "none"},
+			wantExec: wantExec("dev", "kube-system", "api-1", "app"),
+		},
+		{
+			name:     "all namespaces with a unique exact name",
+			opts:     RunOptions{AllNamespaces: true, Pod: "coredns-1", Picker: "none"},
+			wantExec: wantExec("dev", "kube-system", "coredns-1", "app"),
+		},
+		{
+			name:     "all namespaces with a name in two namespaces",
+			opts:     RunOptions{AllNamespaces: true, Pod: "api-1", Picker: scripted},
+			picks:    []string{"kube-system/api-1"},
+			offered:  [][]string{{"payments/api-1", "kube-system/api-1"}},
+			wantExec: wantExec("dev", "kube-system", "api-1", "app"),
+		},
+		{
+			name:     "all namespaces ambiguous with the picker disabled",
+			opts:     RunOptions{AllNamespaces: true, Pod: "api-1", Picker: "none"},
+			wantKind: KindAmbiguous,
+		},
+		{
+			name:     "all namespaces with a missing namespace/pod",
+			opts:     RunOptions{AllNamespaces: true, Pod: "payments/coredns-1", Picker: "none"},
+			wantKind: KindResolution,
+		},
+		{
+			name:     "default container annotation",
+			opts:     RunOptions{Pod: "multi-1", Picker: "none"},
+			wantExec: wantExec("dev", "payments", "multi-1", "app"),
+		},
+		{
+			name:     "requested container",
+			opts:     RunOptions{Pod: "multi-1", Container: "proxy", Picker: "none"},
+			wantExec: wantExec("dev", "payments", "multi-1", "proxy"),
+		},
+		{
+			name:     "requested container not in the pod",
+			opts:     RunOptions{Pod: "multi-1", Container: "db", Picker: "none"},
+			wantKind: KindResolution,
+		},
+		{
+			name:     "several containers with the picker disabled",
+			opts:     RunOptions{Pod: "multi-2", Picker: "none"},
+			wantKind: KindAmbiguous,
+		},
+		{
+			name:     "container chosen in the picker",
+			opts:     RunOptions{Pod: "multi-2", Picker: scripted},
+			picks:    []string{"app"},
+			offered:  [][]string{{"app", "proxy"}},
+			wantExec: wantExec("dev", "payments", "multi-2", "app"),
+		},
+		{
+			name:     "chosen container is not running",
+			opts:     RunOptions{Pod: "multi-2", Picker: scripted},
+			picks:    []string{"proxy"},
+			offered:  [][]string{{"app", "proxy"}},
+			wantKind: KindResolution,
+		},
+		{
+			name:     "pod and container both chosen",
+			opts:     RunOptions{Pod: "multi", Picker: scripted},
+			picks:    []string{"payments/multi-2", "app"},
+			offered:  [][]string{{"payments/multi-1", "payments/multi-2"}, {"app", "proxy"}},
+			wantExec: wantExec("dev", "payments", "multi-2", "app"),
+		},
+		{
+			name:     "partial context uses its namespace",
+			opts:     RunOptions{Context: "stag", ContextRequested: true, Pod: "core", Picker: "none"},
+			wantExec: wantExec("staging", "kube-system", "coredns-1", "app"),
+		},
+		{
+			name:     "context chosen in the picker",
+			opts:     RunOptions{ContextRequested: true, Pod: "api-2", Picker: scripted},
+			picks:    []string{"prod-eu"},
+			offered:  [][]string{{"dev", "prod-eu", "staging"}},
+			wantExec: wantExec("prod-eu", "payments", "api-2", "app"),
+		},
+		{
+			name:     "command",
+			opts:     RunOptions{Pod: "api-1", Command: []string{"env"}, Env: []string{"DEBUG=1"}, Picker: "none"},
+			wantExec: wantExec("dev", "payments", "api-1", "app", envStdinCommand([]string{"env"})...),
+		},
+		{
+			name:     "shell from the config",
+			opts:     RunOptions{Pod: "api-1", Picker: "none"},
+			config:   "shell = \"bash\"\n",
+			wantExec: wantExec("dev", "payments", "api-1", "app", interactiveShellCommand("bash")...),
+		},
+		{
+			name:     "confirmation on a prod context without a terminal",
+			opts:     RunOptions{Context: "prod-eu", Pod: "api-1", ConfirmContext: true, Picker: "none"},
+			wantKind: KindDenied,
+		},
+		{
+			name:     "confirmation not needed outside prod",
+			opts:     RunOptions{Pod: "api-1", ConfirmContext: true, Picker: "none"},
+			wantExec: wantExec("dev", "payments", "api-1", "app"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newFakeCluster(t, fakeRunCluster(), tt.picks...)
+			if tt.config != "" {
+				h.writeConfig(t, tt.config)
+			}
+			opts := tt.opts
+			if opts.Picker == scripted {
+				opts.Picker = h.picker()
+			}
+			err := Run(context.Background(), opts)
+			if tt.wantKind != 0 {
+				if !IsKind(err, tt.wantKind) {
+					t.Errorf("Run() error = %v, want kind %d", err, tt.wantKind)
+				}
+			} else if err != nil {
+				t.Errorf("Run() error: %v", err)
+			}
+
+			var want [][]string
+			if tt.wantExec != nil {
+				want = [][]string{tt.wantExec}
+			}
+			if got := h.execs(t); !reflect.DeepEqual(got, want) {
+				t.Errorf("kubectl exec calls = %q, want %q", got, want)
+			}
+			if got := h.pickerItems(t); !reflect.DeepEqual(got, tt.offered) {
+				t.Errorf("picker offered %q, want %q", got, tt.offered)
+			}
+		})
+	}
+}
+
+func TestRunRemoteExitCode(t *testing.T) {
+	cluster := fakeRunCluster()
+	cluster.ExecExit = 3
+	newFakeCluster(t, cluster)
+	err := Run(context.Background(), RunOptions{Pod: "api-1", Command: []string{"false"}, Picker: "none"})
+	if code := ExitCode(err); code != 3 {
+		t.Errorf("ExitCode(Run()) = %d (%v), want the remote status 3", code, err)
+	}
+}
+
+func TestRunKubectlExecFailure(t *testing.T) {
+	cluster := fakeRunCluster()
+	cluster.ExecExit = 1
+	cluster.ExecStderr = "error: unable to upgrade connection: container not found (\"app\")\n"
+	newFakeCluster(t, cluster)
+	err := Run(context.Background(), RunOptions{Pod: "api-1", Command: []string{"true"}, Picker: "none"})
+	if code := ExitCode(err); code != ExitKubectl {
+		t.Errorf("ExitCode(Run()) = %d (%v), want %d for a failing kubectl exec", code, err, ExitKubectl)
+	}
+}
+
+func TestRunKeepsContextSettingsLocal(t *testing.T) {
+	h := newFakeCluster(t, fakeRunCluster())
+	h.writeConfig(t, "[context.\"dev\"]\nshell = \"ash\"\n")
+	settings, err := ResolveSettings(map[string]string{"picker": "none"})
+	if err != nil {
+		t.Fatalf("ResolveSettings() error: %v", err)
+	}
+	opts := RunOptions{Pod: "api-1"}
+	settings.ApplyRunOptions(&opts)
+	if err := Run(context.Background(), opts); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if got := h.execs(t); len(got) != 1 || !contains(got[0], "command -v ash >/dev/null 2>&1 && exec ash || exec sh") {
+		t.Errorf("kubectl exec calls = %q, want the dev section's shell", got)
+	}
+	after, err := loadSettings()
+	if err != nil {
+		t.Fatalf("loadSettings() error: %v", err)
+	}
+	if shell := after.String("shell"); shell != "" {
+		t.Errorf("loadSettings() after Run has shell %q, want the dev section not applied", shell)
+	}
+}
+
+func TestRunChooser(t *testing.T) {
+	h := newFakeCluster(t, fakeRunCluster())
+	chooser := &ScriptedSelector{Keys: []string{"payments/multi-2", "app"}}
+	err := Run(context.Background(), RunOptions{Pod: "multi", Picker: h.picker(), Chooser: chooser})
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	var kinds []string
+	for _, sel := range chooser.Asked {
+		kinds = append(kinds, sel.Kind)
+	}
+	if !reflect.DeepEqual(kinds, []string{"pod", "container"}) {
+		t.Errorf("Chooser asked for %q, want pod then container", kinds)
+	}
+	if chooser.Asked[0].Preview != podPreview("dev") {
+		t.Errorf("pod selection preview = %q, want %q", chooser.Asked[0].Preview, podPreview("dev"))
+	}
+	if got := h.pickerItems(t); got != nil {
+		t.Errorf("picker ran with a Chooser set: %q", got)
+	}
+	want := [][]string{wantExec("dev", "payments", "multi-2", "app")}
+	if got := h.execs(t); !reflect.DeepEqual(got, want) {
+		t.Errorf("kubectl exec calls = %q, want %q", got, want)
+	}
+
+	h = newFakeCluster(t, fakeRunCluster())
+	err = Run(context.Background(), RunOptions{Pod: "api", Picker: h.picker(), Chooser: NonInteractiveSelector{}})
+	if !IsKind(err, KindAmbiguous) {
+		t.Errorf("Run() with NonInteractiveSelector = %v, want ambiguous error", err)
+	}
+	if got := h.execs(t); got != nil {
+		t.Errorf("kubectl exec calls = %q, want none", got)
+	}
+}
@@ -0,0 +1,64 @@
+package cmdutil
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	defaultShell = "sh"
+)
+
+// execScript is a local script streamed into the container over exec stdin,
+// so nothing is written to the container filesystem.
+type execScript struct {
+	name    string
+	content []byte
+}
+
+// loadScript reads the script at path, or from stdin when path is "-".
+// It returns nil when no script was requested.
+func loadScript(path string, stdin io.Reader) (*execScript, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if path == "-" {
+		content, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, usageErrorf("read script from stdin: %w", err)
+		}
+		return &execScript{name: "<stdin>", content: content}, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, usageErrorf("read script: %w", err)
+	}
+	return &execScript{name: path, content: content}, nil
+}
+
+// shellFromSettings returns the configured remote shell (env: KUBEEXEC_SHELL,
+// config: shell), or "" when none is set.
+func shellFromSettings(settings *Settings) (string, error) {
+	shell := strings.TrimSpace(settings.String("shell"))
+	if strings.ContainsAny(shell, " \t\n") {
+		return "", usageErrorf("invalid shell %q (expected a single executable name or path)", shell)
+	}
+	return shell, nil
+}
+
+// scriptCommand runs the script read from stdin with args as its positional
+// parameters.
+func scriptCommand(shell string, args []string) []string {
+	if shell == "" {
+		shell = defaultShell
+	}
+	return append([]string{shell, "-s", "--"}, args...)
+}
+
+// interactiveShellCommand starts the configured shell, falling back to sh
+// when the image does not have it.
+func interactiveShellCommand(shell string) []string {
+	return []string{"sh", "-c", fmt.Sprintf("command -v %s >/dev/null 2>&1 && exec %s || exec sh", shell, shell)}
+}
@@ -0,0 +1,109 @@
+package cmdutil
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadScript(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "diag.sh")
+	if err := os.WriteFile(path, []byte("echo $1\n"), 0o600); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+
+	script, err := loadScript(path, nil)
+	if err != nil {
+		t.Fatalf("loadScript(file) error: %v", err)
+	}
+	if script.name != path || string(script.content) != "echo $1\n" {
+		t.Errorf("loadScript(file) = %+v", script)
+	}
+
+	script, err = loadScript("-", strings.NewReader("uptime\n"))
+	if err != nil {
+		t.Fatalf("loadScript(-) error: %v", err)
+	}
+	if script.name != "<stdin>" || string(script.content) != "uptime\n" {
+		t.Errorf("loadScript(-) = %+v", script)
+	}
+
+	if script, err := loadScript("", nil); script != nil || err != nil {
+		t.Errorf("loadScript(\"\") = %+v, %v; want nil, nil", script, err)
+	}
+
+	_, err = loadScript(filepath.Join(dir, "missing.sh"), nil)
+	if !IsKind(err, KindUsage) {
+		t.Errorf("loadScript(missing) error = %v, want usage error", err)
+	}
+}
+
+func TestScriptCommand(t *testing.T) {
+	got := strings.Join(scriptCommand("", []string{"a", "b c"}), "|")
+	if want := "sh|-s|--|a|b c"; got != want {
+		t.Errorf("scriptCommand(\"\") = %q, want %q", got, want)
+	}
+	got = strings.Join(scriptCommand("bash", nil), "|")
+	if want := "bash|-s|--"; got != want {
+		t.Errorf("scriptCommand(bash) = %q, want %q", got, want)
+	}
+}
+
+func TestInteractiveShellCommand(t *testing.T) {
+	got := interactiveShellCommand("zsh")
+	want := []string{"sh", "-c", "command -v zsh >/dev/null 2>&1 && exec zsh || exec sh"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("interactiveShellCommand(zsh) = %v, want %v", got, want)
+	}
+}
+
+func TestRunScriptShell(t *testing.T) {
+	tests := []struct {
+		name     string
+		context  string
+		env      string
+		want     string
+		wantKind ErrorKind
+	}{
+		{name: "config", context: "dev", want: "bash"},
+		{name: "context section", context: "prod-eu", want: "zsh"},
+		{name: "env over config", context: "prod-eu", env: "ash", want: "ash"},
+		{name: "not a single executable", context: "dev", env: "bash -l", wantKind: KindUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newFakeCluster(t, fakeRunCluster())
+			h.writeConfig(t, "shell = \"bash\"\n[context.\"prod-*\"]\nshell = \"zsh\"\n")
+			if tt.env != "" {
+				t.Setenv("KUBEEXEC_SHELL", tt.env)
+			}
+			path := filepath.Join(t.TempDir(), "diag.sh")
+			if err := os.WriteFile(path, []byte("uptime\n"), 0o600); err != nil {
+				t.Fatalf("write script: %v", err)
+			}
+			settings, err := ResolveSettings(map[string]string{"picker": "none"})
+			if err != nil {
+				t.Fatalf("ResolveSettings() error: %v", err)
+			}
+			opts := RunOptions{Context: tt.context, Pod: "api-1", Script: path}
+			settings.ApplyRunOptions(&opts)
+			err = Run(context.Background(), opts)
+			if tt.wantKind != 0 {
+				if !IsKind(err, tt.wantKind) {
+					t.Errorf("Run() error = %v, want kind %d", err, tt.wantKind)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run() error: %v", err)
+			}
+			execs := h.execs(t)
+			if len(execs) != 1 || !strings.HasSuffix(strings.Join(execs[0], " "), "-- "+tt.want+" -s --") {
+				t.Errorf("kubectl exec calls = %q, want the script run with %s", execs, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package cmdutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Selection is one choice Run cannot make on its own.
+type Selection struct {
+	// Kind is what is being chosen: "context", "pod", "container" or
+	// "snippet".
+	Kind   string
+	Header string
+	// Items are the choices. Keys are stable across refreshes and are what
+	// Select returns.
+	Items []PickerItem
+	Multi bool
+	// Preview is a shell command that pickers with a preview pane run for the
+	// highlighted item; {key} stands for its key.
+	Preview string
+	// Refresh returns the current items of a live list after Changed
+	// receives a value. Both are nil for a fixed list.
+	Refresh func() []PickerItem
+	Changed <-chan struct{}
+}
+
+// current returns the latest items of the selection.
+func (s Selection) current() []PickerItem {
+	if s.Refresh != nil {
+		return s.Refresh()
+	}
+	return s.Items
+}
+
+// Selector makes the choices Run needs. Select returns the keys of the chosen
+// items (at most one unless Multi), nil when the user cancelled, or
+// ErrSelectionDisabled when the selector never prompts. It should stop
+// prompting when ctx is cancelled.
+type Selector interface {
+	Select(ctx context.Context, sel Selection) ([]string, error)
+}
+
+// ErrSelectionDisabled is returned by selectors that refuse every choice;
+// Run reports it as an ambiguous target.
+var ErrSelectionDisabled = errors.New("selection disabled")
+
+// NewPicker returns the Selector for a picker setting value: fzf, skim,
+// builtin, none, a [pickers.<name>] table or a command line.
+func NewPicker(name string) (Selector, error) {
+	return resolvePicker(name)
+}
+
+// NonInteractiveSelector never prompts, so every ambiguous target fails.
+type NonInteractiveSelector struct{}
+
+func (NonInteractiveSelector) Select(context.Context, Selection) ([]string, error) {
+	return nil, ErrSelectionDisabled
+}
+
+// ScriptedSelector answers selections with Keys in order, for tests and
+// programs that already know what to choose. An empty key, or running out of
+// keys, cancels. Asked records every selection it was given.
+type ScriptedSelector struct {
+	Keys  []string
+	Asked []Selection
+}
+
+func (s *ScriptedSelector) Select(_ context.Context, sel Selection) ([]string, error) {
+	s.Asked = append(s.Asked, sel)
+	if len(s.Asked) > len(s.Keys) || s.Keys[len(s.Asked)-1] == "" {
+		return nil, nil
+	}
+	key := s.Keys[len(s.Asked)-1]
+	for _, item := range sel.current() {
+		if item.Key == key {
+			return []string{key}, nil
+		}
+	}
+	return nil, fmt.Errorf("scripted %s %q is not one of the choices", sel.Kind, key)
+}
+
+// selectOne asks selector for one item of sel. disabled is the error message
+// when the selector never prompts; it should say what to pass instead.
+func selectOne(ctx context.Context, selector Selector, sel Selection, disabled string) (string, error) {
+	keys, err := selector.Select(ctx, sel)
+	if errors.Is(err, ErrSelectionDisabled) {
+		return "", ambiguousErrorf("%s", disabled)
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(keys) == 0 {
+		return "", cancelledErrorf("no %s selected", sel.Kind)
+	}
+	traceFrom(ctx).decision(sel.Kind, "%q chosen from %d items", keys[0], len(sel.Items))
+	return keys[0], nil
+}
+
+// showsChanges reports whether selector can show a list that changes while it
+// is open, so live lists are only watched when someone can see them: pickers
+// that are builtin or reloaded through listen-args, and selectors other than
+// the built-in ones.
+func showsChanges(selector Selector) bool {
+	switch s := selector.(type) {
+	case picker:
+		return s.live()
+	case NonInteractiveSelector, *ScriptedSelector:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+package cmdutil
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestScriptedSelector(t *testing.T) {
+	items := []PickerItem{{Key: "ns/api-1"}, {Key: "ns/api-2"}}
+	tests := []struct {
+		name     string
+		keys     []string
+		want     string
+		wantKind ErrorKind
+		wantErr  bool
+	}{
+		{name: "answers with the next key", keys: []string{"ns/api-2"}, want: "ns/api-2"},
+		{name: "empty key cancels", keys: []string{""}, wantKind: KindCancelled},
+		{name: "no keys left cancels", wantKind: KindCancelled},
+		{name: "unknown key", keys: []string{"ns/api-3"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector := &ScriptedSelector{Keys: tt.keys}
+			got, err := selectOne(context.Background(), selector, Selection{Kind: "pod", Items: items}, "disabled")
+			switch {
+			case tt.wantKind != 0:
+				if !IsKind(err, tt.wantKind) {
+					t.Errorf("selectOne() error = %v, want kind %d", err, tt.wantKind)
+				}
+			case tt.wantErr:
+				if err == nil {
+					t.Errorf("selectOne() = %q, want an error", got)
+				}
+			case err != nil || got != tt.want:
+				t.Errorf("selectOne() = %q, %v, want %q", got, err, tt.want)
+			}
+			if len(selector.Asked) != 1 || selector.Asked[0].Kind != "pod" {
+				t.Errorf("Asked = %+v, want the one pod selection", selector.Asked)
+			}
+		})
+	}
+}
+
+func TestScriptedSelectorRefresh(t *testing.T) {
+	sel := Selection{
+		Items:   []PickerItem{{Key: "ns/api-1"}},
+		Refresh: func() []PickerItem { return []PickerItem{{Key: "ns/api-1"}, {Key: "ns/api-2"}} },
+	}
+	got, err := (&ScriptedSelector{Keys: []string{"ns/api-2"}}).Select(context.Background(), sel)
+	if err != nil || !reflect.DeepEqual(got, []string{"ns/api-2"}) {
+		t.Errorf("Select() = %v, %v, want a key from the refreshed list", got, err)
+	}
+}
+
+func TestSelectOneDisabled(t *testing.T) {
+	for _, selector := range []Selector{NonInteractiveSelector{}, picker{name: pickerNone}} {
+		_, err := selectOne(context.Background(), selector, Selection{Kind: "context"}, "provide --context")
+		if !IsKind(err, KindAmbiguous) || err.Error() != "provide --context" {
+			t.Errorf("selectOne(%T) error = %v, want ambiguous \"provide --context\"", selector, err)
+		}
+	}
+	if _, err := (NonInteractiveSelector{}).Select(context.Background(), Selection{}); !errors.Is(err, ErrSelectionDisabled) {
+		t.Errorf("NonInteractiveSelector.Select() = %v, want ErrSelectionDisabled", err)
+	}
+}
+
+func TestSelectionArgsPreview(t *testing.T) {
+	p := picker{
+		args:        []string{"--delimiter", "{delimiter}"},
+		previewArgs: []string{"--preview", "{preview}"},
+	}
+	if got := p.selectionArgs(Selection{}); !reflect.DeepEqual(got, []string{"--delimiter", "\t"}) {
+		t.Errorf("selectionArgs() without a preview = %q", got)
+	}
+	got := p.selectionArgs(Selection{Preview: "describe {key}"})
+	if want := []string{"--delimiter", "\t", "--preview", "describe {1}"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("selectionArgs() with a preview = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,382 @@
+package cmdutil
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type settingKind int
+
+const (
+	boolSetting settingKind = iota
+	stringSetting
+	listSetting
+	durationSetting
+	intSetting
+)
+
+const sourceDefault = "default"
+
+// settingDef describes one setting. Every setting can be set in config files
+// under key and with the KUBEEXEC_<KEY> environment variable; flag settings
+// also have a CLI flag of the same name.
+type settingDef struct {
+	key  string
+	kind settingKind
+	flag bool
+	def  any
+	help string
+}
+
+var settingDefs = []settingDef{
+	{key: "namespace", kind: stringSetting, flag: true, def: "", help: "namespace to use instead of the context namespace (ignored with all-namespaces)"},
+	{key: "all-namespaces", kind: boolSetting, flag: true, def: false, help: "list pods across all namespaces"},
+	{key: "container", kind: stringSetting, flag: true, def: "", help: "container to exec into; from config or env it is used only when the pod has it"},
+	{key: "selector", kind: stringSetting, flag: true, def: "", help: "label selector for pods; from config or env it is used only when no pod is named"},
+	{key: "field-selector", kind: stringSetting, flag: true, def: "", help: "field selector for pods"},
+	{key: "node", kind: stringSetting, flag: true, def: "", help: "only pods on this node (exact or partial name)"},
+	{key: "owner", kind: stringSetting, flag: true, def: "", help: "only pods owned by kind/name"},
+	{key: "phase", kind: stringSetting, flag: true, def: "", help: "only pods in this phase"},
+	{key: "wait", kind: durationSetting, flag: true, def: time.Duration(0), help: "wait up to this long for the target container before exec (0 disables)"},
+	{key: "reconnect", kind: intSetting, flag: true, def: 0, help: "re-attach up to this many times when the exec session drops"},
+	{key: "reconnect-command", kind: boolSetting, flag: true, def: false, help: "with reconnect, also run -- <CMD>, --script and snippets again when the session drops"},
+	{key: "non-interactive", kind: boolSetting, flag: true, def: false, help: "run without stdin or TTY (no -i/-t)"},
+	{key: "confirm-context", kind: boolSetting, flag: true, def: false, help: "confirm when the context or namespace looks like prod"},
+	{key: "confirm-context-keywords", kind: listSetting, def: defaultConfirmContextKeywords, help: "name segments that trigger confirm-context"},
+	{key: "confirm-style", kind: stringSetting, flag: true, def: confirmStyleContext, help: "how confirm-context asks: " + strings.Join(confirmStyles, ", ")},
+	{key: "confirm-countdown", kind: durationSetting, def: confirmCountdownDefault, help: "how long the countdown confirm-style waits before continuing"},
+	{key: "confirm-token-hash", kind: stringSetting, def: "", help: "hex SHA-256 of the KUBEEXEC_CONFIRM_TOKEN that lets --yes-i-know skip the confirmation"},
+	{key: "picker", kind: stringSetting, flag: true, def: pickerFzf, help: "picker for ambiguous choices: fzf, skim, builtin, none (fail instead) or a command reading items on stdin"},
+	{key: "ignore-fzf", kind: boolSetting, flag: true, def: false, help: "same as picker = \"none\"; kept for older configs"},
+	{key: "picker-columns", kind: listSetting, def: defaultPodColumns, help: "pod picker columns"},
+	{key: "picker-sort", kind: stringSetting, def: podSortName, help: "pod picker sort order (" + strings.Join(podSortOrders, ", ") + ")"},
+	{key: "picker-colors", kind: boolSetting, def: true, help: "color the pod status in the picker (default off when NO_COLOR is set)"},
+	{key: "picker-watch", kind: boolSetting, def: true, help: "keep the pod picker current from a pod watch while it is open"},
+	{key: "shell", kind: stringSetting, def: "", help: "remote shell for sessions and --script"},
+	{key: "kubectl-timeout", kind: durationSetting, def: kubectlTimeoutDefault, help: "timeout for quick kubectl calls (contexts, namespaces, pod lookups)"},
+	{key: "pod-list-timeout", kind: durationSetting, def: kubectlTimeoutPods, help: "timeout for listing pods"},
+	{key: "banner", kind: boolSetting, flag: true, def: false, help: "print the context, cluster server, namespace, pod, container, image, node and pod age to stderr before exec"},
+	{key: "banner-color", kind: stringSetting, def: "", help: "banner color: red, green, yellow, blue, magenta or cyan (none when NO_COLOR is set)"},
+	{key: "terminal-title", kind: boolSetting, flag: true, def: false, help: "set the terminal title to context/namespace/pod during the session"},
+	{key: "verbose", kind: intSetting, flag: true, def: 0, help: "log kubectl calls and resolution decisions to stderr; 2 adds kubectl stderr and setting sources"},
+	{key: "trace-file", kind: stringSetting, flag: true, def: "", help: "write kubectl calls, resolution decisions and setting sources to this file as JSON lines"},
+}
+
+func lookupSettingDef(key string) (settingDef, bool) {
+	for _, def := range settingDefs {
+		if def.key == key {
+			return def, true
+		}
+	}
+	return settingDef{}, false
+}
+
+// IsSettingFlag reports whether the CLI flag name sets a setting.
+func IsSettingFlag(name string) bool {
+	def, ok := lookupSettingDef(name)
+	return ok && def.flag
+}
+
+func (d settingDef) envVar() string {
+	return "KUBEEXEC_" + strings.ToUpper(strings.ReplaceAll(d.key, "-", "_"))
+}
+
+func (d settingDef) valueHint() string {
+	switch d.kind {
+	case boolSetting:
+		return confirmBoolValueHint
+	case listSetting:
+		return "a comma-separated list"
+	case durationSetting:
+		return "a duration such as 30s or 2m"
+	case intSetting:
+		return "a non-negative integer"
+	default:
+		return "a string"
+	}
+}
+
+// parse converts a flag or environment value.
+func (d settingDef) parse(raw string) (any, bool) {
+	switch d.kind {
+	case boolSetting:
+		return ParseConfirmBool(raw)
+	case listSetting:
+		var values []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+		return values, true
+	case durationSetting:
+		value, err := time.ParseDuration(strings.TrimSpace(raw))
+		return value, err == nil && value >= 0
+	case intSetting:
+		value, err := strconv.Atoi(strings.TrimSpace(raw))
+		return value, err == nil && value >= 0
+	default:
+		return raw, true
+	}
+}
+
+// fromTOML converts a decoded config file value.
+func (d settingDef) fromTOML(value any) (any, error) {
+	switch d.kind {
+	case boolSetting:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("invalid value for %s: expected %s", d.key, confirmConfigValueHint)
+	case listSetting:
+		items, ok := value.([]any)
+		if !ok {
+			return nil, fmt.Errorf("invalid value for %s: expected an array of strings", d.key)
+		}
+		values := make([]string, 0, len(items))
+		for _, item := range items {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid value for %s: expected an array of strings", d.key)
+			}
+			values = append(values, s)
+		}
+		return values, nil
+	case durationSetting:
+		if s, ok := value.(string); ok {
+			if parsed, ok := d.parse(s); ok {
+				return parsed, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid value for %s: expected a duration string such as \"30s\" or \"2m\"", d.key)
+	case intSetting:
+		if n, ok := value.(int64); ok && n >= 0 {
+			return int(n), nil
+		}
+		return nil, fmt.Errorf("invalid value for %s: expected a non-negative integer", d.key)
+	default:
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("invalid value for %s: expected a string", d.key)
+	}
+}
+
+type settingValue struct {
+	value  any
+	source string
+}
+
+// Settings is the effective configuration, with the source of each value:
+// a flag, an environment variable, a config file or the default.
+type Settings struct {
+	values           map[string]settingValue
+	snippets         map[string]snippetConfig
+	snippetSources   map[string]string
+	pickers          map[string]pickerConfig
+	pickerSources    map[string]string
+	files            []configFile
+	contextOverrides []contextOverride
+	contextMatches   []contextMatch
+}
+
+// ResolveSettings merges defaults, config files, environment variables and
+// flags, in increasing precedence. flags holds the raw values of the setting
+// flags given on the command line, keyed by flag name.
+func ResolveSettings(flags map[string]string) (*Settings, error) {
+	files, err := loadConfigFiles()
+	if err != nil {
+		return nil, err
+	}
+	s := &Settings{
+		values:         make(map[string]settingValue, len(settingDefs)),
+		snippets:       make(map[string]snippetConfig),
+		snippetSources: make(map[string]string),
+		pickers:        make(map[string]pickerConfig),
+		pickerSources:  make(map[string]string),
+		files:          files,
+	}
+	for _, def := range settingDefs {
+		s.values[def.key] = settingValue{value: def.def, source: sourceDefault}
+	}
+	for _, file := range files {
+		for key, value := range file.values {
+			if file.layer.project() {
+				value = projectValue(key, value, s.values[key].value)
+			}
+			s.values[key] = settingValue{value: value, source: file.layer.describe()}
+		}
+		for name, snippet := range file.snippets {
+			s.snippets[name] = snippet
+			s.snippetSources[name] = file.layer.describe()
+		}
+		for name, picker := range file.pickers {
+			s.pickers[name] = picker
+			s.pickerSources[name] = file.layer.describe()
+		}
+		s.contextOverrides = append(s.contextOverrides, file.contexts...)
+	}
+	for _, def := range settingDefs {
+		env := def.envVar()
+		raw, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+		value, ok := def.parse(raw)
+		if !ok {
+			return nil, usageErrorf("invalid %s value %q (use %s)", env, raw, def.valueHint())
+		}
+		s.values[def.key] = settingValue{value: value, source: "env " + env}
+	}
+	for key, raw := range flags {
+		def, ok := lookupSettingDef(key)
+		if !ok || !def.flag {
+			return nil, usageErrorf("unknown setting flag --%s", key)
+		}
+		value, ok := def.parse(raw)
+		if !ok {
+			return nil, usageErrorf("invalid --%s value %q (use %s)", key, raw, def.valueHint())
+		}
+		s.values[key] = settingValue{value: value, source: "flag --" + key}
+	}
+	return s, nil
+}
+
+// loadSettings resolves settings from config files and the environment, for
+// code paths that have no flags of their own. Callers that know the
+// kubernetes context apply its sections with ForContext.
+func loadSettings() (*Settings, error) {
+	return ResolveSettings(nil)
+}
+
+func (s *Settings) Bool(key string) bool {
+	value, _ := s.values[key].value.(bool)
+	return value
+}
+
+func (s *Settings) String(key string) string {
+	value, _ := s.values[key].value.(string)
+	return value
+}
+
+func (s *Settings) Strings(key string) []string {
+	value, _ := s.values[key].value.([]string)
+	return value
+}
+
+func (s *Settings) Duration(key string) time.Duration {
+	value, _ := s.values[key].value.(time.Duration)
+	return value
+}
+
+func (s *Settings) Int(key string) int {
+	value, _ := s.values[key].value.(int)
+	return value
+}
+
+// Source describes where the value of key came from, e.g. "default",
+// "flag --namespace", "env KUBEEXEC_SHELL" or "project /repo/.kubeexec.toml".
+func (s *Settings) Source(key string) string {
+	return s.values[key].source
+}
+
+// IsSet reports whether key was set anywhere rather than left at its default.
+func (s *Settings) IsSet(key string) bool {
+	return s.Source(key) != sourceDefault
+}
+
+// fromFlag reports whether key was set on the command line.
+func (s *Settings) fromFlag(key string) bool {
+	return strings.HasPrefix(s.Source(key), "flag ")
+}
+
+// ApplyRunOptions fills opts from the settings. Values that come from the
+// environment or config files act as defaults: a configured namespace is
+// ignored in all-namespaces mode, a configured selector is not applied when a
+// pod is named, and a configured container is only a preference.
+func (s *Settings) ApplyRunOptions(opts *RunOptions) {
+	opts.Settings = s
+	opts.AllNamespaces = s.Bool("all-namespaces")
+	opts.Namespace = s.String("namespace")
+	if opts.Namespace != "" && !s.fromFlag("namespace") && opts.AllNamespaces {
+		opts.Namespace = ""
+	}
+	if s.fromFlag("namespace") && !s.fromFlag("all-namespaces") {
+		opts.AllNamespaces = false
+	}
+	if s.fromFlag("container") {
+		opts.Container = s.String("container")
+	} else {
+		opts.PreferredContainer = s.String("container")
+	}
+	if s.fromFlag("selector") || opts.Pod == "" {
+		opts.Selector = s.String("selector")
+	}
+	opts.FieldSelector = s.String("field-selector")
+	opts.Node = s.String("node")
+	opts.Owner = s.String("owner")
+	opts.Phase = s.String("phase")
+	opts.Wait = s.Duration("wait")
+	opts.Reconnect = s.Int("reconnect")
+	opts.ReconnectCommand = s.Bool("reconnect-command")
+	opts.NonInteractive = s.Bool("non-interactive")
+	opts.ConfirmContext = s.Bool("confirm-context")
+	opts.ConfirmStyle = s.String("confirm-style")
+	opts.ConfirmCountdown = s.Duration("confirm-countdown")
+	opts.Picker = s.pickerName()
+	opts.KubectlTimeout = s.Duration("kubectl-timeout")
+	opts.PodListTimeout = s.Duration("pod-list-timeout")
+	opts.Banner = s.Bool("banner")
+	opts.BannerColor = s.String("banner-color")
+	opts.TerminalTitle = s.Bool("terminal-title")
+	opts.Verbose = s.Int("verbose")
+	opts.TraceFile = s.String("trace-file")
+}
+
+// pickerName is the picker setting. The legacy ignore-fzf = true means
+// "none" unless picker was set with at least the same precedence.
+func (s *Settings) pickerName() string {
+	if s.Bool("ignore-fzf") && sourceRank(s.Source("ignore-fzf")) >= sourceRank(s.Source("picker")) {
+		return pickerNone
+	}
+	return s.String("picker")
+}
+
+// sourceRank orders sources by precedence: flags, environment, config files
+// (including context sections), then defaults.
+func sourceRank(source string) int {
+	switch {
+	case strings.HasPrefix(source, "flag "):
+		return 3
+	case strings.HasPrefix(source, "env "):
+		return 2
+	case source == sourceDefault:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// pickerNames returns the configured [pickers.<name>] names, sorted.
+func (s *Settings) pickerNames() []string {
+	names := make([]string, 0, len(s.pickers))
+	for name := range s.pickers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// snippetNames returns the configured snippet names, sorted.
+func (s *Settings) snippetNames() []string {
+	names := make([]string, 0, len(s.snippets))
+	for name := range s.snippets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,144 @@
+package cmdutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveSettingsPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+	writeConfigFile(t, filepath.Join(dir, ".config", "kubeexec", "kubeexec.toml"), `
+namespace = "from-config"
+wait = "30s"
+reconnect = 2
+picker-columns = ["name", "age"]
+`)
+	t.Setenv("KUBEEXEC_NAMESPACE", "from-env")
+	t.Setenv("KUBEEXEC_RECONNECT", "4")
+
+	settings, err := ResolveSettings(map[string]string{"namespace": "from-flag"})
+	if err != nil {
+		t.Fatalf("ResolveSettings() error: %v", err)
+	}
+	tests := []struct {
+		key    string
+		value  any
+		source string
+	}{
+		{key: "namespace", value: "from-flag", source: "flag --namespace"},
+		{key: "reconnect", value: 4, source: "env KUBEEXEC_RECONNECT"},
+		{key: "wait", value: 30 * time.Second, source: "user " + filepath.Join(dir, ".config", "kubeexec", "kubeexec.toml")},
+		{key: "pod-list-timeout", value: kubectlTimeoutPods, source: sourceDefault},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := settings.values[tt.key].value; got != tt.value {
+				t.Errorf("value = %v, want %v", got, tt.value)
+			}
+			if got := settings.Source(tt.key); got != tt.source {
+				t.Errorf("Source() = %q, want %q", got, tt.source)
+			}
+		})
+	}
+	if got := strings.Join(settings.Strings("picker-columns"), ","); got != "name,age" {
+		t.Errorf("picker-columns = %q, want name,age", got)
+	}
+}
+
+func TestResolveSettingsInvalidValues(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+	if _, err := ResolveSettings(map[string]string{"wait": "soon"}); !IsKind(err, KindUsage) {
+		t.Errorf("invalid flag value error = %v, want usage error", err)
+	}
+	t.Setenv("KUBEEXEC_ALL_NAMESPACES", "maybe")
+	if _, err := ResolveSettings(nil); !IsKind(err, KindUsage) {
+		t.Errorf("invalid env value error = %v, want usage error", err)
+	}
+	os.Unsetenv("KUBEEXEC_ALL_NAMESPACES")
+	path := filepath.Join(dir, ".config", "kubeexec", "kubeexec.toml")
+	for _, content := range []string{"wait = 30\n", "reconnect = -1\n", "kubectl-timeout = \"fast\"\n", "picker-columns = \"name\"\n", "namespace = true\n"} {
+		writeConfigFile(t, path, content)
+		if _, err := ResolveSettings(nil); err == nil {
+			t.Errorf("config %q: expected error", strings.TrimSpace(content))
+		}
+	}
+}
+
+func TestSettingEnvVars(t *testing.T) {
+	want := map[string]string{
+		"confirm-context": "KUBEEXEC_CONFIRM_CONTEXT",
+		"non-interactive": "KUBEEXEC_NON_INTERACTIVE",
+		"ignore-fzf":      "KUBEEXEC_IGNORE_FZF",
+		"picker":          "KUBEEXEC_PICKER",
+		"shell":           "KUBEEXEC_SHELL",
+		"field-selector":  "KUBEEXEC_FIELD_SELECTOR",
+	}
+	for key, env := range want {
+		def, ok := lookupSettingDef(key)
+		if !ok {
+			t.Fatalf("setting %q not defined", key)
+		}
+		if got := def.envVar(); got != env {
+			t.Errorf("envVar(%s) = %q, want %q", key, got, env)
+		}
+	}
+}
+
+func TestApplyRunOptions(t *testing.T) {
+	settingsWith := func(values map[string]settingValue) *Settings {
+		s := &Settings{values: make(map[string]settingValue)}
+		for _, def := range settingDefs {
+			s.values[def.key] = settingValue{value: def.def, source: sourceDefault}
+		}
+		for key, v := range values {
+			s.values[key] = v
+		}
+		return s
+	}
+	config := "project /repo/.kubeexec.toml"
+
+	s := settingsWith(map[string]settingValue{
+		"namespace": {value: "payments", source: config},
+		"selector":  {value: "app=api", source: config},
+		"container": {value: "api", source: config},
+	})
+	var opts RunOptions
+	s.ApplyRunOptions(&opts)
+	if opts.Namespace != "payments" || opts.Selector != "app=api" || opts.Container != "" || opts.PreferredContainer != "api" {
+		t.Errorf("config defaults: %+v", opts)
+	}
+
+	opts = RunOptions{Pod: "worker-1"}
+	s.ApplyRunOptions(&opts)
+	if opts.Selector != "" {
+		t.Errorf("configured selector applied with a named pod: %q", opts.Selector)
+	}
+
+	s = settingsWith(map[string]settingValue{
+		"namespace":      {value: "payments", source: config},
+		"all-namespaces": {value: true, source: "flag --all-namespaces"},
+		"container":      {value: "sidecar", source: "flag --container"},
+	})
+	opts = RunOptions{}
+	s.ApplyRunOptions(&opts)
+	if opts.Namespace != "" || !opts.AllNamespaces || opts.Container != "sidecar" || opts.PreferredContainer != "" {
+		t.Errorf("flags over config: %+v", opts)
+	}
+
+	s = settingsWith(map[string]settingValue{
+		"namespace":      {value: "kube-system", source: "flag --namespace"},
+		"all-namespaces": {value: true, source: config},
+	})
+	opts = RunOptions{}
+	s.ApplyRunOptions(&opts)
+	if opts.Namespace != "kube-system" || opts.AllNamespaces {
+		t.Errorf("-n over configured all-namespaces: %+v", opts)
+	}
+}
@@ -0,0 +1,95 @@
+package cmdutil
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// execWaitDelay is how long a child gets to exit after a forwarded signal
+// before it is killed.
+const execWaitDelay = 5 * time.Second
+
+// SignalError is the cause of a context cancelled by SignalContext.
+type SignalError struct {
+	Signal os.Signal
+}
+
+func (e *SignalError) Error() string {
+	return "cancelled by signal: " + e.Signal.String()
+}
+
+// exitCode is the shell convention for a process ended by the signal.
+func (e *SignalError) exitCode() int {
+	if sig, ok := e.Signal.(syscall.Signal); ok {
+		return 128 + int(sig)
+	}
+	return ExitCancelled
+}
+
+// SignalContext returns a context that is cancelled by the first SIGINT or
+// SIGTERM, with a *SignalError as its cause. A second signal is not caught,
+// so it ends the process. stop releases the signals.
+func SignalContext() (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			signal.Stop(signals)
+			cancel(&SignalError{Signal: sig})
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel(context.Canceled)
+	}
+}
+
+// commandContext is exec.CommandContext for children that own the terminal
+// or a session: when ctx is cancelled by a signal, the child gets the same
+// signal so it can restore the terminal and exit cleanly, and it is killed
+// when ctx is cancelled otherwise or it does not exit in time.
+func commandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Cancel = func() error {
+		var sigErr *SignalError
+		if errors.As(context.Cause(ctx), &sigErr) {
+			// Not every platform can deliver every signal.
+			if err := cmd.Process.Signal(sigErr.Signal); err == nil {
+				return nil
+			}
+		}
+		return cmd.Process.Kill()
+	}
+	cmd.WaitDelay = execWaitDelay
+	return cmd
+}
+
+// cancelled reports err as cancelled when ctx was cancelled, whichever step
+// noticed it first. The cause is kept so a signal sets the exit code.
+func cancelled(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil || IsKind(err, KindCancelled) {
+		return err
+	}
+	return &Error{Kind: KindCancelled, Err: context.Cause(ctx)}
+}
+
+// sleepContext waits for d, or returns a cancelled error when ctx is done
+// first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return cancelledErrorf("%w", context.Cause(ctx))
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,79 @@
+package cmdutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// writerFunc adapts a function to io.Writer.
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+func TestCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	kubectlErr := kubectlErrorf("kubectl get pods failed: %w", errors.New("signal: interrupt"))
+	if err := cancelled(ctx, kubectlErr); err != kubectlErr {
+		t.Errorf("cancelled() before cancel = %v, want the error unchanged", err)
+	}
+	cancel(&SignalError{Signal: syscall.SIGTERM})
+	err := cancelled(ctx, kubectlErr)
+	if !IsKind(err, KindCancelled) || ExitCode(err) != 143 {
+		t.Errorf("cancelled() after SIGTERM = %v (exit %d), want cancelled with exit 143", err, ExitCode(err))
+	}
+	if err := cancelled(ctx, nil); err != nil {
+		t.Errorf("cancelled(nil) = %v, want nil", err)
+	}
+	if err := sleepContext(ctx, time.Hour); !IsKind(err, KindCancelled) {
+		t.Errorf("sleepContext() after cancel = %v, want cancelled error", err)
+	}
+}
+
+func TestExecutorForwardsSignals(t *testing.T) {
+	cluster := fakeRunCluster()
+	cluster.ExecHang = true
+	newFakeCluster(t, cluster)
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	// Cancel once the fake kubectl is waiting for a signal.
+	var once sync.Once
+	stdout := writerFunc(func(p []byte) (int, error) {
+		once.Do(func() { cancel(&SignalError{Signal: syscall.SIGTERM}) })
+		return len(p), nil
+	})
+	var stderr bytes.Buffer
+	target := Target{Context: "dev", Namespace: "payments", Pod: "api-1", Container: "app"}
+
+	start := time.Now()
+	err := Executor{Stdout: stdout, Stderr: &stderr}.Exec(ctx, target, "sleep", "60")
+	if elapsed := time.Since(start); elapsed >= execWaitDelay {
+		t.Errorf("Exec() took %s, want kubectl to exit on the forwarded signal", elapsed)
+	}
+	if !IsKind(err, KindCancelled) || ExitCode(err) != 143 {
+		t.Errorf("Exec() error = %v (exit %d), want cancelled with exit 143", err, ExitCode(err))
+	}
+	if !strings.Contains(stderr.String(), "kubectl exec: terminated") {
+		t.Errorf("kubectl stderr = %q, want it to have received SIGTERM", stderr.String())
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	h := newFakeCluster(t, fakeRunCluster())
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(&SignalError{Signal: syscall.SIGINT})
+	err := Run(ctx, RunOptions{Pod: "api-1", Picker: "none"})
+	if !IsKind(err, KindCancelled) || ExitCode(err) != 130 {
+		t.Errorf("Run() with a cancelled context = %v (exit %d), want cancelled with exit 130", err, ExitCode(err))
+	}
+	if execs := h.execs(t); execs != nil {
+		t.Errorf("kubectl exec calls = %q, want none", execs)
+	}
+}
@@ -0,0 +1,168 @@
+package cmdutil
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// snippetConfig is a named command from the [snippets.<name>] config tables.
+// Containers and Images are glob patterns; a snippet applies to a container
+// when it matches at least one pattern of each non-empty list.
+type snippetConfig struct {
+	Command     string   `toml:"command"`
+	Description string   `toml:"description"`
+	Containers  []string `toml:"containers"`
+	Images      []string `toml:"images"`
+}
+
+// snippetPlaceholder matches {{name}} and {{name:default}}.
+var snippetPlaceholder = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*(?::([^}]*))?\}\}`)
+
+func snippetsFromSettings(settings *Settings) (map[string]snippetConfig, error) {
+	for name, snippet := range settings.snippets {
+		if strings.TrimSpace(snippet.Command) == "" {
+			return nil, usageErrorf("snippet %q has no command", name)
+		}
+		for _, pattern := range append(append([]string(nil), snippet.Containers...), snippet.Images...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, usageErrorf("snippet %q has invalid pattern %q", name, pattern)
+			}
+		}
+	}
+	return settings.snippets, nil
+}
+
+func snippetApplies(snippet snippetConfig, container ContainerItem) bool {
+	return matchesAnyGlob(snippet.Containers, container.Name, false) && matchesAnyGlob(snippet.Images, container.Image, true)
+}
+
+// matchesAnyGlob reports whether value matches one of patterns; an empty
+// pattern list matches everything. Since * does not match "/", an image
+// also matches by its last path segment, so "*jdk*" and "redis:*" apply to
+// ghcr.io/org/temurin:21-jdk and docker.io/library/redis:7.
+func matchesAnyGlob(patterns []string, value string, image bool) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+		if image {
+			if ok, _ := path.Match(pattern, path.Base(value)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func applicableSnippets(snippets map[string]snippetConfig, container ContainerItem) []string {
+	var names []string
+	for name, snippet := range snippets {
+		if snippetApplies(snippet, container) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func snippetPickerItems(snippets map[string]snippetConfig, names []string) []PickerItem {
+	width := 0
+	for _, name := range names {
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	items := make([]PickerItem, 0, len(names))
+	for _, name := range names {
+		snippet := snippets[name]
+		summary := snippet.Description
+		if summary == "" {
+			summary = snippet.Command
+		}
+		items = append(items, PickerItem{Key: name, Display: fmt.Sprintf("%-*s  %s", width, name, summary)})
+	}
+	return items
+}
+
+// ParseSnippetArgs parses repeated name=value snippet parameters.
+func ParseSnippetArgs(args []string) (map[string]string, error) {
+	values := make(map[string]string, len(args))
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, usageErrorf("invalid snippet argument %q (expected name=value)", arg)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// renderSnippet substitutes placeholders with shell-quoted values, using the
+// placeholder default when no value is given.
+func renderSnippet(name, command string, values map[string]string) (string, error) {
+	var missing []string
+	rendered := snippetPlaceholder.ReplaceAllStringFunc(command, func(match string) string {
+		parts := snippetPlaceholder.FindStringSubmatch(match)
+		key := parts[1]
+		value, ok := values[key]
+		if !ok {
+			if !strings.Contains(match, ":") {
+				missing = append(missing, key)
+				return match
+			}
+			value = strings.TrimSpace(parts[2])
+		}
+		return shellQuote(value)
+	})
+	if len(missing) > 0 {
+		return "", usageErrorf("snippet %q needs values for %s (use --arg name=value)", name, strings.Join(missing, ", "))
+	}
+	return rendered, nil
+}
+
+// resolveSnippet picks the snippet of settings to run in container: the
+// requested name, or a picker over applicable snippets when name is empty.
+func resolveSnippet(ctx context.Context, settings *Settings, name string, container ContainerItem, selector Selector) (string, snippetConfig, error) {
+	snippets, err := snippetsFromSettings(settings)
+	if err != nil {
+		return "", snippetConfig{}, err
+	}
+	if name != "" {
+		snippet, ok := snippets[name]
+		if !ok {
+			return "", snippetConfig{}, usageErrorf("snippet %q not found in config", name)
+		}
+		if !snippetApplies(snippet, container) {
+			return "", snippetConfig{}, resolutionErrorf("snippet %q does not apply to container %q (image %s)", name, container.Name, container.Image)
+		}
+		return name, snippet, nil
+	}
+	names := applicableSnippets(snippets, container)
+	if len(names) == 0 {
+		return "", snippetConfig{}, resolutionErrorf("no snippets apply to container %q (image %s)", container.Name, container.Image)
+	}
+	traceFrom(ctx).decision("snippet", "%d of %d snippets apply to container %q (image %s); choosing", len(names), len(snippets), container.Name, container.Image)
+	sel := Selection{Kind: "snippet", Header: "snippets for container: " + container.Name, Items: snippetPickerItems(snippets, names)}
+	choice, err := selectOne(ctx, selector, sel, "snippet not specified and the picker is disabled; use -s <name> or choose a --picker")
+	if err != nil {
+		return "", snippetConfig{}, err
+	}
+	return choice, snippets[choice], nil
+}
+
+func shellQuote(value string) string {
+	if value != "" && strings.IndexFunc(value, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=@%+,", r))
+	}) < 0 {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
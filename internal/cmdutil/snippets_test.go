@@ -0,0 +1,153 @@
+package cmdutil
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSnippetApplies(t *testing.T) {
+	container := ContainerItem{Name: "api", Image: "eclipse-temurin:21-jdk"}
+	tests := []struct {
+		name    string
+		image   string
+		snippet snippetConfig
+		want    bool
+	}{
+		{name: "no rules", snippet: snippetConfig{}, want: true},
+		{name: "container match", snippet: snippetConfig{Containers: []string{"web", "ap*"}}, want: true},
+		{name: "container mismatch", snippet: snippetConfig{Containers: []string{"redis"}}, want: false},
+		{name: "image match", snippet: snippetConfig{Images: []string{"*jdk*"}}, want: true},
+		{name: "image mismatch", snippet: snippetConfig{Images: []string{"redis:*"}}, want: false},
+		{name: "both must match", snippet: snippetConfig{Containers: []string{"api"}, Images: []string{"redis:*"}}, want: false},
+		{name: "registry-qualified image", image: "ghcr.io/org/temurin:21-jdk", snippet: snippetConfig{Images: []string{"*jdk*"}}, want: true},
+		{name: "docker hub image", image: "docker.io/library/redis:7", snippet: snippetConfig{Images: []string{"redis:*"}}, want: true},
+		{name: "registry pattern", image: "ghcr.io/org/temurin:21-jdk", snippet: snippetConfig{Images: []string{"ghcr.io/org/*"}}, want: true},
+		{name: "registry pattern mismatch", image: "docker.io/library/redis:7", snippet: snippetConfig{Images: []string{"ghcr.io/*"}}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			container := container
+			if tt.image != "" {
+				container.Image = tt.image
+			}
+			if got := snippetApplies(tt.snippet, container); got != tt.want {
+				t.Errorf("snippetApplies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplicableSnippets(t *testing.T) {
+	snippets := map[string]snippetConfig{
+		"thread-dump": {Command: "jcmd 1 Thread.print", Images: []string{"*jdk*"}},
+		"redis-info":  {Command: "redis-cli info", Images: []string{"redis:*"}},
+		"env":         {Command: "env"},
+	}
+	got := applicableSnippets(snippets, ContainerItem{Name: "api", Image: "eclipse-temurin:21-jdk"})
+	if strings.Join(got, ",") != "env,thread-dump" {
+		t.Errorf("applicableSnippets() = %v, want [env thread-dump]", got)
+	}
+	items := snippetPickerItems(snippets, got)
+	if items[0].Key != "env" || items[0].Display != "env          env" {
+		t.Errorf("snippetPickerItems()[0] = %+v", items[0])
+	}
+}
+
+func TestRenderSnippet(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		values  map[string]string
+		want    string
+		wantErr bool
+	}{
+		{name: "no placeholders", command: "redis-cli info", want: "redis-cli info"},
+		{name: "value", command: "curl localhost:{{port}}/health", values: map[string]string{"port": "9000"}, want: "curl localhost:9000/health"},
+		{name: "default", command: "curl localhost:{{ port:8080 }}/health", want: "curl localhost:8080/health"},
+		{name: "value overrides default", command: "curl localhost:{{port:8080}}", values: map[string]string{"port": "81"}, want: "curl localhost:81"},
+		{name: "quoted", command: "grep {{pattern}} /var/log/app.log", values: map[string]string{"pattern": "it's down"}, want: `grep 'it'"'"'s down' /var/log/app.log`},
+		{name: "empty value", command: "echo {{msg}}", values: map[string]string{"msg": ""}, want: "echo ''"},
+		{name: "missing", command: "kill -{{signal}} {{pid}}", values: map[string]string{"signal": "TERM"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderSnippet("test", tt.command, tt.values)
+			if tt.wantErr {
+				if !IsKind(err, KindUsage) {
+					t.Fatalf("renderSnippet() error = %v, want usage error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("renderSnippet() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("renderSnippet() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSnippetArgs(t *testing.T) {
+	values, err := ParseSnippetArgs([]string{"port=9000", "query=a=b", "empty="})
+	if err != nil {
+		t.Fatalf("ParseSnippetArgs() error: %v", err)
+	}
+	if values["port"] != "9000" || values["query"] != "a=b" || values["empty"] != "" {
+		t.Errorf("ParseSnippetArgs() = %v", values)
+	}
+	for _, arg := range []string{"port", "=9000"} {
+		if _, err := ParseSnippetArgs([]string{arg}); !IsKind(err, KindUsage) {
+			t.Errorf("ParseSnippetArgs(%q) error = %v, want usage error", arg, err)
+		}
+	}
+}
+
+func TestResolveSnippetFromConfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	path := filepath.Join(dir, ".config", "kubeexec", "kubeexec.toml")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	content := `
+[snippets.thread-dump]
+command = "jcmd 1 Thread.print"
+images = ["*jdk*"]
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	settings, err := loadSettings()
+	if err != nil {
+		t.Fatalf("loadSettings() error: %v", err)
+	}
+	jvm := ContainerItem{Name: "api", Image: "eclipse-temurin:21-jdk"}
+	name, snippet, err := resolveSnippet(context.Background(), settings, "thread-dump", jvm, picker{name: pickerNone})
+	if err != nil || name != "thread-dump" || snippet.Command != "jcmd 1 Thread.print" {
+		t.Fatalf("resolveSnippet(thread-dump) = %q, %+v, %v", name, snippet, err)
+	}
+	if _, _, err := resolveSnippet(context.Background(), settings, "missing", jvm, picker{name: pickerNone}); !IsKind(err, KindUsage) {
+		t.Errorf("resolveSnippet(missing) error = %v, want usage error", err)
+	}
+	redis := ContainerItem{Name: "cache", Image: "redis:7"}
+	if _, _, err := resolveSnippet(context.Background(), settings, "thread-dump", redis, picker{name: pickerNone}); !IsKind(err, KindResolution) {
+		t.Errorf("resolveSnippet(thread-dump, redis) error = %v, want resolution error", err)
+	}
+	if _, _, err := resolveSnippet(context.Background(), settings, "", jvm, picker{name: pickerNone}); !IsKind(err, KindAmbiguous) {
+		t.Errorf("resolveSnippet(\"\", picker disabled) error = %v, want ambiguous error", err)
+	}
+
+	if err := os.WriteFile(path, []byte("[snippets.empty]\ndescription = \"nothing\"\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if settings, err = loadSettings(); err != nil {
+		t.Fatalf("loadSettings() error: %v", err)
+	}
+	if _, _, err := resolveSnippet(context.Background(), settings, "empty", jvm, picker{name: pickerNone}); !IsKind(err, KindUsage) {
+		t.Errorf("resolveSnippet(empty) error = %v, want usage error", err)
+	}
+}
@@ -0,0 +1,194 @@
+package cmdutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// traceExcerptLimit caps the kubectl stderr kept per call.
+	traceExcerptLimit = 512
+	// traceKeyLimit caps the pods listed in one decision.
+	traceKeyLimit = 10
+)
+
+// tracer reports kubectl calls, resolution decisions and setting sources for
+// -V/--verbose on stderr and for --trace-file as JSON lines. Verbosity 1
+// shows calls and decisions; 2 adds kubectl stderr and where each setting
+// came from. The trace file always gets everything. A nil tracer does
+// nothing, so callers need not check whether tracing is on.
+type tracer struct {
+	level int
+	out   io.Writer
+	file  *os.File
+	mu    sync.Mutex
+}
+
+// traceEvent is one line of the trace file.
+type traceEvent struct {
+	Time       time.Time `json:"time"`
+	Event      string    `json:"event"`
+	Step       string    `json:"step,omitempty"`
+	Message    string    `json:"message,omitempty"`
+	Args       []string  `json:"args,omitempty"`
+	DurationMS float64   `json:"duration_ms,omitempty"`
+	Exit       *int      `json:"exit,omitempty"`
+	Stderr     string    `json:"stderr,omitempty"`
+	Key        string    `json:"key,omitempty"`
+	Value      string    `json:"value,omitempty"`
+	Source     string    `json:"source,omitempty"`
+}
+
+type tracerKey struct{}
+
+// newTracer returns the tracer for a verbosity and trace file, or nil when
+// both are off. The trace file is truncated.
+func newTracer(level int, path string, out io.Writer) (*tracer, error) {
+	if level <= 0 && path == "" {
+		return nil, nil
+	}
+	t := &tracer{level: level, out: out}
+	if path != "" {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+		if err != nil {
+			return nil, usageErrorf("cannot write trace file: %w", err)
+		}
+		t.file = file
+	}
+	return t, nil
+}
+
+// withTracer returns ctx carrying t for the kubectl calls and decisions made
+// under it.
+func withTracer(ctx context.Context, t *tracer) context.Context {
+	if t == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, tracerKey{}, t)
+}
+
+// traceFrom returns the tracer of ctx, or nil.
+func traceFrom(ctx context.Context) *tracer {
+	t, _ := ctx.Value(tracerKey{}).(*tracer)
+	return t
+}
+
+func (t *tracer) close() error {
+	if t == nil || t.file == nil {
+		return nil
+	}
+	return t.file.Close()
+}
+
+// kubectl records one kubectl run: its arguments, how long it took, its exit
+// status (-1 when it did not exit on its own) and an excerpt of its stderr.
+func (t *tracer) kubectl(args []string, duration time.Duration, err error, stderr string) {
+	if t == nil {
+		return
+	}
+	exit := traceExitCode(err)
+	excerpt := traceExcerpt(stderr)
+	t.emit(traceEvent{Event: "kubectl", Args: args, DurationMS: float64(duration.Microseconds()) / 1000, Exit: &exit, Stderr: excerpt},
+		1, fmt.Sprintf("kubectl %s (%s, exit %d)", strings.Join(args, " "), duration.Round(time.Millisecond), exit))
+	if excerpt != "" {
+		t.print(2, "  stderr: "+strings.ReplaceAll(excerpt, "\n", "\n  stderr: "))
+	}
+}
+
+// decision records why a resolution step chose what it did.
+func (t *tracer) decision(step, format string, args ...any) {
+	if t == nil {
+		return
+	}
+	message := fmt.Sprintf(format, args...)
+	t.emit(traceEvent{Event: "decision", Step: step, Message: message}, 1, step+": "+message)
+}
+
+// settings records every setting that is not at its default, with the flag,
+// variable or config layer it came from.
+func (t *tracer) settings(s *Settings) {
+	if t == nil || s == nil {
+		return
+	}
+	for _, def := range settingDefs {
+		if !s.IsSet(def.key) {
+			continue
+		}
+		value := formatSettingValue(s.values[def.key].value)
+		source := s.Source(def.key)
+		t.emit(traceEvent{Event: "setting", Key: def.key, Value: value, Source: source},
+			2, fmt.Sprintf("setting %s = %s (%s)", def.key, value, source))
+	}
+}
+
+// emit writes event to the trace file and line to stderr from level on.
+func (t *tracer) emit(event traceEvent, level int, line string) {
+	event.Time = time.Now()
+	if t.file != nil {
+		if data, err := json.Marshal(event); err == nil {
+			t.mu.Lock()
+			t.file.Write(append(data, '\n'))
+			t.mu.Unlock()
+		}
+	}
+	t.print(level, line)
+}
+
+// print writes line to stderr from level on.
+func (t *tracer) print(level int, line string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.level >= level {
+		fmt.Fprintln(t.out, "debug: "+line)
+	}
+}
+
+func traceExitCode(err error) int {
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitErr):
+		return exitErr.ExitCode()
+	default:
+		return -1
+	}
+}
+
+func traceExcerpt(stderr string) string {
+	stderr = strings.TrimSpace(stderr)
+	if len(stderr) > traceExcerptLimit {
+		stderr = stderr[:traceExcerptLimit] + "..."
+	}
+	return stderr
+}
+
+// traceKeys lists up to limit pod keys for a decision message.
+func traceKeys(pods []PodItem, limit int) string {
+	keys := make([]string, 0, min(len(pods), limit))
+	for i, pod := range pods {
+		if i == limit {
+			keys = append(keys, fmt.Sprintf("and %d more", len(pods)-limit))
+			break
+		}
+		keys = append(keys, pod.Key())
+	}
+	return strings.Join(keys, ", ")
+}
+
+// tracePodScope describes where pods were listed and with which filters.
+func tracePodScope(namespace string, query PodQuery, allNamespaces bool) string {
+	scope := "namespace " + namespace
+	if allNamespaces {
+		scope = "all namespaces"
+	}
+	return strings.Join(append([]string{scope}, query.describe()...), ", ")
+}
@@ -0,0 +1,154 @@
+package cmdutil
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunVerbose(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    RunOptions
+		picks   []string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:  "calls and decisions",
+			opts:  RunOptions{Pod: "multi", Verbose: 1},
+			picks: []string{"payments/multi-1"},
+			want: []string{
+				"debug: kubectl config current-context (",
+				"debug: context: \"dev\" is kubectl's current context\n",
+				"debug: namespace: \"payments\" from context \"dev\"\n",
+				"debug: pods: listed 5 pods (namespace payments)\n",
+				"debug: pod: \"multi\" is part of the name of 2 pods (payments/multi-1, payments/multi-2); choosing\n",
+				"debug: pod: \"payments/multi-1\" chosen from 2 items\n",
+				"debug: container: \"proxy\" is the preferred container\n",
+				"debug: kubectl --context dev exec -i -n payments multi-1 -c proxy -- sh -c ",
+			},
+			notWant: []string{"secret", "debug: setting "},
+		},
+		{
+			name: "setting sources",
+			opts: RunOptions{Pod: "api-1", Verbose: 2},
+			want: []string{
+				"debug: setting container = \"proxy\" (project ",
+				"debug: pod: \"api-1\" matches payments/api-1 exactly\n",
+				"debug: container: \"app\" is the only regular container\n",
+				"debug: container: preferred container \"proxy\" is not in pod \"api-1\"\n",
+			},
+		},
+		{
+			name: "kubectl stderr",
+			opts: RunOptions{Context: "nope", Pod: "api-1", Verbose: 2},
+			want: []string{
+				"debug: kubectl --context nope get pods -o json -n default (",
+				", exit 1)\ndebug:   stderr: error: context \"nope\" does not exist\n",
+			},
+		},
+		{
+			name:    "kubectl stderr needs -VV",
+			opts:    RunOptions{Context: "nope", Pod: "api-1", Verbose: 1},
+			want:    []string{"debug: kubectl --context nope get pods -o json -n default ("},
+			notWant: []string{"stderr:"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newFakeCluster(t, fakeRunCluster(), tt.picks...)
+			h.writeConfig(t, "container = \"proxy\"\n")
+			settings, err := ResolveSettings(map[string]string{"picker": h.picker()})
+			if err != nil {
+				t.Fatalf("ResolveSettings() error: %v", err)
+			}
+			opts := tt.opts
+			verbose, kubeContext := opts.Verbose, opts.Context
+			settings.ApplyRunOptions(&opts)
+			opts.Verbose, opts.Context = verbose, kubeContext
+			opts.Command = []string{"env"}
+			opts.Env = []string{"TOKEN=secret"}
+			var stderr bytes.Buffer
+			opts.Stdout, opts.Stderr = io.Discard, &stderr
+
+			Run(context.Background(), opts)
+			got := stderr.String()
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("stderr = %q, want it to contain %q", got, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("stderr = %q, want no %q", got, notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestRunTraceFile(t *testing.T) {
+	h := newFakeCluster(t, fakeRunCluster())
+	h.writeConfig(t, "container = \"proxy\"\n")
+	settings, err := ResolveSettings(map[string]string{"picker": "none"})
+	if err != nil {
+		t.Fatalf("ResolveSettings() error: %v", err)
+	}
+	opts := RunOptions{Pod: "multi-1", Command: []string{"env"}, Env: []string{"TOKEN=secret"}}
+	settings.ApplyRunOptions(&opts)
+	opts.TraceFile = filepath.Join(t.TempDir(), "trace.jsonl")
+	var stderr bytes.Buffer
+	opts.Stdout, opts.Stderr = io.Discard, &stderr
+	if err := Run(context.Background(), opts); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if strings.Contains(stderr.String(), "debug:") {
+		t.Errorf("stderr = %q, want no debug lines without -V", stderr.String())
+	}
+
+	data, err := os.ReadFile(opts.TraceFile)
+	if err != nil {
+		t.Fatalf("read trace: %v", err)
+	}
+	if bytes.Contains(data, []byte("secret")) {
+		t.Errorf("trace = %s, want env values redacted", data)
+	}
+	events := make(map[string][]traceEvent)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var event traceEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("decode trace line %q: %v", scanner.Text(), err)
+		}
+		events[event.Event] = append(events[event.Event], event)
+	}
+
+	kubectl := events["kubectl"]
+	if len(kubectl) == 0 {
+		t.Fatalf("trace has no kubectl events: %s", data)
+	}
+	exec := kubectl[len(kubectl)-1]
+	if exec.Exit == nil || *exec.Exit != 0 || !strings.Contains(strings.Join(exec.Args, " "), "exec -i -n payments multi-1 -c proxy") {
+		t.Errorf("last kubectl event = %+v, want the exec with exit 0", exec)
+	}
+	if got := events["decision"]; len(got) == 0 || got[len(got)-1].Step != "container" {
+		t.Errorf("decision events = %+v, want the container decision last", got)
+	}
+	wantSource := "project " + filepath.Join(h.home, projectConfigFilename)
+	found := false
+	for _, event := range events["setting"] {
+		if event.Key == "container" && event.Value == `"proxy"` && event.Source == wantSource {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("setting events = %+v, want container = \"proxy\" from %s", events["setting"], wantSource)
+	}
+}
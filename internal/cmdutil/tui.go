@@ -0,0 +1,833 @@
+package cmdutil
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// tuiRefreshInterval is how often the pod and container lists are
+	// reloaded while the TUI is open.
+	tuiRefreshInterval = 3 * time.Second
+	tuiLogTail         = "200"
+)
+
+type tuiLevel int
+
+const (
+	tuiContexts tuiLevel = iota
+	tuiNamespaces
+	tuiPods
+	tuiContainers
+)
+
+func (l tuiLevel) String() string {
+	switch l {
+	case tuiContexts:
+		return "contexts"
+	case tuiNamespaces:
+		return "namespaces"
+	case tuiPods:
+		return "pods"
+	default:
+		return "containers"
+	}
+}
+
+// tuiItem is one row of the current list. key identifies it across reloads;
+// name is what "copy" puts on the clipboard.
+type tuiItem struct {
+	key       string
+	name      string
+	display   string
+	pod       PodItem
+	container ContainerItem
+}
+
+// tuiView is the TUI state. It changes only in response to keys and load
+// results, so it can be driven without a terminal.
+type tuiView struct {
+	level         tuiLevel
+	context       string
+	namespace     string
+	allNamespaces bool
+	pod           PodItem
+	items         []tuiItem
+	cursor        int
+	filter        string
+	filtering     bool
+	status        string
+	loading       bool
+	// selectKey is the item the cursor moves to after the next load.
+	selectKey string
+	// loaded is set once the current list has been loaded; reloads keep
+	// the cursor instead of moving it to the load's own selection.
+	loaded bool
+	// generation changes with the level or scope, so results of loads
+	// started before the change are dropped.
+	generation int
+}
+
+type tuiActionKind int
+
+const (
+	tuiNone tuiActionKind = iota
+	tuiLoad
+	tuiExec
+	tuiLogs
+	tuiDescribe
+	tuiCopy
+	tuiQuit
+)
+
+type tuiAction struct {
+	kind tuiActionKind
+	item tuiItem
+}
+
+const tuiHelp = "enter open  ← back  x exec  L logs  d describe  y copy  c contexts  n namespaces  A all namespaces  / filter  r refresh  q quit"
+
+// visible returns the items matching the filter.
+func (v *tuiView) visible() []tuiItem {
+	if v.filter == "" {
+		return v.items
+	}
+	filter := strings.ToLower(v.filter)
+	var matches []tuiItem
+	for _, item := range v.items {
+		if strings.Contains(strings.ToLower(item.key), filter) || strings.Contains(strings.ToLower(stripANSI(item.display)), filter) {
+			matches = append(matches, item)
+		}
+	}
+	return matches
+}
+
+func (v *tuiView) selected() (tuiItem, bool) {
+	items := v.visible()
+	if v.cursor < 0 || v.cursor >= len(items) {
+		return tuiItem{}, false
+	}
+	return items[v.cursor], true
+}
+
+func (v *tuiView) moveCursor(delta int) {
+	v.cursor = max(0, min(v.cursor+delta, len(v.visible())-1))
+}
+
+// setLevel switches lists; the caller loads the new one.
+func (v *tuiView) setLevel(level tuiLevel, selectKey string) tuiAction {
+	v.level = level
+	v.items = nil
+	v.cursor = 0
+	v.filter = ""
+	v.filtering = false
+	v.selectKey = selectKey
+	v.loaded = false
+	v.generation++
+	return tuiAction{kind: tuiLoad}
+}
+
+// setItems replaces the list after a load, keeping the cursor on the same
+// item when it is still there.
+func (v *tuiView) setItems(items []tuiItem) {
+	key := v.selectKey
+	if key == "" {
+		if item, ok := v.selected(); ok {
+			key = item.key
+		}
+	}
+	v.items = items
+	v.selectKey = ""
+	v.loaded = true
+	v.loading = false
+	v.cursor = 0
+	for i, item := range v.visible() {
+		if item.key == key {
+			v.cursor = i
+			break
+		}
+	}
+}
+
+// applyResult takes a finished load and reports whether it was for the
+// current list. The load's own selection, such as the pod's default
+// container, only applies to the first load of a list.
+func (v *tuiView) applyResult(result tuiResult) bool {
+	if result.generation != v.generation {
+		return false
+	}
+	if result.err != nil {
+		v.loading = false
+		v.status = "\x1b[31merror: " + result.err.Error() + "\x1b[0m"
+		return true
+	}
+	if !v.loaded && v.selectKey == "" {
+		v.selectKey = result.selectKey
+	}
+	v.setItems(result.items)
+	return true
+}
+
+func (v *tuiView) handleKey(key string) tuiAction {
+	if v.filtering {
+		switch key {
+		case "ctrl-c":
+			return tuiAction{kind: tuiQuit}
+		case "enter":
+			v.filtering = false
+		case "esc":
+			v.filter = ""
+			v.filtering = false
+			v.cursor = 0
+		case "backspace":
+			if v.filter != "" {
+				_, size := utf8.DecodeLastRuneInString(v.filter)
+				v.filter = v.filter[:len(v.filter)-size]
+				v.cursor = 0
+			}
+		case "up":
+			v.moveCursor(-1)
+		case "down":
+			v.moveCursor(1)
+		default:
+			if utf8.RuneCountInString(key) == 1 {
+				v.filter += key
+				v.cursor = 0
+			}
+		}
+		return tuiAction{}
+	}
+
+	item, hasItem := v.selected()
+	switch key {
+	case "q", "ctrl-c":
+		return tuiAction{kind: tuiQuit}
+	case "up", "k":
+		v.moveCursor(-1)
+	case "down", "j":
+		v.moveCursor(1)
+	case "pgup":
+		v.moveCursor(-10)
+	case "pgdown":
+		v.moveCursor(10)
+	case "home":
+		v.cursor = 0
+	case "end":
+		v.moveCursor(len(v.items))
+	case "/":
+		v.filtering = true
+	case "r":
+		return tuiAction{kind: tuiLoad}
+	case "esc":
+		if v.filter != "" {
+			v.filter = ""
+			v.cursor = 0
+			return tuiAction{}
+		}
+		return v.back()
+	case "left", "h", "backspace":
+		return v.back()
+	case "enter", "right", "l":
+		if hasItem {
+			return v.open(item)
+		}
+	case "c":
+		return v.setLevel(tuiContexts, v.context)
+	case "n":
+		if v.context != "" {
+			return v.setLevel(tuiNamespaces, v.namespace)
+		}
+	case "A":
+		if v.level == tuiPods {
+			v.allNamespaces = !v.allNamespaces
+			selectKey := ""
+			if hasItem {
+				selectKey = item.key
+			}
+			return v.setLevel(tuiPods, selectKey)
+		}
+	case "x":
+		if hasItem && (v.level == tuiPods || v.level == tuiContainers) {
+			return tuiAction{kind: tuiExec, item: item}
+		}
+	case "L":
+		if hasItem && (v.level == tuiPods || v.level == tuiContainers) {
+			return tuiAction{kind: tuiLogs, item: item}
+		}
+	case "d":
+		if hasItem && v.level != tuiContexts {
+			return tuiAction{kind: tuiDescribe, item: item}
+		}
+	case "y":
+		if hasItem {
+			return tuiAction{kind: tuiCopy, item: item}
+		}
+	}
+	return tuiAction{}
+}
+
+func (v *tuiView) open(item tuiItem) tuiAction {
+	switch v.level {
+	case tuiContexts:
+		v.context = item.key
+		v.namespace = ""
+		return v.setLevel(tuiNamespaces, "")
+	case tuiNamespaces:
+		v.namespace = item.key
+		v.allNamespaces = false
+		return v.setLevel(tuiPods, "")
+	case tuiPods:
+		v.pod = item.pod
+		return v.setLevel(tuiContainers, "")
+	default:
+		return tuiAction{kind: tuiExec, item: item}
+	}
+}
+
+func (v *tuiView) back() tuiAction {
+	switch v.level {
+	case tuiContainers:
+		return v.setLevel(tuiPods, v.pod.Key())
+	case tuiPods:
+		return v.setLevel(tuiNamespaces, v.namespace)
+	case tuiNamespaces:
+		return v.setLevel(tuiContexts, v.context)
+	}
+	return tuiAction{}
+}
+
+// render draws the view for a terminal of rows x cols. Lines end with \r\n
+// because the terminal is in raw mode.
+func (v *tuiView) render(rows, cols int) string {
+	var lines []string
+	namespace := v.namespace
+	if v.allNamespaces {
+		namespace = "(all)"
+	}
+	title := fmt.Sprintf(" kubeexec  context: %s  namespace: %s", orDash(v.context), orDash(namespace))
+	if v.level == tuiContainers {
+		title += "  pod: " + v.pod.Name
+	}
+	lines = append(lines, "\x1b[7m"+padVisible(truncateVisible(title, cols), cols)+"\x1b[0m")
+
+	items := v.visible()
+	heading := fmt.Sprintf(" %s (%d)", v.level, len(items))
+	if v.loading && len(v.items) == 0 {
+		heading += "  loading..."
+	}
+	if v.filtering || v.filter != "" {
+		heading += "  filter: /" + v.filter
+		if v.filtering {
+			heading += "_"
+		}
+	}
+	lines = append(lines, truncateVisible(heading, cols))
+
+	listRows := max(rows-4, 1)
+	start := 0
+	if v.cursor >= listRows {
+		start = v.cursor - listRows + 1
+	}
+	for i := start; i < len(items) && i < start+listRows; i++ {
+		if i == v.cursor {
+			lines = append(lines, "\x1b[7m"+padVisible(truncateVisible("> "+stripANSI(items[i].display), cols), cols)+"\x1b[0m")
+		} else {
+			lines = append(lines, truncateVisible("  "+items[i].display, cols))
+		}
+	}
+	for len(lines) < rows-2 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, truncateVisible(v.status, cols))
+	lines = append(lines, "\x1b[2m"+truncateVisible(tuiHelp, cols)+"\x1b[0m")
+	return "\x1b[H\x1b[2J" + strings.Join(lines, "\x1b[K\r\n")
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// truncateVisible cuts s to width visible characters, skipping escape
+// sequences, and resets attributes if it cut inside a colored span.
+func truncateVisible(s string, width int) string {
+	var b strings.Builder
+	visible := 0
+	for i := 0; i < len(s); {
+		if loc := ansiEscape.FindStringIndex(s[i:]); loc != nil && loc[0] == 0 {
+			b.WriteString(s[i : i+loc[1]])
+			i += loc[1]
+			continue
+		}
+		if visible == width {
+			b.WriteString("\x1b[0m")
+			break
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		b.WriteRune(r)
+		visible++
+		i += size
+	}
+	return b.String()
+}
+
+func padVisible(s string, width int) string {
+	if n := utf8.RuneCountInString(stripANSI(s)); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+// decodeKeys splits terminal input into key names: "up", "down", "left",
+// "right", "pgup", "pgdown", "home", "end", "enter", "esc", "backspace",
+// "ctrl-c", "tab", or the typed character.
+func decodeKeys(input string) []string {
+	sequences := []struct{ seq, key string }{
+		{"\x1b[A", "up"}, {"\x1b[B", "down"}, {"\x1b[C", "right"}, {"\x1b[D", "left"},
+		{"\x1bOA", "up"}, {"\x1bOB", "down"}, {"\x1bOC", "right"}, {"\x1bOD", "left"},
+		{"\x1b[5~", "pgup"}, {"\x1b[6~", "pgdown"},
+		{"\x1b[H", "home"}, {"\x1b[F", "end"}, {"\x1b[1~", "home"}, {"\x1b[4~", "end"},
+	}
+	var keys []string
+	for input != "" {
+		matched := false
+		for _, s := range sequences {
+			if strings.HasPrefix(input, s.seq) {
+				keys = append(keys, s.key)
+				input = input[len(s.seq):]
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(input)
+		input = input[size:]
+		switch r {
+		case '\r', '\n':
+			keys = append(keys, "enter")
+		case 0x1b:
+			keys = append(keys, "esc")
+		case 0x7f, 0x08:
+			keys = append(keys, "backspace")
+		case 0x03:
+			keys = append(keys, "ctrl-c")
+		case '\t':
+			keys = append(keys, "tab")
+		default:
+			if r >= 0x20 {
+				keys = append(keys, string(r))
+			}
+		}
+	}
+	return keys
+}
+
+// osc52 asks the terminal to put value on the clipboard. It works over SSH
+// and needs no clipboard tool.
+func osc52(value string) string {
+	return "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(value)) + "\a"
+}
+
+// tuiSession runs the TUI against a terminal and the cluster.
+type tuiSession struct {
+	// ctx is never cancelled: the TUI reads ctrl-c as a key and stops logs
+	// itself, so it does not use the signal handling of Run.
+	ctx      context.Context
+	term     *tuiTerminal
+	view     tuiView
+	settings *Settings
+	env      []EnvVar
+	reconn   int
+	results  chan tuiResult
+}
+
+type tuiResult struct {
+	generation int
+	items      []tuiItem
+	selectKey  string
+	err        error
+}
+
+// RunTUI opens the full-screen dashboard: contexts, namespaces, pods and
+// containers, with the pod and container lists refreshed while open.
+func RunTUI(opts RunOptions) error {
+	if _, err := exec.LookPath("kubectl"); err != nil {
+		return kubectlErrorf("kubectl not found")
+	}
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return usageErrorf("tui needs a terminal")
+	}
+	settings := opts.Settings
+	if settings == nil {
+		var err error
+		if settings, err = ResolveSettings(nil); err != nil {
+			return err
+		}
+	}
+	env, err := ResolveEnv(opts.Env, opts.EnvFiles, opts.ForwardEnv)
+	if err != nil {
+		return err
+	}
+	ctx := withKubectlTimeouts(context.Background(), opts.KubectlTimeout, opts.PodListTimeout)
+	view, err := initialTUIView(ctx, opts)
+	if err != nil {
+		return err
+	}
+	term, err := openTUITerminal()
+	if err != nil {
+		return err
+	}
+	defer term.close()
+
+	s := &tuiSession{ctx: ctx, term: term, view: view, settings: settings, env: env, reconn: opts.Reconnect, results: make(chan tuiResult, 8)}
+	return s.loop()
+}
+
+// initialTUIView starts at the pods of the current (or given) context and
+// namespace, or at the context list when there is no context to use.
+func initialTUIView(ctx context.Context, opts RunOptions) (tuiView, error) {
+	view := tuiView{level: tuiPods, allNamespaces: opts.AllNamespaces, namespace: opts.Namespace}
+	context := opts.Context
+	if opts.ContextRequested && context != "" {
+		contexts, err := GetContexts(ctx)
+		if err != nil {
+			return view, err
+		}
+		if !contains(contexts, context) {
+			if matches := filterByQuery(contexts, context); len(matches) == 1 {
+				context = matches[0]
+			} else {
+				view.level = tuiContexts
+				view.filter = context
+				return view, nil
+			}
+		}
+	} else if !opts.ContextRequested {
+		current, err := CurrentContext(ctx)
+		if err != nil {
+			return view, err
+		}
+		context = current
+	}
+	if context == "" {
+		view.level = tuiContexts
+		return view, nil
+	}
+	view.context = context
+	if view.namespace == "" && !view.allNamespaces {
+		namespace, err := CurrentNamespace(ctx, context)
+		if err != nil {
+			return view, err
+		}
+		view.namespace = namespace
+		if view.namespace == "" {
+			view.namespace = "default"
+		}
+	}
+	return view, nil
+}
+
+func (s *tuiSession) loop() error {
+	s.load()
+	refresh := time.NewTicker(tuiRefreshInterval)
+	defer refresh.Stop()
+	rows, cols := s.term.size()
+	sizeChecked := time.Now()
+	dirty := true
+	for {
+		if time.Since(sizeChecked) > time.Second {
+			if r, c := s.term.size(); r != rows || c != cols {
+				rows, cols, dirty = r, c, true
+			}
+			sizeChecked = time.Now()
+		}
+		if dirty {
+			s.term.write(s.view.render(rows, cols))
+			dirty = false
+		}
+		keys, err := s.term.readKeys()
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			dirty = true
+			s.view.status = ""
+			quit, err := s.do(s.view.handleKey(key))
+			if err != nil || quit {
+				return err
+			}
+		}
+		select {
+		case result := <-s.results:
+			if s.view.applyResult(result) {
+				dirty = true
+			}
+		case <-refresh.C:
+			if (s.view.level == tuiPods || s.view.level == tuiContainers) && !s.view.loading {
+				s.load()
+			}
+		default:
+		}
+	}
+}
+
+// do runs an action. Actions that hand the terminal to kubectl suspend the
+// TUI and resume it when kubectl exits.
+func (s *tuiSession) do(action tuiAction) (bool, error) {
+	switch action.kind {
+	case tuiQuit:
+		return true, nil
+	case tuiLoad:
+		s.load()
+	case tuiCopy:
+		s.term.write(osc52(action.item.name))
+		s.view.status = "copied " + action.item.name
+	case tuiExec:
+		target, ok, err := s.execTarget(action.item)
+		if err != nil {
+			s.view.status = "\x1b[31merror: " + err.Error() + "\x1b[0m"
+			return false, nil
+		}
+		if !ok {
+			s.view.status = "pod has several containers; choose one"
+			return false, nil
+		}
+		err = s.suspended(func() error {
+			fmt.Fprintf(os.Stderr, "exec into %s (exit the shell to return)\n", target.describe())
+			return execOrPrint(s.ctx, target, s.execOptions(target.context))
+		})
+		s.reportSuspended("session", err)
+		s.load()
+	case tuiLogs:
+		target := s.itemTarget(action.item)
+		args := kubectlArgs(target.context, "logs", "-f", "--tail", tuiLogTail, "-n", target.namespace, target.pod)
+		if target.container != "" {
+			args = append(args, "-c", target.container)
+		}
+		err := s.suspended(func() error {
+			fmt.Fprintf(os.Stderr, "logs of %s (ctrl-c to return)\n", target.describe())
+			return s.interruptible(exec.Command("kubectl", args...))
+		})
+		s.reportSuspended("logs", err)
+	case tuiDescribe:
+		out, err := runKubectl(s.ctx, kubectlTimeoutsFrom(s.ctx).quick, s.describeArgs(action.item)...)
+		if err != nil {
+			s.view.status = "\x1b[31merror: " + err.Error() + "\x1b[0m"
+			return false, nil
+		}
+		s.reportSuspended("describe", s.suspended(func() error { return page(out) }))
+	}
+	return false, nil
+}
+
+func (s *tuiSession) reportSuspended(what string, err error) {
+	var remote *RemoteExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &remote):
+		s.view.status = fmt.Sprintf("%s ended: %v", what, err)
+	default:
+		s.view.status = "\x1b[31merror: " + err.Error() + "\x1b[0m"
+	}
+}
+
+// load reloads the current list in the background.
+func (s *tuiSession) load() {
+	s.view.loading = true
+	view := s.view
+	go func() {
+		items, selectKey, err := s.loadItems(view)
+		s.results <- tuiResult{generation: view.generation, items: items, selectKey: selectKey, err: err}
+	}()
+}
+
+func (s *tuiSession) loadItems(view tuiView) ([]tuiItem, string, error) {
+	switch view.level {
+	case tuiContexts:
+		contexts, err := GetContexts(s.ctx)
+		if err != nil {
+			return nil, "", err
+		}
+		current, _ := CurrentContext(s.ctx)
+		items := make([]tuiItem, 0, len(contexts))
+		for _, context := range contexts {
+			items = append(items, tuiItem{key: context, name: context, display: markCurrent(context, current)})
+		}
+		return items, "", nil
+	case tuiNamespaces:
+		namespaces, err := GetNamespaces(s.ctx, view.context)
+		if err != nil {
+			return nil, "", err
+		}
+		current, _ := CurrentNamespace(s.ctx, view.context)
+		if current == "" {
+			current = "default"
+		}
+		items := make([]tuiItem, 0, len(namespaces))
+		for _, namespace := range namespaces {
+			items = append(items, tuiItem{key: namespace, name: namespace, display: markCurrent(namespace, current)})
+		}
+		return items, current, nil
+	case tuiPods:
+		namespace := view.namespace
+		if view.allNamespaces {
+			namespace = ""
+		}
+		pods, err := GetPods(s.ctx, view.context, namespace, PodQuery{}, view.allNamespaces)
+		if err != nil {
+			return nil, "", err
+		}
+		layout, err := podLayoutFromSettings(s.settings.ForContext(view.context), view.allNamespaces)
+		if err != nil {
+			return nil, "", err
+		}
+		formatPodDisplays(pods, layout)
+		items := make([]tuiItem, 0, len(pods))
+		for _, pod := range pods {
+			items = append(items, tuiItem{key: pod.Key(), name: pod.Name, display: pod.Display, pod: pod})
+		}
+		return items, "", nil
+	default:
+		containers, defaultContainer, err := GetPodContainers(s.ctx, view.context, view.pod.Namespace, view.pod.Name)
+		if err != nil {
+			return nil, "", err
+		}
+		formatContainerDisplays(containers)
+		items := make([]tuiItem, 0, len(containers))
+		for _, container := range containers {
+			items = append(items, tuiItem{key: container.Name, name: container.Name, display: container.Display, pod: view.pod, container: container})
+		}
+		return items, defaultContainer, nil
+	}
+}
+
+func markCurrent(name, current string) string {
+	if name == current {
+		return name + "  (current)"
+	}
+	return name
+}
+
+// execTarget resolves the container for an exec from the pod list: the only
+// regular container or the pod's default. ok is false when the user has to
+// choose, in which case the view moves to the container list.
+func (s *tuiSession) execTarget(item tuiItem) (execTarget, bool, error) {
+	target := s.itemTarget(item)
+	if target.container != "" {
+		if !item.container.Running() {
+			return target, false, notRunningContainerError(target.pod, item.container)
+		}
+		return target, true, nil
+	}
+	containers, defaultContainer, err := GetPodContainers(s.ctx, target.context, target.namespace, target.pod)
+	if err != nil {
+		return target, false, err
+	}
+	regular := containerNames(filterContainersByKind(containers, ContainerKindRegular))
+	switch {
+	case len(regular) == 1:
+		target.container = regular[0]
+	case defaultContainer != "":
+		target.container = defaultContainer
+	default:
+		s.view.pod = item.pod
+		s.view.setLevel(tuiContainers, "")
+		s.load()
+		return target, false, nil
+	}
+	return target, true, nil
+}
+
+func (s *tuiSession) itemTarget(item tuiItem) execTarget {
+	return execTarget{context: s.view.context, namespace: item.pod.Namespace, pod: item.pod.Name, container: item.container.Name}
+}
+
+func (s *tuiSession) describeArgs(item tuiItem) []string {
+	switch s.view.level {
+	case tuiNamespaces:
+		return kubectlArgs(s.view.context, "describe", "namespace", item.key)
+	default:
+		return kubectlArgs(s.view.context, "describe", "pod", "-n", item.pod.Namespace, item.pod.Name)
+	}
+}
+
+// execOptions applies the settings of the context being exec'd into, so
+// confirm-context, the banner and shell follow its [context."<glob>"] sections.
+func (s *tuiSession) execOptions(context string) execOptions {
+	settings := s.settings.ForContext(context)
+	opts := execOptions{
+		confirmContext: settings.Bool("confirm-context"),
+		reconnect:      s.reconn,
+		replay:         true,
+		env:            s.env,
+		banner:         settings.Bool("banner"),
+		terminalTitle:  settings.Bool("terminal-title"),
+		streams:        stdExecutor(nil),
+	}
+	if color, err := parseBannerColor(settings.String("banner-color")); err == nil {
+		opts.bannerColor = color
+	}
+	if confirm, err := newConfirmPolicy(settings.String("confirm-style"), settings.Duration("confirm-countdown"), false, settings.String("confirm-token-hash")); err == nil {
+		opts.confirm = confirm
+	}
+	opts.keywords = confirmContextKeywords(settings)
+	if shell, err := shellFromSettings(settings); err == nil && shell != "" {
+		opts.command = interactiveShellCommand(shell)
+	}
+	return opts
+}
+
+// suspended restores the terminal, runs fn and takes the terminal back.
+func (s *tuiSession) suspended(fn func() error) error {
+	s.term.leave()
+	defer s.term.enter()
+	return fn()
+}
+
+// interruptible runs cmd on the terminal. Ctrl-C stops cmd and returns to
+// the TUI instead of ending kubeexec.
+func (s *tuiSession) interruptible(cmd *exec.Cmd) error {
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err := cmd.Run()
+	select {
+	case <-interrupts:
+		return nil
+	default:
+		return err
+	}
+}
+
+// page shows output with $PAGER (less -R by default), or prints it and waits
+// for enter when no pager is installed.
+func page(output []byte) error {
+	pager := strings.Fields(os.Getenv("PAGER"))
+	if len(pager) == 0 {
+		pager = []string{"less", "-R"}
+	}
+	if _, err := exec.LookPath(pager[0]); err == nil {
+		cmd := exec.Command(pager[0], pager[1:]...)
+		cmd.Stdin = strings.NewReader(string(output))
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		return cmd.Run()
+	}
+	os.Stdout.Write(output)
+	fmt.Fprint(os.Stdout, "\npress enter to return")
+	bufio.NewReader(os.Stdin).ReadString('\n')
+	return nil
+}
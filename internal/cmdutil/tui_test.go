@@ -0,0 +1,163 @@
+package cmdutil
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeKeys(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{input: "j", want: []string{"j"}},
+		{input: "\x1b[A\x1b[B", want: []string{"up", "down"}},
+		{input: "\x1bOD\r", want: []string{"left", "enter"}},
+		{input: "\x1b", want: []string{"esc"}},
+		{input: "\x7f\x03", want: []string{"backspace", "ctrl-c"}},
+		{input: "\x1b[5~\x1b[6~", want: []string{"pgup", "pgdown"}},
+		{input: "é", want: []string{"é"}},
+	}
+	for _, tt := range tests {
+		if got := decodeKeys(tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("decodeKeys(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateVisible(t *testing.T) {
+	tests := []struct {
+		input string
+		width int
+		want  string
+	}{
+		{input: "api-1", width: 10, want: "api-1"},
+		{input: "api-1234", width: 5, want: "api-1\x1b[0m"},
+		{input: "api  \x1b[32mRunning\x1b[0m", width: 7, want: "api  \x1b[32mRu\x1b[0m"},
+		{input: "api  \x1b[32mRunning\x1b[0m", width: 20, want: "api  \x1b[32mRunning\x1b[0m"},
+	}
+	for _, tt := range tests {
+		if got := truncateVisible(tt.input, tt.width); got != tt.want {
+			t.Errorf("truncateVisible(%q, %d) = %q, want %q", tt.input, tt.width, got, tt.want)
+		}
+	}
+}
+
+func tuiTestItems(keys ...string) []tuiItem {
+	items := make([]tuiItem, 0, len(keys))
+	for _, key := range keys {
+		namespace, name, _ := strings.Cut(key, "/")
+		items = append(items, tuiItem{key: key, name: name, display: name, pod: PodItem{Namespace: namespace, Name: name}})
+	}
+	return items
+}
+
+func TestTUIViewNavigation(t *testing.T) {
+	view := tuiView{level: tuiPods, context: "prod", namespace: "payments"}
+	view.setItems(tuiTestItems("payments/api-1", "payments/api-2", "payments/worker-1"))
+
+	view.handleKey("down")
+	view.handleKey("down")
+	view.handleKey("down")
+	if item, _ := view.selected(); item.key != "payments/worker-1" {
+		t.Fatalf("cursor on %q after moving past the end, want payments/worker-1", item.key)
+	}
+
+	// A refresh keeps the cursor on the same pod even if it moved.
+	view.setItems(tuiTestItems("payments/api-2", "payments/worker-1", "payments/api-3"))
+	if item, _ := view.selected(); item.key != "payments/worker-1" {
+		t.Errorf("cursor on %q after refresh, want payments/worker-1", item.key)
+	}
+
+	for _, key := range []string{"/", "a", "p", "i", "backspace", "i", "enter"} {
+		view.handleKey(key)
+	}
+	if view.filter != "api" || view.filtering || len(view.visible()) != 2 {
+		t.Errorf("filter = %q (filtering %v), visible %d, want api with 2 matches", view.filter, view.filtering, len(view.visible()))
+	}
+
+	if action := view.handleKey("x"); action.kind != tuiExec || action.item.key != "payments/api-2" {
+		t.Errorf("x = %+v, want exec of payments/api-2", action)
+	}
+	if action := view.handleKey("L"); action.kind != tuiLogs {
+		t.Errorf("L = %+v, want logs", action)
+	}
+
+	generation := view.generation
+	if action := view.handleKey("enter"); action.kind != tuiLoad || view.level != tuiContainers || view.pod.Name != "api-2" || view.filter != "" {
+		t.Errorf("enter on a pod = %+v, level %v pod %q filter %q", action, view.level, view.pod.Name, view.filter)
+	}
+	if view.generation == generation {
+		t.Errorf("changing level did not start a new generation")
+	}
+	view.setItems([]tuiItem{{key: "app", name: "app", container: ContainerItem{Name: "app"}}})
+	if action := view.handleKey("enter"); action.kind != tuiExec || action.item.container.Name != "app" {
+		t.Errorf("enter on a container = %+v, want exec", action)
+	}
+
+	view.handleKey("esc")
+	if view.level != tuiPods || view.selectKey != "payments/api-2" {
+		t.Errorf("back from containers: level %v selectKey %q", view.level, view.selectKey)
+	}
+	view.handleKey("left")
+	view.handleKey("left")
+	if view.level != tuiContexts || view.selectKey != "prod" {
+		t.Errorf("back to contexts: level %v selectKey %q", view.level, view.selectKey)
+	}
+	view.setItems([]tuiItem{{key: "dev", name: "dev", display: "dev"}, {key: "prod", name: "prod", display: "prod"}})
+	view.handleKey("up")
+	view.handleKey("enter")
+	if view.context != "dev" || view.level != tuiNamespaces || view.namespace != "" {
+		t.Errorf("open context: context %q level %v namespace %q", view.context, view.level, view.namespace)
+	}
+	if action := view.handleKey("q"); action.kind != tuiQuit {
+		t.Errorf("q = %+v, want quit", action)
+	}
+}
+
+func TestTUIViewRefreshKeepsCursor(t *testing.T) {
+	view := tuiView{level: tuiPods, context: "prod", namespace: "payments", pod: PodItem{Namespace: "payments", Name: "api-1"}}
+	view.setLevel(tuiContainers, "")
+	containers := []tuiItem{{key: "app", name: "app"}, {key: "proxy", name: "proxy"}, {key: "debugger", name: "debugger"}}
+	if !view.applyResult(tuiResult{generation: view.generation, items: containers, selectKey: "proxy"}) {
+		t.Fatalf("applyResult dropped the result of the current generation")
+	}
+	if item, _ := view.selected(); item.key != "proxy" {
+		t.Fatalf("cursor on %q after the first load, want the default container proxy", item.key)
+	}
+
+	view.handleKey("down")
+	view.applyResult(tuiResult{generation: view.generation, items: containers, selectKey: "proxy"})
+	if item, _ := view.selected(); item.key != "debugger" {
+		t.Errorf("cursor on %q after a refresh, want it to stay on debugger", item.key)
+	}
+
+	if view.applyResult(tuiResult{generation: view.generation - 1, items: containers[:1]}) || len(view.items) != 3 {
+		t.Errorf("applyResult took the result of an older generation")
+	}
+}
+
+func TestTUIViewRender(t *testing.T) {
+	view := tuiView{level: tuiPods, context: "prod", namespace: "payments", status: "copied api-2"}
+	view.setItems(tuiTestItems("payments/api-1", "payments/api-2"))
+	view.handleKey("down")
+	lines := strings.Split(strings.TrimPrefix(view.render(8, 50), "\x1b[H\x1b[2J"), "\r\n")
+	if len(lines) != 8 {
+		t.Fatalf("render(8, 50) has %d lines, want 8", len(lines))
+	}
+	if !strings.Contains(lines[0], "context: prod  namespace: payments") {
+		t.Errorf("title line = %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "pods (2)") || !strings.Contains(lines[3], "> api-2") {
+		t.Errorf("list lines = %q", lines[1:4])
+	}
+	if !strings.Contains(lines[6], "copied api-2") {
+		t.Errorf("status line = %q", lines[6])
+	}
+	for _, line := range lines {
+		if n := len([]rune(stripANSI(strings.TrimSuffix(line, "\x1b[K")))); n > 50 {
+			t.Errorf("line wider than the terminal (%d): %q", n, line)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package cmdutil
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// tuiTerminal is the controlling terminal in raw mode, driven with stty so
+// no terminal library is needed.
+type tuiTerminal struct {
+	tty   *os.File
+	saved string
+}
+
+func openTUITerminal() (*tuiTerminal, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, usageErrorf("tui needs a terminal: %w", err)
+	}
+	// Fd switches the file to blocking reads, so the stty read timeout below
+	// (min 0 time 1) makes each read return after at most 100ms.
+	tty.Fd()
+	term := &tuiTerminal{tty: tty}
+	saved, err := term.stty("-g")
+	if err != nil {
+		tty.Close()
+		return nil, usageErrorf("tui needs stty: %w", err)
+	}
+	term.saved = strings.TrimSpace(saved)
+	term.enter()
+	return term, nil
+}
+
+func (t *tuiTerminal) stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = t.tty
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// enter switches to raw mode and the alternate screen.
+func (t *tuiTerminal) enter() {
+	t.stty("raw", "-echo", "min", "0", "time", "1")
+	t.write("\x1b[?1049h\x1b[?25l")
+}
+
+// leave restores the terminal as it was before the TUI started.
+func (t *tuiTerminal) leave() {
+	t.write("\x1b[?25h\x1b[?1049l")
+	t.stty(t.saved)
+}
+
+func (t *tuiTerminal) close() {
+	t.leave()
+	t.tty.Close()
+}
+
+func (t *tuiTerminal) write(s string) {
+	io.WriteString(t.tty, s)
+}
+
+// size returns the terminal rows and columns, or 24x80 when unknown.
+func (t *tuiTerminal) size() (int, int) {
+	out, err := t.stty("size")
+	if err == nil {
+		if fields := strings.Fields(out); len(fields) == 2 {
+			rows, errRows := strconv.Atoi(fields[0])
+			cols, errCols := strconv.Atoi(fields[1])
+			if errRows == nil && errCols == nil && rows > 0 && cols > 0 {
+				return rows, cols
+			}
+		}
+	}
+	return 24, 80
+}
+
+// readKeys waits up to 100ms for input and returns the keys read.
+func (t *tuiTerminal) readKeys() ([]string, error) {
+	buf := make([]byte, 256)
+	n, err := t.tty.Read(buf)
+	if n > 0 {
+		return decodeKeys(string(buf[:n])), nil
+	}
+	if err == io.EOF {
+		return nil, nil
+	}
+	return nil, err
+}
@@ -0,0 +1,206 @@
+package cmdutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+const DefaultWaitTimeout = 5 * time.Minute
+
+// waitPollInterval is how often --wait polls; a variable so tests can poll
+// faster.
+var waitPollInterval = 2 * time.Second
+
+// waitForContainer polls the target pod until its container is running and
+// ready, printing state transitions to out. If the pod is deleted or starts
+// terminating, it follows the newest replacement from the same owner. It
+// returns the name of the pod to exec into.
+func waitForContainer(ctx context.Context, target execTarget, timeout time.Duration, out io.Writer) (string, error) {
+	deadline := time.Now().Add(timeout)
+	lastState := ""
+	var owner ownerReferenceJSON
+	for {
+		pod, found, err := getPodObject(ctx, target.context, target.namespace, target.pod)
+		if err != nil {
+			return "", err
+		}
+		if found {
+			if ref, ok := podController(pod); ok {
+				owner = ref
+			}
+		}
+		if !found || pod.Metadata.DeletionTimestamp != nil {
+			replacement, ok, err := findReplacementPod(ctx, target, owner)
+			if err != nil {
+				return "", err
+			}
+			if ok {
+				fmt.Fprintf(out, "wait: pod %q is gone; following replacement %q\n", target.pod, replacement.Metadata.Name)
+				target.pod = replacement.Metadata.Name
+				lastState = ""
+				continue
+			}
+			if owner.Name == "" {
+				return "", resolutionErrorf("pod %q was deleted while waiting and has no owner to follow", target.pod)
+			}
+			lastState = reportWaitState(out, target, "terminating, waiting for a replacement", lastState)
+		} else {
+			state, ready, ok := containerWaitState(pod, target.container)
+			if !ok {
+				return "", resolutionErrorf("container %q not found in pod %q", target.container, target.pod)
+			}
+			lastState = reportWaitState(out, target, state, lastState)
+			if ready {
+				return target.pod, nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return "", resolutionErrorf("timed out after %s waiting for container %q in pod %q (last state: %s)", timeout, target.container, target.pod, lastState)
+		}
+		if err := sleepContext(ctx, waitPollInterval); err != nil {
+			return "", err
+		}
+	}
+}
+
+func reportWaitState(out io.Writer, target execTarget, state, lastState string) string {
+	if state != lastState {
+		fmt.Fprintf(out, "wait: pod %q container %q: %s\n", target.pod, target.container, state)
+	}
+	return state
+}
+
+// containerWaitState summarizes the container for wait progress and reports
+// whether it is ready for exec. Regular containers must be running and ready;
+// sidecar, init and ephemeral containers only need to be running since they
+// often have no readiness probe.
+func containerWaitState(pod podJSON, name string) (string, bool, bool) {
+	containers, _ := podContainers(pod)
+	for _, c := range containers {
+		if c.Name != name {
+			continue
+		}
+		if !c.Running() {
+			return c.StateSummary(), false, true
+		}
+		if c.Kind != ContainerKindRegular || c.Ready {
+			return "running, ready", true, true
+		}
+		return "running, not ready", false, true
+	}
+	// Ephemeral containers that are not running are dropped by podContainers.
+	for _, spec := range pod.Spec.EphemeralContainers {
+		if spec.Name == name {
+			return containerStateWaiting, false, true
+		}
+	}
+	return "", false, false
+}
+
+func getPodObject(ctx context.Context, context, namespace, name string) (podJSON, bool, error) {
+	var pod podJSON
+	out, err := getPodJSON(ctx, context, namespace, name)
+	if err != nil {
+		// kubectl marks a missing pod "Error from server (NotFound)"; other
+		// failures that merely mention "not found" are real errors.
+		if strings.Contains(err.Error(), "(NotFound)") {
+			return pod, false, nil
+		}
+		return pod, false, kubectlErrorf("kubectl get pod failed: %w", err)
+	}
+	if err := json.Unmarshal(out, &pod); err != nil {
+		return pod, false, kubectlErrorf("kubectl get pod failed: parse pod: %w", err)
+	}
+	return pod, true, nil
+}
+
+func findReplacementPod(ctx context.Context, target execTarget, owner ownerReferenceJSON) (podJSON, bool, error) {
+	if owner.Name == "" {
+		return podJSON{}, false, nil
+	}
+	candidates, err := listPods(ctx, target.context, target.namespace, PodQuery{Selector: target.query.Selector}, false, false)
+	if err != nil {
+		return podJSON{}, false, err
+	}
+	replacement, ok := pickReplacementPod(candidates, owner, target.pod)
+	return replacement, ok, nil
+}
+
+// pickReplacementPod returns the newest non-terminating pod controlled by the
+// same owner. ReplicaSet owners are widened to their deployment so pods from
+// a new rollout revision are followed too.
+func pickReplacementPod(candidates []podJSON, owner ownerReferenceJSON, previous string) (podJSON, bool) {
+	kind, name := owner.Kind, owner.Name
+	if owner.Kind == "ReplicaSet" {
+		if i := strings.LastIndex(owner.Name, "-"); i > 0 {
+			kind, name = "Deployment", owner.Name[:i]
+		}
+	}
+	var matches []podJSON
+	for _, pod := range candidates {
+		if pod.Metadata.Name == previous || pod.Metadata.DeletionTimestamp != nil {
+			continue
+		}
+		if podOwnedBy(pod, kind, name) || podOwnedBy(pod, owner.Kind, owner.Name) {
+			matches = append(matches, pod)
+		}
+	}
+	if len(matches) == 0 {
+		return podJSON{}, false
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Metadata.CreationTimestamp.After(matches[j].Metadata.CreationTimestamp)
+	})
+	return matches[0], true
+}
+
+// waitForPods polls until the query lists a pod podArg can resolve to, for
+// targets that have not been created yet (e.g. right after kubectl apply).
+// On timeout it returns the last list, so the caller reports why nothing
+// matched.
+func waitForPods(ctx context.Context, context, namespace string, query PodQuery, podArg string, allNamespaces bool, timeout time.Duration, out io.Writer) ([]PodItem, error) {
+	deadline := time.Now().Add(timeout)
+	if podArg == "" {
+		fmt.Fprintln(out, "wait: no pods found yet; waiting for matching pods")
+	} else {
+		fmt.Fprintf(out, "wait: no pods match %q yet; waiting for matching pods\n", podArg)
+	}
+	var pods []PodItem
+	for time.Now().Before(deadline) {
+		if err := sleepContext(ctx, waitPollInterval); err != nil {
+			return nil, err
+		}
+		var err error
+		pods, err = GetPods(ctx, context, namespace, query, allNamespaces)
+		if err != nil {
+			return nil, err
+		}
+		if podsMatchArg(pods, podArg, allNamespaces) {
+			return pods, nil
+		}
+	}
+	return pods, nil
+}
+
+// podsMatchArg reports whether podChooser.resolve could find podArg among
+// pods: namespace/pod in all-namespaces mode, else any partial match. An
+// empty podArg matches any pod.
+func podsMatchArg(pods []PodItem, podArg string, allNamespaces bool) bool {
+	if podArg == "" {
+		return len(pods) > 0
+	}
+	if allNamespaces && strings.Contains(podArg, "/") {
+		ns, name, ok := splitPodNamespaceArg(podArg)
+		if !ok {
+			return true // let resolve report the invalid argument
+		}
+		_, found := podFromKey(pods, ns+"/"+name)
+		return found
+	}
+	return len(filterPodsByQuery(pods, podArg)) > 0
+}
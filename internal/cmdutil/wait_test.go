@@ -0,0 +1,146 @@
+package cmdutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContainerWaitState(t *testing.T) {
+	var pod podJSON
+	if err := json.Unmarshal([]byte(podWithSidecarJSON), &pod); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	tests := []struct {
+		container string
+		wantState string
+		wantReady bool
+		wantFound bool
+	}{
+		{"app", "running, ready", true, true},
+		{"metrics", "waiting (CrashLoopBackOff)", false, true},
+		{"proxy", "running, ready", true, true},
+		{"migrate", "terminated (Completed)", false, true},
+		{"debugger-old", "waiting", false, true},
+		{"missing", "", false, false},
+	}
+	for _, tt := range tests {
+		state, ready, found := containerWaitState(pod, tt.container)
+		if state != tt.wantState || ready != tt.wantReady || found != tt.wantFound {
+			t.Errorf("containerWaitState(%q) = (%q, %v, %v), want (%q, %v, %v)", tt.container, state, ready, found, tt.wantState, tt.wantReady, tt.wantFound)
+		}
+	}
+}
+
+func TestContainerWaitStateRunningNotReady(t *testing.T) {
+	var pod podJSON
+	data := `{"spec": {"containers": [{"name": "app"}]}, "status": {"containerStatuses": [{"name": "app", "ready": false, "state": {"running": {}}}]}}`
+	if err := json.Unmarshal([]byte(data), &pod); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	state, ready, found := containerWaitState(pod, "app")
+	if state != "running, not ready" || ready || !found {
+		t.Errorf("containerWaitState = (%q, %v, %v), want (\"running, not ready\", false, true)", state, ready, found)
+	}
+}
+
+func TestPickReplacementPod(t *testing.T) {
+	now := time.Now()
+	newPod := func(name, ownerKind, ownerName string, age time.Duration, terminating bool) podJSON {
+		var pod podJSON
+		pod.Metadata.Name = name
+		pod.Metadata.CreationTimestamp = now.Add(-age)
+		pod.Metadata.OwnerReferences = []ownerReferenceJSON{{Kind: ownerKind, Name: ownerName, Controller: true}}
+		if terminating {
+			ts := now
+			pod.Metadata.DeletionTimestamp = &ts
+		}
+		return pod
+	}
+	candidates := []podJSON{
+		newPod("api-6c8d9f-aaaaa", "ReplicaSet", "api-6c8d9f", 10*time.Minute, true),
+		newPod("api-7d9f8c-bbbbb", "ReplicaSet", "api-7d9f8c", 30*time.Second, false),
+		newPod("api-7d9f8c-ccccc", "ReplicaSet", "api-7d9f8c", 10*time.Second, false),
+		newPod("worker-5b6c7d-ddddd", "ReplicaSet", "worker-5b6c7d", 5*time.Second, false),
+		newPod("db-0", "StatefulSet", "db", 5*time.Second, false),
+	}
+
+	tests := []struct {
+		name     string
+		owner    ownerReferenceJSON
+		previous string
+		want     string
+		wantOk   bool
+	}{
+		{"new rollout revision", ownerReferenceJSON{Kind: "ReplicaSet", Name: "api-6c8d9f"}, "api-6c8d9f-aaaaa", "api-7d9f8c-ccccc", true},
+		{"statefulset recreates same name", ownerReferenceJSON{Kind: "StatefulSet", Name: "db"}, "db-1", "db-0", true},
+		{"skips previous pod", ownerReferenceJSON{Kind: "StatefulSet", Name: "db"}, "db-0", "", false},
+		{"no matching owner", ownerReferenceJSON{Kind: "Job", Name: "migrate"}, "migrate-xyz", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := pickReplacementPod(candidates, tt.owner, tt.previous)
+			if ok != tt.wantOk || got.Metadata.Name != tt.want {
+				t.Errorf("pickReplacementPod() = (%q, %v), want (%q, %v)", got.Metadata.Name, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestRunWaitForPodArgument(t *testing.T) {
+	tests := []struct {
+		name     string
+		pod      string
+		wantErr  string
+		wantWait bool
+	}{
+		{name: "already matches", pod: "api-1"},
+		{name: "other pods only", pod: "batch", wantErr: `no pods match "batch"`, wantWait: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newFakeCluster(t, fakeRunCluster())
+			interval := waitPollInterval
+			waitPollInterval = 10 * time.Millisecond
+			t.Cleanup(func() { waitPollInterval = interval })
+			var stderr bytes.Buffer
+			opts := RunOptions{Pod: tt.pod, Picker: "none", Wait: 50 * time.Millisecond, Stdout: io.Discard, Stderr: &stderr}
+			err := Run(context.Background(), opts)
+			if tt.wantErr != "" {
+				if !IsKind(err, KindResolution) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Run() error = %v, want resolution error %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("Run() error: %v", err)
+			}
+			if got := strings.Contains(stderr.String(), "wait: no pods match"); got != tt.wantWait {
+				t.Errorf("Run() stderr = %q, want wait message %t", stderr.String(), tt.wantWait)
+			}
+			lists := 0
+			for _, call := range h.calls(t) {
+				if fakeArgsHavePrefix(call.Args, "--context", "dev", "get", "pods") || fakeArgsHavePrefix(call.Args, "get", "pods") {
+					lists++
+				}
+			}
+			if tt.wantWait && lists < 2 {
+				t.Errorf("listed pods %d times, want polling until the timeout", lists)
+			}
+		})
+	}
+}
+
+func TestGetPodObjectNotFound(t *testing.T) {
+	newFakeCluster(t, fakeRunCluster())
+	_, found, err := getPodObject(context.Background(), "dev", "payments", "gone-1")
+	if err != nil || found {
+		t.Errorf("getPodObject(gone-1) = found %t, error %v, want not found without error", found, err)
+	}
+	_, _, err = getPodObject(context.Background(), "nope", "payments", "api-1")
+	if !IsKind(err, KindKubectl) {
+		t.Errorf("getPodObject() with a missing context error = %v, want kubectl error", err)
+	}
+}
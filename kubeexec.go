@@ -0,0 +1,103 @@
+// Package kubeexec resolves exec targets with the kubeexec command's matching
+// rules and runs commands in them through kubectl.
+//
+// A Resolver turns a Query (context, namespace, pod and container, exact or
+// partial) into a Target, asking its Selector when the query is ambiguous.
+// An Executor runs a command in a Target with explicit streams:
+//
+//	target, err := kubeexec.Resolver{}.Resolve(ctx, kubeexec.Query{Pod: "api"})
+//	if err != nil {
+//		return err
+//	}
+//	err = kubeexec.Executor{Stdout: &out}.Exec(ctx, target, "uptime")
+//
+// Both read kubectl's configuration from the environment, like the command. A
+// Resolver uses the Settings it is given, or loads them for each Resolve.
+//
+// The kubeexec command is built on this package through Run, RunTUI and
+// Complete, which take the command's options rather than a Query.
+package kubeexec
+
+import (
+	"context"
+
+	"github.com/estebanmilaho/kubeexec/internal/cmdutil"
+)
+
+type (
+	// Target is a resolved exec destination.
+	Target = cmdutil.Target
+	// Query describes the target to resolve.
+	Query = cmdutil.Query
+	// PodQuery narrows the pods a Query considers.
+	PodQuery = cmdutil.PodQuery
+	// Resolver turns a Query into a Target.
+	Resolver = cmdutil.Resolver
+	// Executor runs commands in a Target with kubectl exec.
+	Executor = cmdutil.Executor
+	// EnvVar is an environment variable set for an exec'd command.
+	EnvVar = cmdutil.EnvVar
+	// Settings are the kubeexec settings a Resolver uses.
+	Settings = cmdutil.Settings
+
+	// Selector makes the choices a Query leaves open.
+	Selector = cmdutil.Selector
+	// Selection is one choice asked of a Selector.
+	Selection = cmdutil.Selection
+	// PickerItem is one choice of a Selection.
+	PickerItem = cmdutil.PickerItem
+	// NonInteractiveSelector refuses every choice.
+	NonInteractiveSelector = cmdutil.NonInteractiveSelector
+	// ScriptedSelector answers choices from a list of keys.
+	ScriptedSelector = cmdutil.ScriptedSelector
+
+	// Error is a classified failure.
+	Error = cmdutil.Error
+	// ErrorKind classifies failures.
+	ErrorKind = cmdutil.ErrorKind
+	// RemoteExitError carries the exit status of the command run in the pod.
+	RemoteExitError = cmdutil.RemoteExitError
+	// SignalError is the cause of a context cancelled by SignalContext.
+	SignalError = cmdutil.SignalError
+)
+
+// Error kinds.
+const (
+	KindUsage      = cmdutil.KindUsage
+	KindResolution = cmdutil.KindResolution
+	KindAmbiguous  = cmdutil.KindAmbiguous
+	KindKubectl    = cmdutil.KindKubectl
+	KindDenied     = cmdutil.KindDenied
+	KindCancelled  = cmdutil.KindCancelled
+)
+
+// ErrSelectionDisabled is returned by selectors that refuse every choice.
+var ErrSelectionDisabled = cmdutil.ErrSelectionDisabled
+
+// NewPicker returns the Selector for a picker name: fzf, skim, builtin, none,
+// a [pickers.<name>] table of the config or a command line.
+func NewPicker(name string) (Selector, error) {
+	return cmdutil.NewPicker(name)
+}
+
+// LoadSettings reads the kubeexec settings from the config files and the
+// environment, for a Resolver's Settings.
+func LoadSettings() (*Settings, error) {
+	return cmdutil.ResolveSettings(nil)
+}
+
+// SignalContext returns a context cancelled by the first SIGINT or SIGTERM.
+// An Executor passes that signal on to kubectl instead of killing it.
+func SignalContext() (ctx context.Context, stop func()) {
+	return cmdutil.SignalContext()
+}
+
+// IsKind reports whether err is a failure of the given kind.
+func IsKind(err error, kind ErrorKind) bool {
+	return cmdutil.IsKind(err, kind)
+}
+
+// ExitCode maps an error to the exit code the kubeexec command would use.
+func ExitCode(err error) int {
+	return cmdutil.ExitCode(err)
+}
@@ -4,10 +4,16 @@ setup() {
   export PATH="$BATS_TEST_DIRNAME/bin:$PATH"
 }
 
-@test "ignore fzf fails fast when no pod specified" {
+@test "picker none fails fast when no pod specified" {
+  run go run ./cmd/kubeexec --context ctx -n ns --picker none
+  [ "$status" -eq 121 ]
+  [[ "$output" == *"pod not specified and the picker is disabled"* ]]
+}
+
+@test "ignore fzf still disables the picker" {
   run env KUBEEXEC_IGNORE_FZF=1 go run ./cmd/kubeexec --context ctx -n ns
-  [ "$status" -ne 0 ]
-  [[ "$output" == *"pod not specified and fzf is disabled"* ]]
+  [ "$status" -eq 121 ]
+  [[ "$output" == *"pod not specified and the picker is disabled"* ]]
 }
 
 @test "dry-run passes command args" {